@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/pcap"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/repository"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/websocket"
+)
+
+// pcapStats counts what happened to every record in a capture file
+type pcapStats struct {
+	records   int
+	estimated int
+	udp       int
+	otherPort int
+	skipped   int
+	packets   int
+	stored    int
+	failed    int
+}
+
+// runIngestPcap feeds the UDP payloads of a pcap or pcapng file through the
+// processing pipeline, using capture timestamps as receipt times
+func runIngestPcap(args []string) {
+	fs := flag.NewFlagSet("ingest-pcap", flag.ExitOnError)
+	file := fs.String("file", "", "pcap or pcapng file to read")
+	port := fs.Int("port", 8089, "UDP destination port carrying telemetry")
+	dataset := fs.String("dataset", models.DatasetLive, "dataset to store decoded telemetry in")
+	archive := fs.Bool("archive", false, "also store each datagram in the raw packet archive")
+	spacecraft := fs.Uint("spacecraft", 0, "spacecraft tag for packets that do not carry one")
+	stream := fs.String("stream", "", "stream tag applied to every packet")
+	fs.Parse(args)
+
+	if *file == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatal("Failed to open capture file:", err)
+	}
+	defer f.Close()
+
+	reader, err := pcap.NewReader(f)
+	if err != nil {
+		log.Fatal("Failed to read capture file:", err)
+	}
+	if info, err := f.Stat(); err == nil {
+		reader.Start = info.ModTime()
+	}
+
+	cfg := config.LoadConfig()
+	ingestCfg := config.LoadIngestConfig()
+
+	// Nothing is broadcast offline, but the repository expects a WebSocket server
+	repo := repository.NewTelemetryRepository(cfg, websocket.NewWebSocketServer())
+	source := config.ListenerConfig{
+		Name:         *file,
+		Mode:         ingestCfg.Mode,
+		SpacecraftID: uint16(*spacecraft),
+		Stream:       *stream,
+	}
+	pipeline := telemetry.NewReplayPipeline(repo, ingestCfg, source, *dataset)
+
+	var stats pcapStats
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log.Printf("Stopped reading capture after %d records: %v", stats.records, err)
+			break
+		}
+		stats.records++
+		if record.Estimated {
+			if stats.estimated == 0 {
+				log.Printf("Capture has Simple Packet Blocks without timestamps; their receipt times are estimated from the preceding record")
+			}
+			stats.estimated++
+		}
+
+		datagram, err := pcap.DecodeUDP(record.LinkType, record.Data)
+		if err != nil {
+			stats.skipped++
+			continue
+		}
+		stats.udp++
+		if int(datagram.DstPort) != *port {
+			stats.otherPort++
+			continue
+		}
+
+		if *archive {
+			raw := models.RawPacket{
+				ReceivedAt:   record.Timestamp,
+				Source:       datagram.Source(),
+				Mode:         source.Mode,
+				SpacecraftID: source.SpacecraftID,
+				Stream:       source.Stream,
+				Data:         datagram.Payload,
+			}
+			if err := repo.InsertRawPacket(raw); err != nil {
+				log.Printf("Failed to archive raw packet: %v", err)
+			}
+		}
+
+		for _, packet := range pipeline.Extract(datagram.Payload, record.Timestamp) {
+			stats.packets++
+			if err := pipeline.Handle(packet); err != nil {
+				stats.failed++
+			} else {
+				stats.stored++
+			}
+		}
+	}
+
+	decode := pipeline.Stats()
+	log.Printf("Capture %s: %d records, %d UDP datagrams (%d to other ports), %d non-UDP or undecodable",
+		*file, stats.records, stats.udp, stats.otherPort, stats.skipped)
+	if stats.estimated > 0 {
+		log.Printf("%d records had estimated receipt times", stats.estimated)
+	}
+	log.Printf("Telemetry: %d packets, %d stored, %d failed (%d CRC errors, %d decode errors)",
+		stats.packets, stats.stored, stats.failed, decode.CRCErrors, decode.DecodeErrors)
+}
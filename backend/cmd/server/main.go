@@ -2,20 +2,35 @@ package main
 
 import (
 	"log"
+	"os"
 	"sync"
 
 	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/api"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/commanding"
 	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
 	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/repository"
 	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/clock"
 	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/websocket"
 )
 
 func main() {
+	// Offline subcommands run instead of the services
+	if len(os.Args) > 1 && os.Args[1] == "ingest-pcap" {
+		runIngestPcap(os.Args[2:])
+		return
+	}
+
 	log.Println("Starting Telemetry Services...")
 
 	// Load configuration
 	cfg := config.LoadConfig()
+	ingestCfg := config.LoadIngestConfig()
+	commandCfg := config.LoadCommandConfig()
+	if ids := commandCfg.Unidentified(ingestCfg); len(ids) > 0 {
+		log.Fatalf("No listener identifies packets from commandable spacecraft %v: "+
+			"packet listeners need spacecraft=<id>, or a fleet needs frame or CADU ingest", ids)
+	}
 
 	// Initialize WebSocket server
 	wsServer := websocket.NewWebSocketServer()
@@ -24,20 +39,30 @@ func main() {
 	// Initialize database connection
 	repo := repository.NewTelemetryRepository(cfg, wsServer)
 
+	// Onboard clock correlation is shared by ingest and the API
+	correlator := clock.NewCorrelator(ingestCfg)
+	replayer := telemetry.NewReplayer(repo, ingestCfg)
+
+	// Command verification follows acknowledgements and telemetry from ingest
+	verifier := commanding.NewVerifier(repo, wsServer, commandCfg)
+	verifier.Start()
+	commander := commanding.NewCommander(repo, verifier, commandCfg)
+
+	telemetryServer := telemetry.NewTelemetryServer(repo, correlator, verifier, ingestCfg)
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
-	// Start the UDP telemetry server
+	// Start the telemetry listeners
 	go func() {
 		defer wg.Done()
-		telemetryServer := telemetry.NewTelemetryServer(repo, "8089")
 		telemetryServer.Start()
 	}()
 
 	// Start the API server
 	go func() {
 		defer wg.Done()
-		apiServer := api.NewAPIServer(repo, "3000", wsServer)
+		apiServer := api.NewAPIServer(repo, "3000", wsServer, correlator, replayer, telemetryServer, commander)
 		apiServer.Start()
 	}()
 
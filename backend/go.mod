@@ -6,6 +6,7 @@ require (
 	github.com/gofiber/fiber/v2 v2.49.2
 	github.com/gofiber/websocket/v2 v2.2.1
 	github.com/joho/godotenv v1.5.1
+	github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds v0.0.0
 	gorm.io/driver/postgres v1.5.2
 	gorm.io/gorm v1.25.4
 )
@@ -32,3 +33,6 @@ require (
 	golang.org/x/sys v0.12.0 // indirect
 	golang.org/x/text v0.13.0 // indirect
 )
+
+// The packet library lives alongside the backend and generator in this repository
+replace github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds => ../ccsds
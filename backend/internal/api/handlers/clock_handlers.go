@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/clock"
+)
+
+// ClockHandler handles API requests for onboard clock correlation
+type ClockHandler struct {
+	correlator *clock.Correlator
+}
+
+// NewClockHandler creates a new handler with the given correlator
+func NewClockHandler(correlator *clock.Correlator) *ClockHandler {
+	return &ClockHandler{correlator: correlator}
+}
+
+// GetCorrelation handles requests for the current clock correlation parameters
+func (h *ClockHandler) GetCorrelation(c *fiber.Ctx) error {
+	return c.JSON(h.correlator.Correlations())
+}
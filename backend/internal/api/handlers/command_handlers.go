@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/commanding"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/repository"
+)
+
+// CommandHandler handles API requests for telecommanding
+type CommandHandler struct {
+	commander *commanding.Commander
+	repo      *repository.TelemetryRepository
+}
+
+// NewCommandHandler creates a new handler with the given commander and repository
+func NewCommandHandler(commander *commanding.Commander, repo *repository.TelemetryRepository) *CommandHandler {
+	return &CommandHandler{commander: commander, repo: repo}
+}
+
+// SendCommand handles requests to validate, encode and uplink a command
+func (h *CommandHandler) SendCommand(c *fiber.Ctx) error {
+	var req commanding.Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	cmd, err := h.commander.Send(req)
+	if errors.Is(err, commanding.ErrMissingOperator) ||
+		errors.Is(err, commanding.ErrUnknownCommand) ||
+		errors.Is(err, commanding.ErrUnknownSpacecraft) ||
+		errors.Is(err, commanding.ErrInvalidArgument) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(cmd)
+}
+
+// GetCommands handles requests for the command history
+func (h *CommandHandler) GetCommands(c *fiber.Ctx) error {
+	limit, err := strconv.Atoi(c.Query("limit", "100"))
+	if err != nil || limit <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid limit parameter"})
+	}
+
+	data, err := h.repo.GetCommandHistory(limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	return c.JSON(data)
+}
+
+// GetDictionary handles requests for the commands that may be sent
+func (h *CommandHandler) GetDictionary(c *fiber.Ctx) error {
+	return c.JSON(h.commander.Dictionary())
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry"
+)
+
+// IngestHandler handles API requests for ingest listener metrics
+type IngestHandler struct {
+	server *telemetry.TelemetryServer
+}
+
+// NewIngestHandler creates a new handler with the given telemetry server
+func NewIngestHandler(server *telemetry.TelemetryServer) *IngestHandler {
+	return &IngestHandler{server: server}
+}
+
+// GetStats handles requests for per-listener traffic, rejection and decode counters
+func (h *IngestHandler) GetStats(c *fiber.Ctx) error {
+	return c.JSON(h.server.Stats())
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry"
+)
+
+// ReplayHandler handles API requests for reprocessing archived packets
+type ReplayHandler struct {
+	replayer *telemetry.Replayer
+}
+
+// NewReplayHandler creates a new handler with the given replayer
+func NewReplayHandler(replayer *telemetry.Replayer) *ReplayHandler {
+	return &ReplayHandler{replayer: replayer}
+}
+
+// Replay handles requests to push a time range of archived packets back
+// through the pipeline. The replay runs in the background; its progress is
+// read from GetReplay.
+func (h *ReplayHandler) Replay(c *fiber.Ctx) error {
+	var req telemetry.ReplayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if req.StartTime.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid start_time"})
+	}
+	if req.EndTime.IsZero() || req.EndTime.Before(req.StartTime) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid end_time"})
+	}
+
+	job, err := h.replayer.Start(req)
+	if errors.Is(err, telemetry.ErrLiveOverwrite) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if errors.Is(err, telemetry.ErrReplayRunning) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Replay failed"})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// GetReplay handles requests for the state of a replay job
+func (h *ReplayHandler) GetReplay(c *fiber.Ctx) error {
+	job, ok := h.replayer.Job(c.Params("id"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown replay job"})
+	}
+	return c.JSON(job)
+}
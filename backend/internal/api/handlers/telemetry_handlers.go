@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/downsample"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
 	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/repository"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/pkg/dto"
 )
 
 // TelemetryHandler handles API requests for telemetry data
@@ -18,6 +24,35 @@ func NewTelemetryHandler(repo *repository.TelemetryRepository) *TelemetryHandler
 	return &TelemetryHandler{repo: repo}
 }
 
+// datasetRepo returns the repository scoped to the optional dataset,
+// spacecraft_id and apid query parameters
+func (h *TelemetryHandler) datasetRepo(c *fiber.Ctx) (*repository.TelemetryRepository, error) {
+	spacecraftID, err := queryID(c, "spacecraft_id")
+	if err != nil {
+		return nil, err
+	}
+	apid, err := queryID(c, "apid")
+	if err != nil {
+		return nil, err
+	}
+	source := repository.Source{SpacecraftID: spacecraftID, APID: apid}
+	return h.repo.WithDataset(c.Query("dataset", models.DatasetLive)).WithSource(source), nil
+}
+
+// queryID parses an optional 16-bit ID query parameter, returning nil when absent
+func queryID(c *fiber.Ctx, name string) (*uint16, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(v, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s", name)
+	}
+	id16 := uint16(id)
+	return &id16, nil
+}
+
 // GetTelemetry handles requests for telemetry data within a time range
 func (h *TelemetryHandler) GetTelemetry(c *fiber.Ctx) error {
 	startTime, err := time.Parse(time.RFC3339, c.Query("start_time"))
@@ -30,7 +65,15 @@ func (h *TelemetryHandler) GetTelemetry(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid end_time"})
 	}
 
-	data, err := h.repo.GetTelemetry(startTime, endTime)
+	if method := c.Query("downsample"); method != "" {
+		return h.getDownsampledTelemetry(c, startTime, endTime, method)
+	}
+
+	repo, err := h.datasetRepo(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	data, err := repo.GetTelemetry(startTime, endTime)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
 	}
@@ -38,9 +81,51 @@ func (h *TelemetryHandler) GetTelemetry(c *fiber.Ctx) error {
 	return c.JSON(data)
 }
 
+// getDownsampledTelemetry reduces a time range to about points records per
+// parameter while it is read, keeping every anomaly
+func (h *TelemetryHandler) getDownsampledTelemetry(c *fiber.Ctx, startTime, endTime time.Time, method string) error {
+	points, err := strconv.Atoi(c.Query("points", strconv.Itoa(defaultPoints)))
+	if err != nil || points < 1 || points > maxPoints {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid points; must be between 1 and %d", maxPoints),
+		})
+	}
+
+	repo, err := h.datasetRepo(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	total, err := repo.CountTelemetry(startTime, endTime)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	data := []models.Telemetry{}
+	sampler, err := downsample.New(method, int(total), points, func(t models.Telemetry) error {
+		data = append(data, t)
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := repo.EachTelemetry(startTime, endTime, sampler.Add); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	if err := sampler.Flush(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	return c.JSON(data)
+}
+
 // GetCurrentTelemetry handles requests for the most recent telemetry
 func (h *TelemetryHandler) GetCurrentTelemetry(c *fiber.Ctx) error {
-	data, err := h.repo.GetLatestTelemetry()
+	repo, err := h.datasetRepo(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	data, err := repo.GetLatestTelemetry()
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
 	}
@@ -60,7 +145,11 @@ func (h *TelemetryHandler) GetAnomalies(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid end_time"})
 	}
 
-	data, err := h.repo.GetAnomalies(startTime, endTime)
+	repo, err := h.datasetRepo(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	data, err := repo.GetAnomalies(startTime, endTime)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
 	}
@@ -68,7 +157,8 @@ func (h *TelemetryHandler) GetAnomalies(c *fiber.Ctx) error {
 	return c.JSON(data)
 }
 
-// GetAggregatedTelemetry handles requests for statistical telemetry data
+// GetAggregatedTelemetry handles requests for statistics of the telemetry in
+// a time range
 func (h *TelemetryHandler) GetAggregatedTelemetry(c *fiber.Ctx) error {
 	startTime, err := time.Parse(time.RFC3339, c.Query("start_time"))
 	if err != nil {
@@ -80,7 +170,28 @@ func (h *TelemetryHandler) GetAggregatedTelemetry(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid end_time"})
 	}
 
-	data, err := h.repo.GetAggregatedTelemetry(startTime, endTime)
+	// Statistics are a comma-separated list such as "min,max,p95,stddev,count"
+	stats := repository.DefaultStatistics
+	if list := c.Query("stats"); list != "" {
+		stats = nil
+		seen := make(map[string]bool)
+		for _, stat := range strings.Split(list, ",") {
+			stat = strings.ToLower(strings.TrimSpace(stat))
+			if stat != "" && !seen[stat] {
+				seen[stat] = true
+				stats = append(stats, stat)
+			}
+		}
+	}
+
+	repo, err := h.datasetRepo(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	data, err := repo.GetAggregatedTelemetry(startTime, endTime, stats)
+	if errors.Is(err, repository.ErrUnknownStatistic) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
 	}
@@ -88,6 +199,47 @@ func (h *TelemetryHandler) GetAggregatedTelemetry(c *fiber.Ctx) error {
 	return c.JSON(data)
 }
 
+// GetBucketedTelemetry handles requests for telemetry statistics in
+// consecutive time buckets. The bucket width is given either directly as an
+// interval or as the largest number of buckets wanted, from which a round
+// width is chosen.
+func (h *TelemetryHandler) GetBucketedTelemetry(c *fiber.Ctx) error {
+	startTime, err := time.Parse(time.RFC3339, c.Query("start_time"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid start_time"})
+	}
+
+	endTime, err := time.Parse(time.RFC3339, c.Query("end_time"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid end_time"})
+	}
+	if !endTime.After(startTime) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "end_time must be after start_time"})
+	}
+
+	width, err := bucketWidth(startTime, endTime, c.Query("interval"), c.Query("max_points"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	repo, err := h.datasetRepo(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	buckets, err := repo.GetBucketedTelemetry(startTime, endTime, width)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	return c.JSON(dto.BucketedTelemetry{
+		StartTime: startTime,
+		EndTime:   endTime,
+		Interval:  width.String(),
+		Seconds:   int64(width / time.Second),
+		Buckets:   buckets,
+	})
+}
+
 // GetLastTelemetry handles requests for the last N telemetry records
 func (h *TelemetryHandler) GetLastTelemetry(c *fiber.Ctx) error {
 	countParam := c.Params("count")
@@ -98,7 +250,11 @@ func (h *TelemetryHandler) GetLastTelemetry(c *fiber.Ctx) error {
 		})
 	}
 
-	data, err := h.repo.GetLastTelemetry(count)
+	repo, err := h.datasetRepo(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	data, err := repo.GetLastTelemetry(count)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
@@ -151,7 +307,11 @@ func (h *TelemetryHandler) GetPaginatedTelemetry(c *fiber.Ctx) error {
 		}
 	}
 
-	data, total, err := h.repo.GetPaginatedTelemetry(page, limit, startTime, endTime, anomalyFilter)
+	repo, err := h.datasetRepo(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	data, total, err := repo.GetPaginatedTelemetry(page, limit, startTime, endTime, anomalyFilter)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Could not fetch telemetry data",
@@ -168,3 +328,57 @@ func (h *TelemetryHandler) GetPaginatedTelemetry(c *fiber.Ctx) error {
 		"total_pages": totalPages,
 	})
 }
+
+// maxBuckets limits the size of a bucketed response
+const maxBuckets = 10000
+
+// Downsampled range queries return about points records per parameter
+const (
+	defaultPoints = 1000
+	maxPoints     = 100000
+)
+
+// roundWidths are the bucket widths chosen from max_points, so that bucket
+// boundaries fall on readable times
+var roundWidths = []time.Duration{
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour,
+	24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+// bucketWidth returns the bucket width from exactly one of an interval such
+// as "1m" or "1h", or a maximum number of buckets between startTime and endTime
+func bucketWidth(startTime, endTime time.Time, interval, maxPoints string) (time.Duration, error) {
+	span := endTime.Sub(startTime)
+
+	switch {
+	case interval != "" && maxPoints != "":
+		return 0, errors.New("Specify either interval or max_points, not both")
+
+	case interval != "":
+		width, err := time.ParseDuration(interval)
+		if err != nil || width < time.Second || width%time.Second != 0 {
+			return 0, errors.New("Invalid interval; use a whole number of seconds such as 30s, 1m or 1h")
+		}
+		if span/width >= maxBuckets {
+			return 0, fmt.Errorf("Interval too small; the range would exceed %d buckets", maxBuckets)
+		}
+		return width, nil
+
+	case maxPoints != "":
+		points, err := strconv.Atoi(maxPoints)
+		if err != nil || points < 1 || points > maxBuckets {
+			return 0, fmt.Errorf("Invalid max_points; must be between 1 and %d", maxBuckets)
+		}
+		// One extra bucket allows for the first one starting before startTime
+		for _, width := range roundWidths {
+			if int64(span/width)+1 <= int64(points) {
+				return width, nil
+			}
+		}
+		width := roundWidths[len(roundWidths)-1]
+		return (span/time.Duration(points)/width + 1) * width, nil
+	}
+	return 0, errors.New("Missing interval or max_points")
+}
@@ -6,7 +6,10 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/api/handlers"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/commanding"
 	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/repository"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/clock"
 	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/websocket"
 )
 
@@ -16,11 +19,15 @@ type APIServer struct {
 	port     string
 	app      *fiber.App
 	handlers *handlers.TelemetryHandler
+	clock    *handlers.ClockHandler
+	replay   *handlers.ReplayHandler
+	ingest   *handlers.IngestHandler
+	commands *handlers.CommandHandler
 	wsServer *websocket.WebSocketServer
 }
 
 // NewAPIServer creates a new API server instance
-func NewAPIServer(repo *repository.TelemetryRepository, port string, wsServer *websocket.WebSocketServer) *APIServer {
+func NewAPIServer(repo *repository.TelemetryRepository, port string, wsServer *websocket.WebSocketServer, correlator *clock.Correlator, replayer *telemetry.Replayer, telemetryServer *telemetry.TelemetryServer, commander *commanding.Commander) *APIServer {
 	app := fiber.New()
 	app.Use(cors.New())
 
@@ -29,6 +36,10 @@ func NewAPIServer(repo *repository.TelemetryRepository, port string, wsServer *w
 		port:     port,
 		app:      app,
 		handlers: handlers.NewTelemetryHandler(repo),
+		clock:    handlers.NewClockHandler(correlator),
+		replay:   handlers.NewReplayHandler(replayer),
+		ingest:   handlers.NewIngestHandler(telemetryServer),
+		commands: handlers.NewCommandHandler(commander, repo),
 		wsServer: wsServer,
 	}
 }
@@ -43,8 +54,16 @@ func (s *APIServer) Start() {
 	api.Get("/telemetry/current", s.handlers.GetCurrentTelemetry)
 	api.Get("/telemetry/anomalies", s.handlers.GetAnomalies)
 	api.Get("/telemetry/aggregate", s.handlers.GetAggregatedTelemetry)
+	api.Get("/telemetry/buckets", s.handlers.GetBucketedTelemetry)
 	api.Get("/telemetry/last/:count", s.handlers.GetLastTelemetry)
 	api.Get("/telemetry/paginated", s.handlers.GetPaginatedTelemetry)
+	api.Get("/clock/correlation", s.clock.GetCorrelation)
+	api.Post("/replay", s.replay.Replay)
+	api.Get("/replay/:id", s.replay.GetReplay)
+	api.Get("/ingest/stats", s.ingest.GetStats)
+	api.Post("/commands", s.commands.SendCommand)
+	api.Get("/commands", s.commands.GetCommands)
+	api.Get("/commands/dictionary", s.commands.GetDictionary)
 
 	// Setup WebSocket routes
 	s.wsServer.HandleWebSocket(s.app)
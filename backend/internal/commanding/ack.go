@@ -0,0 +1,63 @@
+package commanding
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// Stage codes carried in acknowledgement packets
+const (
+	AckStageReceived = 1
+	AckStageAccepted = 2
+	AckStageExecuted = 3
+)
+
+// ackSize is the length of an acknowledgement packet's data field
+const ackSize = 6
+
+// ErrShortAck is returned for acknowledgement packets without a full data field
+var ErrShortAck = errors.New("acknowledgement packet too short")
+
+// Ack reports the progress of one telecommand. Its data field holds the
+// packet ID and sequence control of the TC packet it refers to, the stage
+// code, and a result code that is zero on success.
+type Ack struct {
+	APID     uint16 // APID of the acknowledged TC packet
+	SeqCount uint16 // Sequence count of the acknowledged TC packet
+	Stage    uint8
+	Result   uint8
+}
+
+// ParseAck decodes an acknowledgement space packet
+func ParseAck(packet []byte) (Ack, error) {
+	if len(packet) < ccsds.PrimaryHeaderSize+ackSize {
+		return Ack{}, ErrShortAck
+	}
+	data := packet[ccsds.PrimaryHeaderSize:]
+
+	tc := ccsds.PrimaryHeader{
+		PacketID:      binary.BigEndian.Uint16(data[0:2]),
+		PacketSeqCtrl: binary.BigEndian.Uint16(data[2:4]),
+	}
+	return Ack{
+		APID:     tc.APID(),
+		SeqCount: tc.SeqCount(),
+		Stage:    data[4],
+		Result:   data[5],
+	}, nil
+}
+
+// stageName returns the verification stage an acknowledgement stage code reports
+func (a Ack) stageName() (string, bool) {
+	switch a.Stage {
+	case AckStageReceived:
+		return StageReceived, true
+	case AckStageAccepted:
+		return StageAccepted, true
+	case AckStageExecuted:
+		return StageExecuted, true
+	}
+	return "", false
+}
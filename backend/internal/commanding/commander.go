@@ -0,0 +1,154 @@
+package commanding
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/repository"
+)
+
+var (
+	// ErrMissingOperator is returned for commands that do not say who sent them
+	ErrMissingOperator = errors.New("operator is required")
+	// ErrUnknownSpacecraft is returned for commands addressed to a spacecraft that is not configured
+	ErrUnknownSpacecraft = errors.New("unknown spacecraft")
+)
+
+// Request is an operator's request to send a command
+type Request struct {
+	Command   string                 `json:"command"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Operator  string                 `json:"operator"`
+	// Spacecraft the command is addressed to, which alone can verify it;
+	// the first configured spacecraft when omitted
+	SpacecraftID *uint16 `json:"spacecraft_id"`
+}
+
+// Commander validates, encodes, uplinks and records telecommands
+type Commander struct {
+	repo       *repository.TelemetryRepository
+	verifier   *Verifier
+	dictionary *Dictionary
+	uplink     Uplink
+	spacecraft []config.SpacecraftCommandConfig
+	mu         sync.Mutex
+	seqCounts  map[uint16]uint16 // Next sequence count by TC APID
+}
+
+// NewCommander creates a commander using the settings in cfg
+func NewCommander(repo *repository.TelemetryRepository, verifier *Verifier, cfg *config.CommandConfig) *Commander {
+	dictionary := DefaultDictionary()
+	if cfg.DictionaryFile != "" {
+		var err error
+		dictionary, err = LoadDictionary(cfg.DictionaryFile)
+		if err != nil {
+			log.Fatal("Failed to load command dictionary:", err)
+		}
+	}
+
+	uplink, err := NewUDPUplink(cfg.UplinkAddr)
+	if err != nil {
+		log.Fatal("Failed to open command uplink:", err)
+	}
+
+	return &Commander{
+		repo:       repo,
+		verifier:   verifier,
+		dictionary: dictionary,
+		uplink:     uplink,
+		spacecraft: cfg.Spacecraft,
+		seqCounts:  make(map[uint16]uint16),
+	}
+}
+
+// Dictionary returns every command that may be sent
+func (c *Commander) Dictionary() []Definition {
+	return c.dictionary.Commands()
+}
+
+// Send validates and encodes a command, records it in the command history,
+// transmits it and starts its verification. A command that fails to
+// transmit is returned with its failed status and no error.
+func (c *Commander) Send(req Request) (models.CommandHistory, error) {
+	if req.Operator == "" {
+		return models.CommandHistory{}, ErrMissingOperator
+	}
+
+	target, ok := c.target(req.SpacecraftID)
+	if !ok {
+		return models.CommandHistory{}, fmt.Errorf("%w: %d", ErrUnknownSpacecraft, *req.SpacecraftID)
+	}
+
+	def, ok := c.dictionary.Lookup(req.Command)
+	if !ok {
+		return models.CommandHistory{}, fmt.Errorf("%w: %s", ErrUnknownCommand, req.Command)
+	}
+	data, err := def.Encode(req.Arguments)
+	if err != nil {
+		return models.CommandHistory{}, err
+	}
+
+	arguments, err := json.Marshal(req.Arguments)
+	if err != nil || req.Arguments == nil {
+		arguments = []byte("{}")
+	}
+
+	releasedAt := time.Now()
+	verification, err := c.verifier.plan(def, req.Arguments, releasedAt)
+	if err != nil {
+		return models.CommandHistory{}, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+
+	// Sequence counts identify the command in acknowledgements. They are
+	// taken only once the command is valid so that rejected commands leave
+	// no gaps.
+	c.mu.Lock()
+	seqCount := c.seqCounts[target.APID]
+	c.seqCounts[target.APID] = (seqCount + 1) & 0x3FFF
+	c.mu.Unlock()
+
+	cmd := models.CommandHistory{
+		Command:       def.Name,
+		Opcode:        def.Opcode,
+		Arguments:     string(arguments),
+		Operator:      req.Operator,
+		SpacecraftID:  target.ID,
+		APID:          target.APID,
+		SequenceCount: seqCount,
+		Packet:        EncodeTC(target.APID, seqCount, data),
+		Status:        models.CommandStatusReleased,
+		ReleasedAt:    releasedAt,
+	}
+	if err := c.repo.InsertCommand(&cmd); err != nil {
+		return cmd, err
+	}
+	c.verifier.track(verification, cmd)
+
+	err = c.uplink.Send(cmd.Packet)
+	if err != nil {
+		log.Printf("Failed to uplink command %s: %v", cmd.Command, err)
+	}
+	cmd = c.verifier.transmitted(verification, err, time.Now())
+
+	log.Printf("Command %s (seq %d) from %s: %s", cmd.Command, cmd.SequenceCount, cmd.Operator, cmd.Status)
+	return cmd, nil
+}
+
+// target returns the configured spacecraft a command is addressed to
+func (c *Commander) target(id *uint16) (config.SpacecraftCommandConfig, bool) {
+	if id == nil {
+		return c.spacecraft[0], true
+	}
+	for _, sc := range c.spacecraft {
+		if sc.ID == *id {
+			return sc, true
+		}
+	}
+	return config.SpacecraftCommandConfig{}, false
+}
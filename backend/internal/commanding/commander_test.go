@@ -0,0 +1,35 @@
+package commanding
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+)
+
+func TestCommanderSendRejectsWithoutSequenceCount(t *testing.T) {
+	// The condition names an argument the command lacks, so encoding
+	// succeeds and planning the verification fails
+	unverifiable := safeModeDefinition
+	unverifiable.Name = "UNVERIFIABLE"
+	unverifiable.Verification = []Check{{Stage: StageExecuted, Condition: "anomaly == $missing"}}
+
+	c := &Commander{
+		verifier:   newTestVerifier(),
+		dictionary: &Dictionary{commands: map[string]Definition{unverifiable.Name: unverifiable}},
+		spacecraft: []config.SpacecraftCommandConfig{primary, secondary},
+		seqCounts:  make(map[uint16]uint16),
+	}
+
+	_, err := c.Send(Request{
+		Command:   unverifiable.Name,
+		Arguments: map[string]interface{}{"enabled": true},
+		Operator:  "test",
+	})
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("error %v, want %v", err, ErrInvalidArgument)
+	}
+	if len(c.seqCounts) != 0 {
+		t.Errorf("sequence counts %v taken by a rejected command", c.seqCounts)
+	}
+}
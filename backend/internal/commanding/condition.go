@@ -0,0 +1,147 @@
+package commanding
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+)
+
+// telemetryParameters maps the parameter names usable in conditions to
+// their values in a telemetry record. Parameters a record does not report
+// are not available.
+var telemetryParameters = map[string]func(models.Telemetry) (float64, bool){
+	"temperature": func(t models.Telemetry) (float64, bool) { return float64(t.Temperature), true },
+	"battery":     func(t models.Telemetry) (float64, bool) { return float64(t.Battery), true },
+	"altitude":    func(t models.Telemetry) (float64, bool) { return float64(t.Altitude), true },
+	"signal":      func(t models.Telemetry) (float64, bool) { return float64(t.Signal), true },
+	"anomaly":     func(t models.Telemetry) (float64, bool) { return boolValue(t.Anomaly), true },
+	"heater_state": func(t models.Telemetry) (float64, bool) {
+		if t.HeaterOn == nil {
+			return 0, false
+		}
+		return boolValue(*t.HeaterOn), true
+	},
+	"safe_mode": func(t models.Telemetry) (float64, bool) {
+		if t.SafeMode == nil {
+			return 0, false
+		}
+		return boolValue(*t.SafeMode), true
+	},
+	"tx_interval_ms": func(t models.Telemetry) (float64, bool) {
+		if t.TxIntervalMs == nil {
+			return 0, false
+		}
+		return float64(*t.TxIntervalMs), true
+	},
+}
+
+// namedValues are the symbolic constants accepted on the right of a condition
+var namedValues = map[string]float64{
+	"ON":    1,
+	"OFF":   0,
+	"TRUE":  1,
+	"FALSE": 0,
+}
+
+// condition is a telemetry check such as "heater_state == ON". The value
+// may name a command argument as $name, bound when the command is sent.
+type condition struct {
+	text     string
+	param    string
+	op       string
+	value    float64
+	argument string
+}
+
+// parseCondition parses "parameter operator value"
+func parseCondition(text string) (condition, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 3 {
+		return condition{}, fmt.Errorf("condition %q must be \"parameter operator value\"", text)
+	}
+
+	c := condition{text: text, param: fields[0], op: fields[1]}
+	if _, ok := telemetryParameters[c.param]; !ok {
+		return condition{}, fmt.Errorf("condition %q: unknown parameter %s", text, c.param)
+	}
+	switch c.op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return condition{}, fmt.Errorf("condition %q: unknown operator %s", text, c.op)
+	}
+
+	value := fields[2]
+	if strings.HasPrefix(value, "$") {
+		c.argument = value[1:]
+		return c, nil
+	}
+	if v, ok := namedValues[strings.ToUpper(value)]; ok {
+		c.value = v
+		return c, nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return condition{}, fmt.Errorf("condition %q: invalid value %s", text, value)
+	}
+	c.value = v
+	return c, nil
+}
+
+// bind resolves an argument reference using the arguments of a command
+func (c condition) bind(def Definition, args map[string]interface{}) (condition, error) {
+	if c.argument == "" {
+		return c, nil
+	}
+
+	for _, arg := range def.Arguments {
+		if arg.Name != c.argument {
+			continue
+		}
+		switch v := args[arg.Name].(type) {
+		case bool:
+			c.value = boolValue(v)
+		case string:
+			c.value = float64(arg.Values[v])
+		case float64:
+			c.value = v
+		default:
+			return c, fmt.Errorf("condition %q: cannot compare argument %s", c.text, arg.Name)
+		}
+		c.text = strings.Replace(c.text, "$"+c.argument, fmt.Sprint(args[arg.Name]), 1)
+		c.argument = ""
+		return c, nil
+	}
+	return c, fmt.Errorf("condition %q: %s has no argument %s", c.text, def.Name, c.argument)
+}
+
+// eval reports whether a telemetry record satisfies the condition
+func (c condition) eval(t models.Telemetry) bool {
+	v, ok := telemetryParameters[c.param](t)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "==":
+		return v == c.value
+	case "!=":
+		return v != c.value
+	case "<":
+		return v < c.value
+	case "<=":
+		return v <= c.value
+	case ">":
+		return v > c.value
+	case ">=":
+		return v >= c.value
+	}
+	return false
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+package commanding
+
+// Opcodes of the built-in dictionary, which matches the simulator
+const (
+	OpcodeNoop        = 0x0001
+	OpcodeSetHeater   = 0x0002
+	OpcodeSetSafeMode = 0x0003
+	OpcodeSetTxRate   = 0x0004
+)
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+// DefaultDictionary returns the built-in command dictionary
+func DefaultDictionary() *Dictionary {
+	d, err := NewDictionary([]Definition{
+		{
+			Name:        "NOOP",
+			Opcode:      OpcodeNoop,
+			Description: "No operation; exercises the uplink",
+		},
+		{
+			Name:        "SET_HEATER",
+			Opcode:      OpcodeSetHeater,
+			Description: "Switch the battery heater",
+			Arguments: []Argument{
+				{Name: "state", Type: TypeEnum, Values: map[string]uint8{"OFF": 0, "ON": 1}},
+			},
+			Verification: []Check{
+				{Stage: StageExecuted, Condition: "heater_state == $state", Timeout: "10s"},
+			},
+		},
+		{
+			Name:        "SET_SAFE_MODE",
+			Opcode:      OpcodeSetSafeMode,
+			Description: "Enter or leave safe mode",
+			Arguments: []Argument{
+				{Name: "enabled", Type: TypeBool},
+			},
+			Verification: []Check{
+				{Stage: StageExecuted, Condition: "safe_mode == $enabled", Timeout: "10s"},
+			},
+		},
+		{
+			Name:        "SET_TX_RATE",
+			Opcode:      OpcodeSetTxRate,
+			Description: "Set the telemetry transmit interval",
+			Arguments: []Argument{
+				{Name: "interval_ms", Type: TypeUint16, Min: bound(100), Max: bound(60000)},
+			},
+			Verification: []Check{
+				{Stage: StageExecuted, Condition: "tx_interval_ms == $interval_ms"},
+			},
+		},
+	})
+	if err != nil {
+		panic(err) // The built-in dictionary is always valid
+	}
+	return d
+}
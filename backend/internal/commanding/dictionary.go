@@ -0,0 +1,269 @@
+package commanding
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// Argument types supported by the dictionary
+const (
+	TypeUint8   = "uint8"
+	TypeUint16  = "uint16"
+	TypeUint32  = "uint32"
+	TypeInt8    = "int8"
+	TypeInt16   = "int16"
+	TypeInt32   = "int32"
+	TypeFloat32 = "float32"
+	TypeBool    = "bool"
+	TypeEnum    = "enum" // Named values encoded as uint8
+)
+
+var (
+	// ErrUnknownCommand is returned for commands missing from the dictionary
+	ErrUnknownCommand = errors.New("unknown command")
+	// ErrInvalidArgument is returned when arguments do not match the dictionary
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// Argument describes one command argument
+type Argument struct {
+	Name        string           `json:"name"`
+	Type        string           `json:"type"`
+	Description string           `json:"description,omitempty"`
+	Min         *float64         `json:"min,omitempty"`    // Inclusive lower bound of numeric arguments
+	Max         *float64         `json:"max,omitempty"`    // Inclusive upper bound of numeric arguments
+	Values      map[string]uint8 `json:"values,omitempty"` // Named values of enum arguments
+}
+
+// Check customises how one verification stage is satisfied. Without a
+// condition the stage is satisfied only by an acknowledgement packet.
+type Check struct {
+	Stage     string `json:"stage"`               // received, accepted or executed
+	Condition string `json:"condition,omitempty"` // Telemetry condition, e.g. "battery > 50"
+	Timeout   string `json:"timeout,omitempty"`   // Window from transmission, e.g. "10s"
+}
+
+// Definition describes one command. Arguments are encoded in order, big
+// endian, after the opcode.
+type Definition struct {
+	Name         string     `json:"name"`
+	Opcode       uint16     `json:"opcode"`
+	Description  string     `json:"description,omitempty"`
+	Arguments    []Argument `json:"arguments"`
+	Verification []Check    `json:"verification,omitempty"`
+}
+
+// Dictionary holds every command that may be sent
+type Dictionary struct {
+	commands map[string]Definition
+}
+
+// NewDictionary creates a dictionary, checking that names and opcodes are
+// unique and that every argument type is known
+func NewDictionary(defs []Definition) (*Dictionary, error) {
+	d := &Dictionary{commands: make(map[string]Definition)}
+	opcodes := make(map[uint16]string)
+
+	for _, def := range defs {
+		if def.Name == "" {
+			return nil, fmt.Errorf("command with opcode %d has no name", def.Opcode)
+		}
+		if _, ok := d.commands[def.Name]; ok {
+			return nil, fmt.Errorf("duplicate command %s", def.Name)
+		}
+		if other, ok := opcodes[def.Opcode]; ok {
+			return nil, fmt.Errorf("commands %s and %s share opcode %d", other, def.Name, def.Opcode)
+		}
+		for _, arg := range def.Arguments {
+			if _, ok := argumentSize(arg.Type); !ok {
+				return nil, fmt.Errorf("command %s: argument %s has unknown type %q", def.Name, arg.Name, arg.Type)
+			}
+			if arg.Type == TypeEnum && len(arg.Values) == 0 {
+				return nil, fmt.Errorf("command %s: enum argument %s has no values", def.Name, arg.Name)
+			}
+		}
+		for _, check := range def.Verification {
+			if err := def.validateCheck(check); err != nil {
+				return nil, fmt.Errorf("command %s: %w", def.Name, err)
+			}
+		}
+		d.commands[def.Name] = def
+		opcodes[def.Opcode] = def.Name
+	}
+	return d, nil
+}
+
+// LoadDictionary reads a JSON array of command definitions
+func LoadDictionary(path string) (*Dictionary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []Definition
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return NewDictionary(defs)
+}
+
+// Lookup returns the definition of a command
+func (d *Dictionary) Lookup(name string) (Definition, bool) {
+	def, ok := d.commands[name]
+	return def, ok
+}
+
+// Commands returns every definition ordered by opcode
+func (d *Dictionary) Commands() []Definition {
+	defs := make([]Definition, 0, len(d.commands))
+	for _, def := range d.commands {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Opcode < defs[j].Opcode })
+	return defs
+}
+
+// Encode validates args against the definition and returns the application
+// data of the command: the opcode followed by each argument
+func (def Definition) Encode(args map[string]interface{}) ([]byte, error) {
+	for name := range args {
+		if !def.hasArgument(name) {
+			return nil, fmt.Errorf("%w: %s does not take %q", ErrInvalidArgument, def.Name, name)
+		}
+	}
+
+	data := binary.BigEndian.AppendUint16(nil, def.Opcode)
+	for _, arg := range def.Arguments {
+		value, ok := args[arg.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing %s", ErrInvalidArgument, arg.Name)
+		}
+		encoded, err := arg.encode(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %v", ErrInvalidArgument, arg.Name, err)
+		}
+		data = append(data, encoded...)
+	}
+	return data, nil
+}
+
+// validateCheck checks that a verification check can be evaluated
+func (def Definition) validateCheck(check Check) error {
+	if check.Stage != StageReceived && check.Stage != StageAccepted && check.Stage != StageExecuted {
+		return fmt.Errorf("cannot verify stage %q", check.Stage)
+	}
+	if check.Timeout != "" {
+		if d, err := time.ParseDuration(check.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", check.Timeout)
+		}
+	}
+	if check.Condition != "" {
+		c, err := parseCondition(check.Condition)
+		if err != nil {
+			return err
+		}
+		if c.argument != "" && !def.hasArgument(c.argument) {
+			return fmt.Errorf("condition %q: no argument %s", check.Condition, c.argument)
+		}
+	}
+	return nil
+}
+
+func (def Definition) hasArgument(name string) bool {
+	for _, arg := range def.Arguments {
+		if arg.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// argumentSize returns the encoded size of an argument type
+func argumentSize(t string) (int, bool) {
+	switch t {
+	case TypeUint8, TypeInt8, TypeBool, TypeEnum:
+		return 1, true
+	case TypeUint16, TypeInt16:
+		return 2, true
+	case TypeUint32, TypeInt32, TypeFloat32:
+		return 4, true
+	}
+	return 0, false
+}
+
+// encode checks a JSON-decoded value and encodes it big endian
+func (arg Argument) encode(value interface{}) ([]byte, error) {
+	switch arg.Type {
+	case TypeBool:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("must be a boolean")
+		}
+		if b {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+
+	case TypeEnum:
+		name, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string")
+		}
+		v, ok := arg.Values[name]
+		if !ok {
+			return nil, fmt.Errorf("has no value %q", name)
+		}
+		return []byte{v}, nil
+	}
+
+	n, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("must be a number")
+	}
+	if arg.Min != nil && n < *arg.Min {
+		return nil, fmt.Errorf("must be at least %g", *arg.Min)
+	}
+	if arg.Max != nil && n > *arg.Max {
+		return nil, fmt.Errorf("must be at most %g", *arg.Max)
+	}
+
+	if arg.Type == TypeFloat32 {
+		if math.Abs(n) > math.MaxFloat32 {
+			return nil, fmt.Errorf("is out of range for %s", arg.Type)
+		}
+		return binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(n))), nil
+	}
+
+	if n != math.Trunc(n) {
+		return nil, fmt.Errorf("must be an integer")
+	}
+	var lo, hi float64
+	switch arg.Type {
+	case TypeUint8:
+		lo, hi = 0, math.MaxUint8
+	case TypeUint16:
+		lo, hi = 0, math.MaxUint16
+	case TypeUint32:
+		lo, hi = 0, math.MaxUint32
+	case TypeInt8:
+		lo, hi = math.MinInt8, math.MaxInt8
+	case TypeInt16:
+		lo, hi = math.MinInt16, math.MaxInt16
+	case TypeInt32:
+		lo, hi = math.MinInt32, math.MaxInt32
+	}
+	if n < lo || n > hi {
+		return nil, fmt.Errorf("is out of range for %s", arg.Type)
+	}
+
+	size, _ := argumentSize(arg.Type)
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, uint32(int64(n)))
+	return buf[4-size:], nil
+}
@@ -0,0 +1,16 @@
+package commanding
+
+import (
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// EncodeTC builds a standalone TC space packet carrying the command's
+// application data followed by a CRC-16-CCITT packet error control field
+func EncodeTC(apid, seqCount uint16, data []byte) []byte {
+	packet := make([]byte, 0, ccsds.PrimaryHeaderSize+len(data)+ccsds.CRCSize)
+
+	// No secondary header
+	header := ccsds.NewPrimaryHeader(ccsds.TypeTC, false, apid, ccsds.SeqFlagStandalone, seqCount, len(data)+ccsds.CRCSize)
+	packet = append(header.Append(packet), data...)
+	return ccsds.AppendCRC(packet, packet)
+}
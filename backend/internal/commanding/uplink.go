@@ -0,0 +1,30 @@
+package commanding
+
+import (
+	"net"
+)
+
+// Uplink sends encoded TC packets towards the spacecraft
+type Uplink interface {
+	Send(packet []byte) error
+}
+
+// UDPUplink sends each TC packet as one UDP datagram
+type UDPUplink struct {
+	conn net.Conn
+}
+
+// NewUDPUplink creates an uplink sending to addr
+func NewUDPUplink(addr string) (*UDPUplink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPUplink{conn: conn}, nil
+}
+
+// Send transmits one packet
+func (u *UDPUplink) Send(packet []byte) error {
+	_, err := u.conn.Write(packet)
+	return err
+}
@@ -0,0 +1,425 @@
+package commanding
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/repository"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/websocket"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// Verification stages, in the order a command passes through them
+const (
+	StageReleased    = "released"
+	StageTransmitted = "transmitted"
+	StageReceived    = "received"
+	StageAccepted    = "accepted"
+	StageExecuted    = "executed"
+)
+
+// Stage statuses
+const (
+	StagePending  = "pending"
+	StagePassed   = "passed"
+	StageFailed   = "failed"
+	StageTimedOut = "timed_out"
+	StageSkipped  = "skipped" // Not evaluated because an earlier stage failed
+)
+
+// verifiedStages are the stages satisfied by the spacecraft
+var verifiedStages = []string{StageReceived, StageAccepted, StageExecuted}
+
+// StageResult is the state of one verification stage of a command
+type StageResult struct {
+	Stage       string     `json:"stage"`
+	Status      string     `json:"status"`
+	Condition   string     `json:"condition,omitempty"`
+	Deadline    *time.Time `json:"deadline,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Detail      string     `json:"detail,omitempty"`
+}
+
+// Update is broadcast to WebSocket clients whenever a command's verification progresses
+type Update struct {
+	CommandID     uint          `json:"command_id"`
+	Command       string        `json:"command"`
+	SequenceCount uint16        `json:"sequence_count"`
+	Status        string        `json:"status"`
+	Final         bool          `json:"final"`
+	Stages        []StageResult `json:"stages"`
+}
+
+// publication is a verification state waiting to be stored and broadcast
+type publication struct {
+	cmd    models.CommandHistory
+	update Update
+}
+
+// commandKey identifies a command in acknowledgements
+type commandKey struct {
+	spacecraftID uint16
+	apid         uint16 // TC APID
+	seqCount     uint16
+}
+
+// ackSource identifies the acknowledgement stream of one spacecraft
+type ackSource struct {
+	spacecraftID uint16
+	apid         uint16 // Acknowledgement APID
+}
+
+// tracked is a transmitted command awaiting verification
+type tracked struct {
+	cmd        models.CommandHistory
+	stages     []StageResult
+	timeouts   []time.Duration
+	conditions map[int]condition // Telemetry conditions by stage index
+}
+
+// Verifier follows each transmitted command through its verification
+// stages, which are satisfied by acknowledgement packets or telemetry
+// conditions and fail when their window expires
+type Verifier struct {
+	repo     *repository.TelemetryRepository
+	wsServer *websocket.WebSocketServer
+	ackAPIDs map[ackSource]uint16 // TC APID of each spacecraft by its acknowledgement stream
+	timeouts map[string]time.Duration
+	mu       sync.Mutex
+	pending  map[commandKey]*tracked
+
+	// Updates are stored and broadcast in order by one goroutine, so that
+	// a slow database or WebSocket hub cannot hold up ingest
+	queue []publication
+	wake  chan struct{}
+}
+
+// NewVerifier creates a verifier using the settings in cfg
+func NewVerifier(repo *repository.TelemetryRepository, wsServer *websocket.WebSocketServer, cfg *config.CommandConfig) *Verifier {
+	ackAPIDs := make(map[ackSource]uint16)
+	for _, sc := range cfg.Spacecraft {
+		ackAPIDs[ackSource{spacecraftID: sc.ID, apid: sc.AckAPID}] = sc.APID
+	}
+
+	return &Verifier{
+		repo:     repo,
+		wsServer: wsServer,
+		ackAPIDs: ackAPIDs,
+		timeouts: map[string]time.Duration{
+			StageReceived: cfg.ReceivedTimeout,
+			StageAccepted: cfg.AcceptedTimeout,
+			StageExecuted: cfg.ExecutedTimeout,
+		},
+		pending: make(map[commandKey]*tracked),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Start publishes verification updates and periodically times out stages
+// whose window has expired
+func (v *Verifier) Start() {
+	go v.publisher()
+	go func() {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			v.expire(now)
+		}
+	}()
+}
+
+// plan builds the verification stages of a command about to be released
+func (v *Verifier) plan(def Definition, args map[string]interface{}, releasedAt time.Time) (*tracked, error) {
+	t := &tracked{conditions: make(map[int]condition)}
+	t.stages = []StageResult{
+		{Stage: StageReleased, Status: StagePassed, CompletedAt: &releasedAt},
+		{Stage: StageTransmitted, Status: StagePending},
+	}
+	t.timeouts = []time.Duration{0, 0}
+
+	for _, stage := range verifiedStages {
+		result := StageResult{Stage: stage, Status: StagePending}
+		timeout := v.timeouts[stage]
+
+		for _, check := range def.Verification {
+			if check.Stage != stage {
+				continue
+			}
+			if check.Timeout != "" {
+				timeout, _ = time.ParseDuration(check.Timeout)
+			}
+			if check.Condition != "" {
+				c, err := parseCondition(check.Condition)
+				if err == nil {
+					c, err = c.bind(def, args)
+				}
+				if err != nil {
+					return nil, err
+				}
+				t.conditions[len(t.stages)] = c
+				result.Condition = c.text
+			}
+		}
+
+		t.stages = append(t.stages, result)
+		t.timeouts = append(t.timeouts, timeout)
+	}
+	return t, nil
+}
+
+// track starts following a released command. It is called before the
+// command is sent so that an early acknowledgement is not missed.
+func (v *Verifier) track(t *tracked, cmd models.CommandHistory) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	t.cmd = cmd
+	// A new command reusing a sequence count replaces any stale entry
+	v.pending[t.key()] = t
+}
+
+// transmitted records the outcome of sending a tracked command and opens the
+// windows of its remaining stages
+func (v *Verifier) transmitted(t *tracked, sendErr error, at time.Time) models.CommandHistory {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if sendErr != nil {
+		t.stages[1].Detail = sendErr.Error()
+		t.cmd.Error = sendErr.Error()
+		t.finish(1, StageFailed, at)
+	} else {
+		t.cmd.TransmittedAt = &at
+		if t.stages[1].Status == StagePending {
+			t.pass(1, at, "")
+		}
+		for i := 2; i < len(t.stages); i++ {
+			if t.stages[i].Status == StagePending {
+				deadline := at.Add(t.timeouts[i])
+				t.stages[i].Deadline = &deadline
+			}
+		}
+	}
+
+	if v.publish(t) && v.pending[t.key()] == t {
+		delete(v.pending, t.key())
+	}
+	return t.cmd
+}
+
+// HandleAck processes a packet received from a spacecraft if it is a
+// command acknowledgement and reports whether it was one
+func (v *Verifier) HandleAck(spacecraftID uint16, packet []byte, receivedAt time.Time) bool {
+	header, err := ccsds.ParsePrimaryHeader(packet)
+	if err != nil {
+		return false
+	}
+	tcAPID, ok := v.ackAPIDs[ackSource{spacecraftID: spacecraftID, apid: header.APID()}]
+	if !ok {
+		return false
+	}
+
+	ack, err := ParseAck(packet)
+	if err != nil {
+		log.Printf("Invalid command acknowledgement: %v", err)
+		return true
+	}
+	stage, ok := ack.stageName()
+	if !ok {
+		log.Printf("Command acknowledgement with unknown stage %d", ack.Stage)
+		return true
+	}
+
+	// A spacecraft only acknowledges its own commands
+	key := commandKey{spacecraftID: spacecraftID, apid: ack.APID, seqCount: ack.SeqCount}
+	v.mu.Lock()
+	t, ok := v.pending[key]
+	if !ok || ack.APID != tcAPID {
+		v.mu.Unlock()
+		log.Printf("Acknowledgement for unknown command (spacecraft %d, APID %d, seq %d)", spacecraftID, ack.APID, ack.SeqCount)
+		return true
+	}
+
+	i := t.index(stage)
+	if t.stages[i].Status != StagePending {
+		v.mu.Unlock()
+		return true
+	}
+	if ack.Result != 0 {
+		t.stages[i].Detail = fmt.Sprintf("failure code %d", ack.Result)
+		t.finish(i, StageFailed, receivedAt)
+	} else {
+		t.pass(i, receivedAt, "acknowledged")
+	}
+	if v.publish(t) {
+		delete(v.pending, key)
+	}
+	v.mu.Unlock()
+
+	return true
+}
+
+// ObserveTelemetry checks stored telemetry against pending stage conditions
+func (v *Verifier) ObserveTelemetry(telemetry models.Telemetry) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for key, t := range v.pending {
+		if t.cmd.TransmittedAt == nil || telemetry.ReceivedAt.Before(*t.cmd.TransmittedAt) {
+			continue
+		}
+		// Only the commanded spacecraft's telemetry can verify a command
+		if telemetry.SpacecraftID != t.cmd.SpacecraftID {
+			continue
+		}
+		changed := false
+		for i, c := range t.conditions {
+			if t.stages[i].Status == StagePending && c.eval(telemetry) {
+				t.pass(i, telemetry.ReceivedAt, "condition met")
+				changed = true
+			}
+		}
+		if changed && v.publish(t) {
+			delete(v.pending, key)
+		}
+	}
+}
+
+// expire times out every pending stage whose deadline has passed
+func (v *Verifier) expire(now time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for key, t := range v.pending {
+		for i, stage := range t.stages {
+			if stage.Status == StagePending && stage.Deadline != nil && now.After(*stage.Deadline) {
+				t.finish(i, StageTimedOut, now)
+				v.publish(t)
+				delete(v.pending, key)
+				break
+			}
+		}
+	}
+}
+
+// publish queues a command's verification state to be stored and
+// broadcast, reporting whether verification has finished. It is called with
+// v.mu held so that updates of one command are queued in order.
+func (v *Verifier) publish(t *tracked) bool {
+	update := t.update()
+	stages, err := json.Marshal(update.Stages)
+	if err != nil {
+		log.Printf("Failed to encode verification stages: %v", err)
+		return update.Final
+	}
+
+	t.cmd.Status = update.Status
+	t.cmd.Stages = string(stages)
+	if update.Final {
+		now := time.Now()
+		t.cmd.CompletedAt = &now
+	}
+
+	v.queue = append(v.queue, publication{cmd: t.cmd, update: update})
+	select {
+	case v.wake <- struct{}{}:
+	default:
+	}
+	return update.Final
+}
+
+// publisher stores and broadcasts queued updates, outside v.mu
+func (v *Verifier) publisher() {
+	for range v.wake {
+		v.mu.Lock()
+		queue := v.queue
+		v.queue = nil
+		v.mu.Unlock()
+
+		for _, p := range queue {
+			if err := v.repo.UpdateCommand(&p.cmd); err != nil {
+				log.Printf("Failed to update command history: %v", err)
+			}
+			v.wsServer.BroadcastCommand(p.update)
+
+			if p.update.Final {
+				log.Printf("Command %s (seq %d) verification finished: %s", p.cmd.Command, p.cmd.SequenceCount, p.cmd.Status)
+			}
+		}
+	}
+}
+
+// key returns the identity of the command in acknowledgements
+func (t *tracked) key() commandKey {
+	return commandKey{spacecraftID: t.cmd.SpacecraftID, apid: t.cmd.APID, seqCount: t.cmd.SequenceCount}
+}
+
+// index returns the position of a stage
+func (t *tracked) index(stage string) int {
+	for i, s := range t.stages {
+		if s.Stage == stage {
+			return i
+		}
+	}
+	return -1
+}
+
+// pass marks stage i passed. Earlier pending stages are implied by it.
+func (t *tracked) pass(i int, at time.Time, detail string) {
+	for j := 0; j < i; j++ {
+		if t.stages[j].Status == StagePending {
+			t.stages[j].Status = StagePassed
+			t.stages[j].CompletedAt = &at
+			t.stages[j].Detail = "implied by " + t.stages[i].Stage
+		}
+	}
+	t.stages[i].Status = StagePassed
+	t.stages[i].CompletedAt = &at
+	t.stages[i].Detail = detail
+}
+
+// finish ends verification at stage i with a failing status and skips the rest
+func (t *tracked) finish(i int, status string, at time.Time) {
+	t.stages[i].Status = status
+	t.stages[i].CompletedAt = &at
+	for j := i + 1; j < len(t.stages); j++ {
+		if t.stages[j].Status == StagePending {
+			t.stages[j].Status = StageSkipped
+		}
+	}
+}
+
+// update summarises the command's current verification state. The status
+// is that of the latest passed stage until a stage fails or times out.
+func (t *tracked) update() Update {
+	u := Update{
+		CommandID:     t.cmd.ID,
+		Command:       t.cmd.Command,
+		SequenceCount: t.cmd.SequenceCount,
+		Status:        models.CommandStatusReleased,
+		Stages:        append([]StageResult(nil), t.stages...),
+		Final:         true,
+	}
+
+	for _, s := range t.stages {
+		switch s.Status {
+		case StagePassed:
+			u.Status = s.Stage
+		case StageFailed:
+			u.Status = models.CommandStatusFailed
+			return u
+		case StageTimedOut:
+			u.Status = models.CommandStatusTimedOut
+			return u
+		case StagePending:
+			u.Final = false
+		}
+	}
+	return u
+}
@@ -0,0 +1,294 @@
+package commanding
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+var released = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// safeModeDefinition is a command whose execution is verified by telemetry
+// reflecting its argument, with a longer window for the accepted stage
+var safeModeDefinition = Definition{
+	Name:      "SET_SAFE_MODE",
+	Opcode:    OpcodeSetSafeMode,
+	Arguments: []Argument{{Name: "enabled", Type: TypeBool}},
+	Verification: []Check{
+		{Stage: StageAccepted, Timeout: "20s"},
+		{Stage: StageExecuted, Condition: "anomaly == $enabled"},
+	},
+}
+
+// Commandable spacecraft of the test verifier
+var (
+	primary   = config.SpacecraftCommandConfig{ID: 0, APID: 0x10, AckAPID: 0x11}
+	secondary = config.SpacecraftCommandConfig{ID: 7, APID: 0x20, AckAPID: 0x21}
+)
+
+func newTestVerifier() *Verifier {
+	return NewVerifier(nil, nil, &config.CommandConfig{
+		Spacecraft:      []config.SpacecraftCommandConfig{primary, secondary},
+		ReceivedTimeout: 5 * time.Second,
+		AcceptedTimeout: 10 * time.Second,
+		ExecutedTimeout: 30 * time.Second,
+	})
+}
+
+// statuses returns the status of every stage of a tracked command
+func statuses(t *tracked) []string {
+	var s []string
+	for _, stage := range t.stages {
+		s = append(s, stage.Status)
+	}
+	return s
+}
+
+func checkStatuses(t *testing.T, tr *tracked, want ...string) {
+	t.Helper()
+	got := statuses(tr)
+	if len(got) != len(want) {
+		t.Fatalf("stage statuses %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("stage statuses %v, want %v", got, want)
+		}
+	}
+}
+
+func TestVerifierPlan(t *testing.T) {
+	v := newTestVerifier()
+	tr, err := v.plan(safeModeDefinition, map[string]interface{}{"enabled": true}, released)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{StageReleased, StageTransmitted, StageReceived, StageAccepted, StageExecuted}
+	for i, stage := range tr.stages {
+		if stage.Stage != want[i] {
+			t.Fatalf("stage %d is %s, want %s", i, stage.Stage, want[i])
+		}
+	}
+	checkStatuses(t, tr, StagePassed, StagePending, StagePending, StagePending, StagePending)
+
+	// Windows default to the configured timeouts unless the dictionary sets one
+	if tr.timeouts[2] != 5*time.Second || tr.timeouts[3] != 20*time.Second || tr.timeouts[4] != 30*time.Second {
+		t.Errorf("stage timeouts %v, want 5s, 20s and 30s", tr.timeouts[2:])
+	}
+
+	// The condition is bound to the command's argument
+	if tr.stages[4].Condition != "anomaly == true" || tr.conditions[4].value != 1 {
+		t.Errorf("executed condition %q compares with %g", tr.stages[4].Condition, tr.conditions[4].value)
+	}
+}
+
+func TestVerifierPlanRejectsUnboundConditions(t *testing.T) {
+	def := safeModeDefinition
+	def.Verification = []Check{{Stage: StageExecuted, Condition: "battery > $level"}}
+	if _, err := newTestVerifier().plan(def, map[string]interface{}{"enabled": true}, released); err == nil {
+		t.Fatal("condition on a missing argument accepted")
+	}
+}
+
+func TestTrackedStatusTransitions(t *testing.T) {
+	v := newTestVerifier()
+	tr, _ := v.plan(safeModeDefinition, map[string]interface{}{"enabled": true}, released)
+	if u := tr.update(); u.Status != models.CommandStatusReleased || u.Final {
+		t.Fatalf("planned command is %s (final %v)", u.Status, u.Final)
+	}
+
+	tr.pass(1, released, "")
+	if u := tr.update(); u.Status != models.CommandStatusTransmitted || u.Final {
+		t.Fatalf("transmitted command is %s (final %v)", u.Status, u.Final)
+	}
+
+	// Execution implies the stages before it
+	tr.pass(4, released.Add(time.Second), "condition met")
+	checkStatuses(t, tr, StagePassed, StagePassed, StagePassed, StagePassed, StagePassed)
+	if tr.stages[2].Detail != "implied by executed" {
+		t.Errorf("received stage detail %q", tr.stages[2].Detail)
+	}
+	if u := tr.update(); u.Status != models.CommandStatusExecuted || !u.Final {
+		t.Fatalf("executed command is %s (final %v)", u.Status, u.Final)
+	}
+}
+
+func TestTrackedFailures(t *testing.T) {
+	v := newTestVerifier()
+	for status, want := range map[string]string{
+		StageFailed:   models.CommandStatusFailed,
+		StageTimedOut: models.CommandStatusTimedOut,
+	} {
+		tr, _ := v.plan(safeModeDefinition, map[string]interface{}{"enabled": false}, released)
+		tr.pass(2, released, "acknowledged")
+
+		// The stages after a failure are never evaluated
+		tr.finish(3, status, released.Add(time.Minute))
+		checkStatuses(t, tr, StagePassed, StagePassed, StagePassed, status, StageSkipped)
+		if u := tr.update(); u.Status != want || !u.Final {
+			t.Errorf("command %s at the accepted stage is %s (final %v), want %s", status, u.Status, u.Final, want)
+		}
+	}
+}
+
+// ackPacket encodes an acknowledgement of a TC sequence count
+func ackPacket(ackAPID, tcAPID, seq uint16, stage, result uint8) []byte {
+	packet := make([]byte, ccsds.PrimaryHeaderSize, ccsds.PrimaryHeaderSize+ackSize)
+	binary.BigEndian.PutUint16(packet[0:2], ackAPID)
+	packet = binary.BigEndian.AppendUint16(packet, 1<<12|tcAPID)
+	packet = binary.BigEndian.AppendUint16(packet, 3<<14|seq)
+	return append(packet, stage, result)
+}
+
+// transmit tracks a safe mode command to sc and records its transmission at released
+func transmit(t *testing.T, v *Verifier, sc config.SpacecraftCommandConfig, seq uint16) *tracked {
+	t.Helper()
+	tr, err := v.plan(safeModeDefinition, map[string]interface{}{"enabled": true}, released)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.track(tr, models.CommandHistory{Command: safeModeDefinition.Name, SpacecraftID: sc.ID, APID: sc.APID, SequenceCount: seq})
+	v.transmitted(tr, nil, released)
+	return tr
+}
+
+// lastUpdate returns the latest queued update
+func lastUpdate(t *testing.T, v *Verifier) Update {
+	t.Helper()
+	if len(v.queue) == 0 {
+		t.Fatal("no update queued")
+	}
+	return v.queue[len(v.queue)-1].update
+}
+
+func TestParseAck(t *testing.T) {
+	packet := ackPacket(0x11, 0x10, 42, AckStageAccepted, 7)
+	ack, err := ParseAck(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack != (Ack{APID: 0x10, SeqCount: 42, Stage: AckStageAccepted, Result: 7}) {
+		t.Errorf("parsed %+v", ack)
+	}
+	if stage, ok := ack.stageName(); !ok || stage != StageAccepted {
+		t.Errorf("stage code %d names %q", ack.Stage, stage)
+	}
+
+	if _, err := ParseAck(packet[:len(packet)-1]); !errors.Is(err, ErrShortAck) {
+		t.Errorf("short acknowledgement: error %v, want %v", err, ErrShortAck)
+	}
+}
+
+func TestVerifierHandleAck(t *testing.T) {
+	v := newTestVerifier()
+	tr := transmit(t, v, primary, 42)
+	at := released.Add(time.Second)
+
+	// Telemetry is not an acknowledgement
+	if v.HandleAck(0, ackPacket(0x12, 0x10, 42, AckStageReceived, 0), at) {
+		t.Error("packet on another APID handled as an acknowledgement")
+	}
+
+	// Acknowledgements of other commands change nothing
+	v.HandleAck(0, ackPacket(0x11, 0x10, 43, AckStageReceived, 0), at)
+	v.HandleAck(0, ackPacket(0x11, 0x20, 42, AckStageReceived, 0), at)
+	checkStatuses(t, tr, StagePassed, StagePassed, StagePending, StagePending, StagePending)
+
+	if !v.HandleAck(0, ackPacket(0x11, 0x10, 42, AckStageReceived, 0), at) {
+		t.Fatal("acknowledgement not handled")
+	}
+	checkStatuses(t, tr, StagePassed, StagePassed, StagePassed, StagePending, StagePending)
+	if u := lastUpdate(t, v); u.Status != models.CommandStatusReceived || u.Final {
+		t.Errorf("received command is %s (final %v)", u.Status, u.Final)
+	}
+
+	// A failure code ends verification
+	v.HandleAck(0, ackPacket(0x11, 0x10, 42, AckStageAccepted, 3), at)
+	checkStatuses(t, tr, StagePassed, StagePassed, StagePassed, StageFailed, StageSkipped)
+	if tr.stages[3].Detail != "failure code 3" {
+		t.Errorf("accepted stage detail %q", tr.stages[3].Detail)
+	}
+	if u := lastUpdate(t, v); u.Status != models.CommandStatusFailed || !u.Final {
+		t.Errorf("rejected command is %s (final %v)", u.Status, u.Final)
+	}
+	if len(v.pending) != 0 {
+		t.Error("finished command still pending")
+	}
+}
+
+func TestVerifierHandleAckBySpacecraft(t *testing.T) {
+	v := newTestVerifier()
+	first, second := transmit(t, v, primary, 5), transmit(t, v, secondary, 5)
+	at := released.Add(time.Second)
+
+	// Each spacecraft acknowledges on its own APIDs, which mean nothing
+	// when received from another spacecraft
+	if v.HandleAck(0, ackPacket(0x21, 0x20, 5, AckStageReceived, 0), at) {
+		t.Error("secondary acknowledgement handled from the primary spacecraft")
+	}
+	if !v.HandleAck(7, ackPacket(0x21, 0x20, 5, AckStageReceived, 0), at) {
+		t.Fatal("secondary acknowledgement not handled")
+	}
+	checkStatuses(t, first, StagePassed, StagePassed, StagePending, StagePending, StagePending)
+	checkStatuses(t, second, StagePassed, StagePassed, StagePassed, StagePending, StagePending)
+}
+
+func TestVerifierObserveTelemetry(t *testing.T) {
+	v := newTestVerifier()
+	tr := transmit(t, v, secondary, 42)
+
+	// Telemetry received before transmission, or from another spacecraft,
+	// cannot verify the command
+	v.ObserveTelemetry(models.Telemetry{SpacecraftID: 7, Anomaly: true, ReceivedAt: released.Add(-time.Second)})
+	v.ObserveTelemetry(models.Telemetry{SpacecraftID: 8, Anomaly: true, ReceivedAt: released.Add(time.Second)})
+	v.ObserveTelemetry(models.Telemetry{SpacecraftID: 7, Anomaly: false, ReceivedAt: released.Add(time.Second)})
+	checkStatuses(t, tr, StagePassed, StagePassed, StagePending, StagePending, StagePending)
+
+	v.ObserveTelemetry(models.Telemetry{SpacecraftID: 7, Anomaly: true, ReceivedAt: released.Add(2 * time.Second)})
+	checkStatuses(t, tr, StagePassed, StagePassed, StagePassed, StagePassed, StagePassed)
+	if u := lastUpdate(t, v); u.Status != models.CommandStatusExecuted || !u.Final {
+		t.Errorf("executed command is %s (final %v)", u.Status, u.Final)
+	}
+	if len(v.pending) != 0 {
+		t.Error("verified command still pending")
+	}
+}
+
+func TestVerifierExpire(t *testing.T) {
+	v := newTestVerifier()
+	tr := transmit(t, v, primary, 42)
+
+	// The received window is 5s from transmission
+	v.expire(released.Add(4 * time.Second))
+	checkStatuses(t, tr, StagePassed, StagePassed, StagePending, StagePending, StagePending)
+
+	v.expire(released.Add(6 * time.Second))
+	checkStatuses(t, tr, StagePassed, StagePassed, StageTimedOut, StageSkipped, StageSkipped)
+	if u := lastUpdate(t, v); u.Status != models.CommandStatusTimedOut || !u.Final {
+		t.Errorf("expired command is %s (final %v)", u.Status, u.Final)
+	}
+	if len(v.pending) != 0 {
+		t.Error("expired command still pending")
+	}
+}
+
+func TestVerifierSendFailure(t *testing.T) {
+	v := newTestVerifier()
+	tr, _ := v.plan(safeModeDefinition, map[string]interface{}{"enabled": true}, released)
+	v.track(tr, models.CommandHistory{SequenceCount: 42})
+
+	cmd := v.transmitted(tr, errors.New("uplink down"), released)
+	checkStatuses(t, tr, StagePassed, StageFailed, StageSkipped, StageSkipped, StageSkipped)
+	if cmd.Status != models.CommandStatusFailed || cmd.Error != "uplink down" || cmd.TransmittedAt != nil {
+		t.Errorf("command %s with error %q transmitted at %v", cmd.Status, cmd.Error, cmd.TransmittedAt)
+	}
+	if len(v.pending) != 0 {
+		t.Error("failed command still pending")
+	}
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,6 +19,60 @@ type DatabaseConfig struct {
 	SSLMode  string
 }
 
+// Ingest modes select what each received datagram contains
+const (
+	IngestModePacket = "packet" // Bare CCSDS space packets
+	IngestModeFrame  = "frame"  // CCSDS TM Transfer Frames
+	IngestModeCADU   = "cadu"   // Byte stream of ASM-synchronised, Reed-Solomon coded TM Transfer Frames
+)
+
+// IngestConfig holds settings for the telemetry ingest path
+type IngestConfig struct {
+	Mode                  string           // Default mode of UDP listeners
+	Listeners             []ListenerConfig // Ingest endpoints
+	ArchiveRaw            bool             // Store every received datagram in the raw packet archive
+	ArchiveQueueSize      int              // Datagrams waiting to be archived before new ones are dropped
+	ArchiveBatchSize      int              // Largest number of datagrams archived in one insert
+	TCPAPIDs              []uint16         // APIDs expected on TCP streams, used to resynchronise (empty accepts all)
+	FrameFECF             bool             // Transfer frames end with a Frame Error Control Field
+	FrameSpacecraftIDs    []uint16         // Spacecraft IDs to accept in frame mode (empty accepts all)
+	CRCAPIDs              []uint16         // APIDs whose packets end with a CRC-16-CCITT error control field
+	TimeCode              string           // Secondary header time code format: unix, cuc or cds
+	TimeEpoch             time.Time        // Epoch of CUC/CDS codes (zero selects the 1958 CCSDS epoch)
+	TimeScale             string           // utc or tai; empty selects the format default
+	CUCCoarseOctets       int              // CUC coarse time length
+	CUCFineOctets         int              // CUC fine time length
+	CDSDayOctets          int              // CDS day segment length (2 or 3)
+	CDSSubMsOctets        int              // CDS sub-millisecond segment length (0, 2 or 4)
+	LaunchTime            time.Time        // Onboard times before this are implausible
+	ClockWindow           int              // Number of time pairs used for the correlation fit
+	ClockFutureTolerance  time.Duration    // How far onboard time may lead receipt time
+	ClockJumpThreshold    time.Duration    // Largest accepted deviation from the correlation model
+	ClockCorrection       bool             // Replace onboard timestamps with correlated ground time
+	ReassemblyTimeout     time.Duration    // Maximum age of an incomplete segment group
+	ReassemblyMaxBytes    int              // Maximum size of a reassembled packet data field
+	ReassemblyMaxSegments int              // Maximum number of segments in one group
+	RSInterleave          int              // Reed-Solomon interleaving depth of coded frames
+	ASMTolerance          int              // Bit errors accepted in the sync marker while locked
+}
+
+// SpacecraftCommandConfig holds the command APIDs of one spacecraft
+type SpacecraftCommandConfig struct {
+	ID      uint16 // Spacecraft ID, matched against the spacecraft of telemetry
+	APID    uint16 // APID of its TC packets
+	AckAPID uint16 // APID of its command acknowledgement packets
+}
+
+// CommandConfig holds settings for the telecommand uplink
+type CommandConfig struct {
+	UplinkAddr      string                    // host:port that TC packets are sent to over UDP
+	Spacecraft      []SpacecraftCommandConfig // Commandable spacecraft; the first is the default
+	DictionaryFile  string                    // JSON command dictionary; empty selects the built-in dictionary
+	ReceivedTimeout time.Duration             // Default window for the received stage, from transmission
+	AcceptedTimeout time.Duration             // Default window for the accepted stage, from transmission
+	ExecutedTimeout time.Duration             // Default window for the executed stage, from transmission
+}
+
 func LoadConfig() *DatabaseConfig {
 	// Load database credentials from .env file if it exists
 	err := godotenv.Load()
@@ -33,9 +90,244 @@ func LoadConfig() *DatabaseConfig {
 	}
 }
 
+// LoadIngestConfig reads ingest settings from the environment.
+// It should be called after LoadConfig so that .env values are visible.
+func LoadIngestConfig() *IngestConfig {
+	mode := getEnv("INGEST_MODE", IngestModePacket)
+	if !validMode(mode) {
+		log.Printf("Invalid value for INGEST_MODE (%q), using default %s", mode, IngestModePacket)
+		mode = IngestModePacket
+	}
+
+	// TCP_PORT predates INGEST_LISTENERS and is still honoured. It joins the
+	// list so that it is checked for duplicates with the other listeners.
+	value := getEnv("INGEST_LISTENERS", "udp://:8089")
+	if port := getEnv("TCP_PORT", ""); port != "" {
+		value += ",tcp://:" + port
+	}
+	listeners := parseListeners(value, mode)
+
+	return &IngestConfig{
+		Mode:                  mode,
+		Listeners:             listeners,
+		ArchiveRaw:            getEnvBool("ARCHIVE_RAW", true),
+		ArchiveQueueSize:      getEnvInt("ARCHIVE_QUEUE_SIZE", 10000),
+		ArchiveBatchSize:      getEnvInt("ARCHIVE_BATCH_SIZE", 500),
+		TCPAPIDs:              getEnvUint16List("TCP_APIDS"),
+		FrameFECF:             getEnvBool("FRAME_FECF", true),
+		FrameSpacecraftIDs:    getEnvUint16List("FRAME_SPACECRAFT_IDS"),
+		CRCAPIDs:              getEnvUint16List("CRC_APIDS"),
+		TimeCode:              getEnv("TIME_CODE", "unix"),
+		TimeEpoch:             getEnvTime("TIME_EPOCH"),
+		TimeScale:             getEnv("TIME_SCALE", ""),
+		CUCCoarseOctets:       getEnvInt("CUC_COARSE_OCTETS", 4),
+		CUCFineOctets:         getEnvInt("CUC_FINE_OCTETS", 2),
+		CDSDayOctets:          getEnvInt("CDS_DAY_OCTETS", 2),
+		CDSSubMsOctets:        getEnvInt("CDS_SUBMS_OCTETS", 0),
+		LaunchTime:            getEnvTime("LAUNCH_TIME"),
+		ClockWindow:           getEnvInt("CLOCK_WINDOW", 600),
+		ClockFutureTolerance:  getEnvDuration("CLOCK_FUTURE_TOLERANCE", 5*time.Second),
+		ClockJumpThreshold:    getEnvDuration("CLOCK_JUMP_THRESHOLD", 5*time.Second),
+		ClockCorrection:       getEnvBool("CLOCK_CORRECTION", false),
+		ReassemblyTimeout:     getEnvDuration("REASSEMBLY_TIMEOUT", 30*time.Second),
+		ReassemblyMaxBytes:    getEnvInt("REASSEMBLY_MAX_BYTES", 65536),
+		ReassemblyMaxSegments: getEnvInt("REASSEMBLY_MAX_SEGMENTS", 256),
+		RSInterleave:          getEnvInt("RS_INTERLEAVE", 1),
+		ASMTolerance:          getEnvInt("ASM_BIT_TOLERANCE", 2),
+	}
+}
+
+// LoadCommandConfig reads telecommand settings from the environment.
+// It should be called after LoadConfig so that .env values are visible.
+func LoadCommandConfig() *CommandConfig {
+	apid := uint16(0x010)
+	if apids := getEnvUint16List("COMMAND_APID"); len(apids) > 0 {
+		apid = apids[0] & 0x07FF
+	}
+
+	ackAPID := uint16(0x011)
+	if apids := getEnvUint16List("ACK_APID"); len(apids) > 0 {
+		ackAPID = apids[0] & 0x07FF
+	}
+
+	// COMMAND_APID and ACK_APID describe spacecraft 0 unless a list is given
+	spacecraft := []SpacecraftCommandConfig{{APID: apid, AckAPID: ackAPID}}
+	if value := getEnv("COMMAND_SPACECRAFT", ""); value != "" {
+		if list := parseCommandSpacecraft(value); len(list) > 0 {
+			spacecraft = list
+		}
+	}
+
+	return &CommandConfig{
+		UplinkAddr:      getEnv("UPLINK_ADDR", "localhost:8090"),
+		Spacecraft:      spacecraft,
+		DictionaryFile:  getEnv("COMMAND_DICTIONARY", ""),
+		ReceivedTimeout: getEnvDuration("VERIFY_RECEIVED_TIMEOUT", 5*time.Second),
+		AcceptedTimeout: getEnvDuration("VERIFY_ACCEPTED_TIMEOUT", 10*time.Second),
+		ExecutedTimeout: getEnvDuration("VERIFY_EXECUTED_TIMEOUT", 30*time.Second),
+	}
+}
+
+// parseCommandSpacecraft parses a comma-separated list of
+// spacecraft:command_apid:ack_apid entries such as "1:0x10:0x11,2:32:33".
+// Every spacecraft and APID may appear only once, so that commands and
+// acknowledgements are never ambiguous. Invalid entries are logged and skipped.
+func parseCommandSpacecraft(value string) []SpacecraftCommandConfig {
+	var list []SpacecraftCommandConfig
+	ids := make(map[uint16]bool)
+	apids := make(map[uint16]bool)
+	for _, entry := range strings.Split(value, ",") {
+		fields := strings.Split(strings.TrimSpace(entry), ":")
+		if len(fields) != 3 {
+			log.Printf("Invalid entry in COMMAND_SPACECRAFT (%q), skipping", entry)
+			continue
+		}
+
+		var numbers [3]uint16
+		valid := true
+		for i, field := range fields {
+			n, err := strconv.ParseUint(strings.TrimSpace(field), 0, 16)
+			if err != nil || (i > 0 && n > 0x07FF) {
+				valid = false
+				break
+			}
+			numbers[i] = uint16(n)
+		}
+		if !valid {
+			log.Printf("Invalid entry in COMMAND_SPACECRAFT (%q), skipping", entry)
+			continue
+		}
+		if ids[numbers[0]] {
+			log.Printf("Duplicate spacecraft in COMMAND_SPACECRAFT (%q), skipping", entry)
+			continue
+		}
+		if apids[numbers[1]] || apids[numbers[2]] || numbers[1] == numbers[2] {
+			log.Printf("Duplicate APID in COMMAND_SPACECRAFT (%q), skipping", entry)
+			continue
+		}
+		ids[numbers[0]] = true
+		apids[numbers[1]], apids[numbers[2]] = true, true
+		list = append(list, SpacecraftCommandConfig{ID: numbers[0], APID: numbers[1], AckAPID: numbers[2]})
+	}
+	return list
+}
+
+// Unidentified returns the commandable spacecraft whose packets no listener
+// attributes to them, whose commands could therefore never be verified.
+// Transfer frames carry their spacecraft ID, but a packet listener tags
+// everything it receives with its spacecraft option, so a fleet needs frame
+// or CADU ingest or a packet listener per spacecraft.
+func (c *CommandConfig) Unidentified(ingest *IngestConfig) []uint16 {
+	var ids []uint16
+	for _, sc := range c.Spacecraft {
+		if !ingest.identifies(sc.ID) {
+			ids = append(ids, sc.ID)
+		}
+	}
+	return ids
+}
+
+// identifies reports whether some listener attributes packets to spacecraftID
+func (c *IngestConfig) identifies(spacecraftID uint16) bool {
+	for _, l := range c.Listeners {
+		if l.Mode == IngestModePacket {
+			if l.SpacecraftID == spacecraftID {
+				return true
+			}
+			continue
+		}
+		if len(c.FrameSpacecraftIDs) == 0 {
+			return true
+		}
+		for _, id := range c.FrameSpacecraftIDs {
+			if id == spacecraftID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validMode reports whether mode is a known ingest mode
+func validMode(mode string) bool {
+	return mode == IngestModePacket || mode == IngestModeFrame || mode == IngestModeCADU
+}
+
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+func getEnvTime(key string) time.Time {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default", key, value)
+		return time.Time{}
+	}
+	return t
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+// getEnvUint16List parses a comma-separated list of numbers. Hex values
+// with a 0x prefix are accepted.
+func getEnvUint16List(key string) []uint16 {
+	value, exists := os.LookupEnv(key)
+	if !exists || strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	var list []uint16
+	for _, field := range strings.Split(value, ",") {
+		n, err := strconv.ParseUint(strings.TrimSpace(field), 0, 16)
+		if err != nil {
+			log.Printf("Invalid entry in %s (%q), skipping", key, field)
+			continue
+		}
+		list = append(list, uint16(n))
+	}
+	return list
+}
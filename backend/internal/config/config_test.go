@@ -0,0 +1,53 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommandSpacecraft(t *testing.T) {
+	got := parseCommandSpacecraft(" 1:0x10:0x11, 2:32:33 ")
+	want := []SpacecraftCommandConfig{{ID: 1, APID: 0x10, AckAPID: 0x11}, {ID: 2, APID: 32, AckAPID: 33}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsed %+v, want %+v", got, want)
+	}
+}
+
+func TestParseCommandSpacecraftRejects(t *testing.T) {
+	for _, value := range []string{
+		"1:16",            // Missing the acknowledgement APID
+		"1:16:0x800",      // APID out of range
+		"70000:16:17",     // Spacecraft ID out of range
+		"1:16:17,1:32:33", // Spacecraft listed twice
+		"1:16:17,2:16:33", // TC APID shared
+		"1:16:17,2:32:17", // Acknowledgement APID shared
+		"1:16:17,2:17:33", // One spacecraft's acknowledgements on another's TC APID
+		"1:16:16",         // TC and acknowledgements on one APID
+	} {
+		// Only the first entry of each list is valid
+		got := parseCommandSpacecraft(value)
+		if len(got) > 1 || (len(got) == 1 && got[0] != (SpacecraftCommandConfig{ID: 1, APID: 16, AckAPID: 17})) {
+			t.Errorf("%s: parsed %+v", value, got)
+		}
+	}
+}
+
+func TestCommandConfigUnidentified(t *testing.T) {
+	fleet := &CommandConfig{Spacecraft: []SpacecraftCommandConfig{{ID: 1, APID: 16, AckAPID: 17}, {ID: 2, APID: 32, AckAPID: 33}}}
+
+	// A packet listener tags everything it receives with one spacecraft
+	packets := &IngestConfig{Listeners: parseListeners("udp://:8089?spacecraft=1", IngestModePacket)}
+	if ids := fleet.Unidentified(packets); !reflect.DeepEqual(ids, []uint16{2}) {
+		t.Errorf("packet ingest leaves %v unidentified, want [2]", ids)
+	}
+
+	// Frames name their spacecraft, unless frame ingest is limited to others
+	frames := &IngestConfig{Listeners: parseListeners("udp://:8089?mode=frame", IngestModePacket)}
+	if ids := fleet.Unidentified(frames); len(ids) != 0 {
+		t.Errorf("frame ingest leaves %v unidentified", ids)
+	}
+	frames.FrameSpacecraftIDs = []uint16{2}
+	if ids := fleet.Unidentified(frames); !reflect.DeepEqual(ids, []uint16{1}) {
+		t.Errorf("frame ingest of spacecraft 2 leaves %v unidentified, want [1]", ids)
+	}
+}
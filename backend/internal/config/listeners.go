@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ListenerConfig describes one ingest endpoint
+type ListenerConfig struct {
+	Name         string       // Label used in logs and statistics
+	Protocol     string       // udp or tcp
+	Address      string       // Local or multicast group address; empty listens on all addresses
+	Port         string       // Port to listen on
+	Interface    string       // Network interface for multicast membership or binding (optional)
+	Mode         string       // Ingest mode; TCP streams carry packets or CADUs
+	SpacecraftID uint16       // Spacecraft tag applied to packets that do not identify their spacecraft
+	Stream       string       // Stream tag applied to every packet
+	Allow        []*net.IPNet // Source networks allowed to send (empty allows all)
+	Rate         float64      // Packets per second accepted from each source (0 disables limiting)
+	Burst        int          // Token bucket size of each source (0 defaults to one second of Rate)
+	HMACKey      []byte       // Shared key of the HMAC-SHA256 trailer on every datagram (optional)
+}
+
+// Multicast reports whether the listener joins a multicast group
+func (l ListenerConfig) Multicast() bool {
+	ip := net.ParseIP(l.Address)
+	return ip != nil && ip.IsMulticast()
+}
+
+// parseListeners parses a comma-separated list of listener URLs such as
+//
+//	udp://:8089
+//	udp://239.1.2.3:9000?iface=eth1&spacecraft=42&stream=vc1&mode=frame
+//	tcp://:8100?stream=gs-relay&name=relay
+//	udp://:8089?allow=10.0.0.0/8&allow=192.168.1.7&rate=50&burst=100&hmac_key_env=INGEST_KEY
+//
+// hmac_key_env names the environment variable holding the shared key, so
+// that keys stay out of the listener list.
+// Invalid entries, and entries repeating the endpoint or name of an earlier
+// one, are logged and skipped.
+func parseListeners(value, defaultMode string) []ListenerConfig {
+	var listeners []ListenerConfig
+	endpoints := make(map[string]bool)
+	names := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		l, err := parseListener(entry, defaultMode)
+		if err != nil {
+			log.Printf("Invalid listener %q: %v, skipping", entry, err)
+			continue
+		}
+
+		endpoint := l.Protocol + "://" + net.JoinHostPort(l.Address, l.Port)
+		if endpoints[endpoint] {
+			log.Printf("Duplicate listener %q: %s is already configured, skipping", entry, endpoint)
+			continue
+		}
+		if names[l.Name] {
+			log.Printf("Duplicate listener %q: name %s is already used, skipping", entry, l.Name)
+			continue
+		}
+		endpoints[endpoint] = true
+		names[l.Name] = true
+		listeners = append(listeners, l)
+	}
+	return listeners
+}
+
+func parseListener(entry, defaultMode string) (ListenerConfig, error) {
+	u, err := url.Parse(entry)
+	if err != nil {
+		return ListenerConfig{}, err
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return ListenerConfig{}, fmt.Errorf("unsupported protocol %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		return ListenerConfig{}, fmt.Errorf("missing port")
+	}
+	if port, err := strconv.Atoi(u.Port()); err != nil || port < 1 || port > 65535 {
+		return ListenerConfig{}, fmt.Errorf("port %s out of range 1-65535", u.Port())
+	}
+
+	q := u.Query()
+	l := ListenerConfig{
+		Name:      q.Get("name"),
+		Protocol:  u.Scheme,
+		Address:   u.Hostname(),
+		Port:      u.Port(),
+		Interface: q.Get("iface"),
+		Mode:      q.Get("mode"),
+		Stream:    q.Get("stream"),
+	}
+	if l.Name == "" {
+		l.Name = u.Scheme + "://" + u.Host
+	}
+
+	if l.Mode == "" {
+		l.Mode = defaultMode
+		if l.Protocol == "tcp" && l.Mode == IngestModeFrame {
+			l.Mode = IngestModePacket
+		}
+	}
+	if !validMode(l.Mode) {
+		return ListenerConfig{}, fmt.Errorf("unknown mode %q", l.Mode)
+	}
+	if l.Protocol == "tcp" && l.Mode == IngestModeFrame {
+		// A stream has no frame boundaries without sync markers
+		return ListenerConfig{}, fmt.Errorf("tcp supports packet or cadu mode")
+	}
+
+	if s := q.Get("spacecraft"); s != "" {
+		id, err := strconv.ParseUint(s, 0, 16)
+		if err != nil {
+			return ListenerConfig{}, fmt.Errorf("invalid spacecraft %q", s)
+		}
+		l.SpacecraftID = uint16(id)
+	}
+
+	for _, a := range q["allow"] {
+		network, err := parseNetwork(a)
+		if err != nil {
+			return ListenerConfig{}, err
+		}
+		l.Allow = append(l.Allow, network)
+	}
+
+	if s := q.Get("rate"); s != "" {
+		l.Rate, err = strconv.ParseFloat(s, 64)
+		if err != nil || l.Rate < 0 {
+			return ListenerConfig{}, fmt.Errorf("invalid rate %q", s)
+		}
+	}
+	if s := q.Get("burst"); s != "" {
+		l.Burst, err = strconv.Atoi(s)
+		if err != nil || l.Burst < 0 {
+			return ListenerConfig{}, fmt.Errorf("invalid burst %q", s)
+		}
+	}
+
+	if name := q.Get("hmac_key_env"); name != "" {
+		if l.Protocol != "udp" {
+			return ListenerConfig{}, fmt.Errorf("hmac requires udp")
+		}
+		key := os.Getenv(name)
+		if key == "" {
+			return ListenerConfig{}, fmt.Errorf("HMAC key variable %s is not set", name)
+		}
+		l.HMACKey = []byte(key)
+	}
+
+	if l.Multicast() && l.Protocol != "udp" {
+		return ListenerConfig{}, fmt.Errorf("multicast requires udp")
+	}
+
+	return l, nil
+}
+
+// parseNetwork parses a CIDR block or a single address
+func parseNetwork(value string) (*net.IPNet, error) {
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		return network, nil
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid allow entry %q", value)
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
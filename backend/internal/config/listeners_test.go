@@ -0,0 +1,139 @@
+package config
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParseListeners(t *testing.T) {
+	listeners := parseListeners(
+		" udp://:8089, udp://239.1.2.3:9000?iface=eth1&spacecraft=0x2A&stream=vc1&mode=frame ,,tcp://:8100?stream=gs-relay&name=relay",
+		IngestModePacket)
+	if len(listeners) != 3 {
+		t.Fatalf("parsed %d listeners, want 3", len(listeners))
+	}
+
+	want := []ListenerConfig{
+		{Name: "udp://:8089", Protocol: "udp", Port: "8089", Mode: IngestModePacket},
+		{Name: "udp://239.1.2.3:9000", Protocol: "udp", Address: "239.1.2.3", Port: "9000", Interface: "eth1", Mode: IngestModeFrame, SpacecraftID: 42, Stream: "vc1"},
+		{Name: "relay", Protocol: "tcp", Port: "8100", Mode: IngestModePacket, Stream: "gs-relay"},
+	}
+	for i, l := range listeners {
+		if !reflect.DeepEqual(l, want[i]) {
+			t.Errorf("listener %d is %+v, want %+v", i, l, want[i])
+		}
+	}
+	if !listeners[1].Multicast() || listeners[0].Multicast() {
+		t.Error("only the group address should be multicast")
+	}
+}
+
+func TestParseListenerSecurity(t *testing.T) {
+	t.Setenv("TEST_INGEST_KEY", "secret")
+	l, err := parseListener("udp://:8089?allow=10.0.0.0/8&allow=192.168.1.7&rate=50&burst=100&hmac_key_env=TEST_INGEST_KEY", IngestModePacket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A single address is allowed as a network of one
+	if len(l.Allow) != 2 || l.Allow[0].String() != "10.0.0.0/8" || l.Allow[1].String() != "192.168.1.7/32" {
+		t.Errorf("allow-list %v, want 10.0.0.0/8 and 192.168.1.7/32", l.Allow)
+	}
+	if !l.Allow[1].Contains(net.ParseIP("192.168.1.7")) || l.Allow[1].Contains(net.ParseIP("192.168.1.8")) {
+		t.Error("single address entry matches the wrong sources")
+	}
+	if l.Rate != 50 || l.Burst != 100 || string(l.HMACKey) != "secret" {
+		t.Errorf("rate %g, burst %d and key %q, want 50, 100 and the key from the environment", l.Rate, l.Burst, l.HMACKey)
+	}
+
+	for _, entry := range []string{
+		"udp://:8089?allow=10.0.0.0/33",
+		"udp://:8089?allow=gateway",
+		"udp://:8089?rate=-1",
+		"udp://:8089?burst=many",
+		"udp://:8089?hmac_key_env=TEST_INGEST_KEY_UNSET",
+		"tcp://:8100?hmac_key_env=TEST_INGEST_KEY",
+	} {
+		if _, err := parseListener(entry, IngestModePacket); err == nil {
+			t.Errorf("%s: accepted", entry)
+		}
+	}
+}
+
+func TestParseListenerModes(t *testing.T) {
+	// Listeners default to the ingest mode, except that a TCP stream cannot
+	// carry bare frames
+	for entry, want := range map[string]string{
+		"udp://:8089":             IngestModeFrame,
+		"udp://:8089?mode=packet": IngestModePacket,
+		"udp://:8089?mode=cadu":   IngestModeCADU,
+		"tcp://:8100":             IngestModePacket,
+		"tcp://:8100?mode=cadu":   IngestModeCADU,
+	} {
+		l, err := parseListener(entry, IngestModeFrame)
+		if err != nil {
+			t.Errorf("%s: %v", entry, err)
+		} else if l.Mode != want {
+			t.Errorf("%s: mode %s, want %s", entry, l.Mode, want)
+		}
+	}
+}
+
+func TestParseListenerRejects(t *testing.T) {
+	for _, entry := range []string{
+		"sctp://:8089",
+		"udp://localhost",
+		"udp://:port",
+		"udp://:0",
+		"udp://:65536",
+		"tcp://:123456789012",
+		"udp://:8089?mode=ax25",
+		"tcp://:8100?mode=frame",
+		"udp://:8089?spacecraft=70000",
+		"tcp://239.1.2.3:9000",
+	} {
+		if l, err := parseListener(entry, IngestModePacket); err == nil {
+			t.Errorf("%s: accepted as %+v", entry, l)
+		}
+	}
+
+	// Invalid entries are skipped without losing the valid ones
+	if listeners := parseListeners("udp://:8089?mode=ax25,udp://:8090", IngestModePacket); len(listeners) != 1 || listeners[0].Port != "8090" {
+		t.Errorf("parsed %+v, want only the listener on port 8090", listeners)
+	}
+}
+
+func TestParseListenersSkipsDuplicates(t *testing.T) {
+	listeners := parseListeners(
+		"udp://:8089,udp://:8089?stream=vc1,tcp://:8089,udp://:8090?name=tcp://:8089,udp://:8091?name=a,udp://:8092?name=a",
+		IngestModePacket)
+
+	// The same port may be used once per protocol, and each name once
+	var got []string
+	for _, l := range listeners {
+		got = append(got, l.Name)
+	}
+	if want := []string{"udp://:8089", "tcp://:8089", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+}
+
+func TestLoadIngestConfigTCPPort(t *testing.T) {
+	t.Setenv("INGEST_LISTENERS", "udp://:8089,tcp://:8100")
+	t.Setenv("TCP_PORT", "8100")
+	if listeners := LoadIngestConfig().Listeners; len(listeners) != 2 {
+		t.Errorf("TCP_PORT repeating a listener gave %+v", listeners)
+	}
+
+	t.Setenv("TCP_PORT", "8101")
+	listeners := LoadIngestConfig().Listeners
+	if len(listeners) != 3 || listeners[2].Protocol != "tcp" || listeners[2].Port != "8101" {
+		t.Errorf("TCP_PORT gave %+v, want a third listener on tcp port 8101", listeners)
+	}
+
+	t.Setenv("TCP_PORT", "70000")
+	if listeners := LoadIngestConfig().Listeners; len(listeners) != 2 {
+		t.Errorf("TCP_PORT out of range gave %+v", listeners)
+	}
+}
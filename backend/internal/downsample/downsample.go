@@ -0,0 +1,67 @@
+// Package downsample reduces a time-ordered telemetry series to a number of
+// samples that still shows its shape. Samplers consume records one at a time
+// and hold at most a couple of buckets, so a long range can be reduced while
+// it is read from the database. Anomalous records are always kept.
+package downsample
+
+import (
+	"fmt"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+)
+
+// Downsampling methods
+const (
+	MethodLTTB   = "lttb"   // Largest-Triangle-Three-Buckets
+	MethodMinMax = "minmax" // Minimum and maximum of each bucket
+)
+
+// parameters is the number of telemetry values a sample is chosen for. The
+// records kept are the union of the choices for each value, so every chart
+// drawn from the result keeps its shape.
+const parameters = 4
+
+// Sampler consumes records in time order and emits the ones to keep, also
+// in time order
+type Sampler interface {
+	// Add consumes the next record
+	Add(t models.Telemetry) error
+	// Flush emits what remains once the last record has been added
+	Flush() error
+}
+
+// New creates a sampler for method that reduces about total records to
+// about points samples per parameter and passes them to emit
+func New(method string, total, points int, emit func(models.Telemetry) error) (Sampler, error) {
+	switch method {
+	case MethodLTTB:
+		if points < 3 {
+			return nil, fmt.Errorf("lttb needs at least 3 points")
+		}
+		return newLTTB(total, points, emit), nil
+	case MethodMinMax:
+		if points < 2 {
+			return nil, fmt.Errorf("minmax needs at least 2 points")
+		}
+		return newMinMax(total, points, emit), nil
+	}
+	return nil, fmt.Errorf("unknown downsampling method %q", method)
+}
+
+// values returns the parameters samples are chosen for
+func values(t models.Telemetry) [parameters]float64 {
+	return [parameters]float64{
+		float64(t.Temperature),
+		float64(t.Battery),
+		float64(t.Altitude),
+		float64(t.Signal),
+	}
+}
+
+// bucketSize divides n records into buckets buckets, rounding up
+func bucketSize(n, buckets int) int {
+	if buckets < 1 || n <= buckets {
+		return 1
+	}
+	return (n + buckets - 1) / buckets
+}
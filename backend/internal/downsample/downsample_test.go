@@ -0,0 +1,172 @@
+package downsample
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+)
+
+var methods = []string{MethodLTTB, MethodMinMax}
+
+func sine(i int) float32 { return float32(math.Sin(float64(i) / 50)) }
+
+// series builds n records a second apart whose parameters follow value
+func series(n int, value func(i int) float32, anomalies ...int) []models.Telemetry {
+	start := time.Unix(1700000000, 0)
+	records := make([]models.Telemetry, n)
+	for i := range records {
+		v := value(i)
+		records[i] = models.Telemetry{
+			ID:          uint(i + 1),
+			Timestamp:   start.Add(time.Duration(i) * time.Second),
+			Temperature: v,
+			Battery:     -v,
+			Altitude:    v * 2,
+			Signal:      v / 2,
+		}
+	}
+	for _, i := range anomalies {
+		records[i].Anomaly = true
+	}
+	return records
+}
+
+// sample passes records through a sampler and returns what it kept
+func sample(t *testing.T, method string, points int, records []models.Telemetry) []models.Telemetry {
+	t.Helper()
+	var kept []models.Telemetry
+	s, err := New(method, len(records), points, func(r models.Telemetry) error {
+		kept = append(kept, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if err := s.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return kept
+}
+
+// checkReduced checks that kept is a time-ordered reduction of records to
+// about points samples per parameter that keeps both ends and every anomaly
+func checkReduced(t *testing.T, records, kept []models.Telemetry, points int) {
+	t.Helper()
+	for i := 1; i < len(kept); i++ {
+		if !kept[i].Timestamp.After(kept[i-1].Timestamp) {
+			t.Fatalf("record %d at %s is not after %s", i, kept[i].Timestamp, kept[i-1].Timestamp)
+		}
+	}
+
+	ids := make(map[uint]bool)
+	for _, r := range kept {
+		ids[r.ID] = true
+	}
+	if !ids[records[0].ID] || !ids[records[len(records)-1].ID] {
+		t.Fatal("first or last record dropped")
+	}
+	anomalies := 0
+	for _, r := range records {
+		if r.Anomaly {
+			anomalies++
+			if !ids[r.ID] {
+				t.Fatalf("anomaly %d dropped", r.ID)
+			}
+		}
+	}
+
+	// Each parameter contributes at most one or two records per bucket
+	if limit := parameters*points + anomalies + 2; len(kept) > limit {
+		t.Fatalf("kept %d records, more than %d", len(kept), limit)
+	}
+}
+
+func TestNewRejects(t *testing.T) {
+	emit := func(models.Telemetry) error { return nil }
+	if _, err := New(MethodLTTB, 100, 2, emit); err == nil {
+		t.Error("lttb accepted 2 points")
+	}
+	if _, err := New(MethodMinMax, 100, 1, emit); err == nil {
+		t.Error("minmax accepted 1 point")
+	}
+	if _, err := New("average", 100, 100, emit); err == nil {
+		t.Error("unknown method accepted")
+	}
+}
+
+func TestSamplersKeepShortSeries(t *testing.T) {
+	for _, method := range methods {
+		if kept := sample(t, method, 10, nil); len(kept) != 0 {
+			t.Errorf("%s: kept %d records of none", method, len(kept))
+		}
+		for _, n := range []int{1, 8} {
+			if kept := sample(t, method, 10, series(n, sine)); len(kept) != n {
+				t.Errorf("%s: kept %d of %d records", method, len(kept), n)
+			}
+		}
+	}
+}
+
+func TestSamplersReduce(t *testing.T) {
+	for _, method := range methods {
+		records := series(5000, sine)
+		checkReduced(t, records, sample(t, method, 100, records), 100)
+
+		// Anomalies are kept wherever they fall
+		records = series(3000, sine, 7, 1500, 2998)
+		checkReduced(t, records, sample(t, method, 60, records), 60)
+
+		// The last bucket holds fewer records than the others
+		records = series(1001, sine, 1000)
+		checkReduced(t, records, sample(t, method, 40, records), 40)
+	}
+}
+
+func TestSamplersKeepSpike(t *testing.T) {
+	records := series(2000, func(i int) float32 {
+		if i == 613 {
+			return 100
+		}
+		return 1
+	})
+	for _, method := range methods {
+		kept := sample(t, method, 50, records)
+		checkReduced(t, records, kept, 50)
+
+		found := false
+		for _, r := range kept {
+			found = found || r.ID == records[613].ID
+		}
+		if !found {
+			t.Errorf("%s: spike dropped", method)
+		}
+	}
+}
+
+func TestMinMaxKeepsExtremes(t *testing.T) {
+	records := series(4000, func(i int) float32 { return float32(math.Sin(float64(i)/37) * float64(i%113)) })
+	kept := sample(t, MethodMinMax, 80, records)
+
+	for p := 0; p < parameters; p++ {
+		min, max := math.Inf(1), math.Inf(-1)
+		for _, r := range records {
+			v := values(r)[p]
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+		keptMin, keptMax := math.Inf(1), math.Inf(-1)
+		for _, r := range kept {
+			v := values(r)[p]
+			keptMin, keptMax = math.Min(keptMin, v), math.Max(keptMax, v)
+		}
+		if keptMin != min || keptMax != max {
+			t.Errorf("parameter %d: kept range %g to %g, series spans %g to %g", p, keptMin, keptMax, min, max)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package downsample
+
+import (
+	"math"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+)
+
+// lttb implements Largest-Triangle-Three-Buckets. The first and last records
+// are kept, and from each bucket between them the record forming the largest
+// triangle with the previously kept record and the average of the next bucket.
+// Only the bucket being chosen from and the next one are held.
+type lttb struct {
+	emit    func(models.Telemetry) error
+	size    int
+	started bool
+	origin  float64 // Time of the first record, so times keep their precision
+	current []models.Telemetry
+	next    []models.Telemetry
+
+	prev [parameters][2]float64 // Time and value of the record last kept for each parameter
+}
+
+// newLTTB creates a sampler keeping points records per parameter from total
+func newLTTB(total, points int, emit func(models.Telemetry) error) *lttb {
+	return &lttb{emit: emit, size: bucketSize(total-2, points-2)}
+}
+
+// x returns a record's time in seconds since the first record
+func (s *lttb) x(t models.Telemetry) float64 {
+	return float64(t.Timestamp.UnixNano())/1e9 - s.origin
+}
+
+// Add buffers t, choosing from the previous bucket once the next one is full
+func (s *lttb) Add(t models.Telemetry) error {
+	if !s.started {
+		s.started = true
+		s.origin = float64(t.Timestamp.UnixNano()) / 1e9
+		s.keep(t)
+		return s.emit(t)
+	}
+
+	s.next = append(s.next, t)
+	if len(s.next) < s.size {
+		return nil
+	}
+	if s.current != nil {
+		x, y := s.average(s.next)
+		if err := s.choose(s.current, x, y); err != nil {
+			return err
+		}
+	}
+	s.current, s.next = s.next, make([]models.Telemetry, 0, s.size)
+	return nil
+}
+
+// Flush chooses from the buffered buckets and emits the last record
+func (s *lttb) Flush() error {
+	rest := append(s.current, s.next...)
+	s.current, s.next = nil, nil
+	if len(rest) == 0 {
+		return nil
+	}
+	last := rest[len(rest)-1]
+	rest = rest[:len(rest)-1]
+
+	// What remains is at most two buckets; the last record anchors the final one
+	for len(rest) > 0 {
+		n := s.size
+		if n > len(rest) {
+			n = len(rest)
+		}
+		bucket, after := rest[:n], rest[n:]
+		x, y := s.average([]models.Telemetry{last})
+		if len(after) > 0 {
+			x, y = s.average(after)
+		}
+		if err := s.choose(bucket, x, y); err != nil {
+			return err
+		}
+		rest = after
+	}
+	return s.emit(last)
+}
+
+// choose emits the records of bucket that form the largest triangle for
+// some parameter, along with any anomalies
+func (s *lttb) choose(bucket []models.Telemetry, nextX float64, nextY [parameters]float64) error {
+	var best [parameters]int
+	var area [parameters]float64
+	for p := range area {
+		area[p] = -1
+	}
+	for i, t := range bucket {
+		x, v := s.x(t), values(t)
+		for p := range v {
+			ax, ay := s.prev[p][0], s.prev[p][1]
+			a := math.Abs((ax-nextX)*(v[p]-ay) - (ax-x)*(nextY[p]-ay))
+			if a > area[p] {
+				area[p], best[p] = a, i
+			}
+		}
+	}
+
+	for i, t := range bucket {
+		selected := t.Anomaly
+		for p, b := range best {
+			if b == i {
+				selected = true
+				s.prev[p] = [2]float64{s.x(t), values(t)[p]}
+			}
+		}
+		if selected {
+			if err := s.emit(t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// keep records t as the last kept record for every parameter
+func (s *lttb) keep(t models.Telemetry) {
+	x, v := s.x(t), values(t)
+	for p := range v {
+		s.prev[p] = [2]float64{x, v[p]}
+	}
+}
+
+// average returns the mean time and parameter values of records
+func (s *lttb) average(records []models.Telemetry) (float64, [parameters]float64) {
+	var x float64
+	var y [parameters]float64
+	for _, t := range records {
+		x += s.x(t)
+		v := values(t)
+		for p := range y {
+			y[p] += v[p]
+		}
+	}
+	n := float64(len(records))
+	for p := range y {
+		y[p] /= n
+	}
+	return x / n, y
+}
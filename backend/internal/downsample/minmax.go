@@ -0,0 +1,107 @@
+package downsample
+
+import (
+	"sort"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+)
+
+// candidate is a record that may be kept from the current bucket, with its
+// position in the series so the bucket can be emitted in order
+type candidate struct {
+	index int
+	t     models.Telemetry
+}
+
+// minMax keeps the records holding each parameter's minimum and maximum in
+// every bucket, together with the first and last records of the series.
+// Only those candidates and the bucket's anomalies are held.
+type minMax struct {
+	emit    func(models.Telemetry) error
+	size    int
+	seen    int
+	count   int // Records in the current bucket
+	emitted int // Position of the last record emitted
+
+	min, max [parameters]candidate
+	anomaly  []candidate
+	last     candidate
+}
+
+// newMinMax creates a sampler keeping about points records per parameter
+// from total, a minimum and a maximum from each bucket
+func newMinMax(total, points int, emit func(models.Telemetry) error) *minMax {
+	return &minMax{emit: emit, size: bucketSize(total, points/2), emitted: -1}
+}
+
+// Add folds t into the current bucket, emitting the bucket once it is full
+func (s *minMax) Add(t models.Telemetry) error {
+	c := candidate{index: s.seen, t: t}
+	s.seen++
+
+	if c.index == 0 {
+		s.emitted = 0
+		if err := s.emit(t); err != nil {
+			return err
+		}
+	}
+
+	v := values(t)
+	for p := range v {
+		if s.count == 0 || v[p] < values(s.min[p].t)[p] {
+			s.min[p] = c
+		}
+		if s.count == 0 || v[p] > values(s.max[p].t)[p] {
+			s.max[p] = c
+		}
+	}
+	if t.Anomaly {
+		s.anomaly = append(s.anomaly, c)
+	}
+	s.last = c
+	s.count++
+
+	if s.count < s.size {
+		return nil
+	}
+	return s.flushBucket(false)
+}
+
+// Flush emits the last partial bucket and the last record
+func (s *minMax) Flush() error {
+	if s.count > 0 {
+		return s.flushBucket(true)
+	}
+	if s.seen > 0 && s.last.index > s.emitted {
+		s.emitted = s.last.index
+		return s.emit(s.last.t)
+	}
+	return nil
+}
+
+// flushBucket emits the current bucket's candidates in series order,
+// skipping any already emitted, such as the first record
+func (s *minMax) flushBucket(final bool) error {
+	kept := make([]candidate, 0, len(s.anomaly)+2*parameters+1)
+	kept = append(kept, s.anomaly...)
+	kept = append(kept, s.min[:]...)
+	kept = append(kept, s.max[:]...)
+	if final {
+		kept = append(kept, s.last)
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].index < kept[j].index })
+
+	for _, c := range kept {
+		if c.index <= s.emitted {
+			continue
+		}
+		s.emitted = c.index
+		if err := s.emit(c.t); err != nil {
+			return err
+		}
+	}
+
+	s.count = 0
+	s.anomaly = s.anomaly[:0]
+	return nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// Command statuses recorded in the command history
+const (
+	CommandStatusReleased    = "released"    // Validated and encoded, not yet sent
+	CommandStatusTransmitted = "transmitted" // Sent on the uplink
+	CommandStatusReceived    = "received"    // Received on board
+	CommandStatusAccepted    = "accepted"    // Accepted for execution on board
+	CommandStatusExecuted    = "executed"    // Executed; verification complete
+	CommandStatusFailed      = "failed"      // Could not be sent, or reported as failed
+	CommandStatusTimedOut    = "timed_out"   // A verification stage was not satisfied in time
+)
+
+// CommandHistory records one telecommand sent by an operator.
+type CommandHistory struct {
+	ID            uint       `gorm:"primaryKey"`                       // Unique identifier for the command
+	Command       string     `gorm:"not null;index"`                   // Dictionary name of the command
+	Opcode        uint16     `gorm:"not null"`                         // Opcode encoded in the packet
+	Arguments     string     `gorm:"type:jsonb;not null;default:'{}'"` // Arguments as submitted, in JSON
+	Operator      string     `gorm:"not null;index"`                   // Who sent the command
+	SpacecraftID  uint16     `gorm:"not null;default:0;index"`         // Spacecraft the command is addressed to
+	APID          uint16     `gorm:"not null"`                         // APID of the TC packet
+	SequenceCount uint16     `gorm:"not null"`                         // Sequence count of the TC packet
+	Packet        []byte     `gorm:"not null"`                         // Encoded TC packet
+	Status        string     `gorm:"not null;index"`                   // Current command status
+	Error         string     `gorm:"not null;default:''"`              // Reason for a failed command
+	ReleasedAt    time.Time  `gorm:"not null;index"`                   // When the command was accepted for release
+	TransmittedAt *time.Time // When the packet was sent on the uplink
+	Stages        string     `gorm:"type:jsonb;not null;default:'[]'"` // Verification stage results, in JSON
+	CompletedAt   *time.Time // When verification reached a final status
+}
+
+// TableName stores the history in a table named command_history.
+func (CommandHistory) TableName() string {
+	return "command_history"
+}
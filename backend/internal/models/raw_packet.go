@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// RawPacket represents an archived datagram exactly as it was received.
+type RawPacket struct {
+	ID           uint      `gorm:"primaryKey"`          // Unique identifier for the archived datagram
+	ReceivedAt   time.Time `gorm:"not null;index"`      // Ground receipt time of the datagram
+	Source       string    `gorm:"not null"`            // Sender address as host:port
+	Mode         string    `gorm:"not null"`            // Ingest mode the datagram was received in (packet or frame)
+	SpacecraftID uint16    `gorm:"not null;default:0"`  // Default spacecraft tag of the receiving listener
+	Stream       string    `gorm:"not null;default:''"` // Stream tag of the receiving listener
+	Data         []byte    `gorm:"not null"`            // Original datagram bytes
+}
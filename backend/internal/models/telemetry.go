@@ -4,13 +4,44 @@ import (
 	"time"
 )
 
+// DatasetLive is the dataset that real-time ingest writes to. Replays may
+// write to other named datasets so they can be compared with live data.
+const DatasetLive = "live"
+
+// Quality flags describing how far a telemetry record's packet was verified.
+const (
+	QualityUnverified = "unverified" // The packet carried no error control field
+	QualityVerified   = "verified"   // The packet error control field matched
+)
+
+// Time quality flags describing how much a record's timestamp can be trusted.
+const (
+	TimeQualityUnknown   = "unknown"    // Recorded before clock correlation was available
+	TimeQualityGood      = "good"       // Onboard time agrees with the correlation model
+	TimeQualityCorrected = "corrected"  // Onboard time was mapped to ground time by the model
+	TimeQualityFuture    = "future"     // Onboard time is ahead of ground receipt time
+	TimeQualityPreLaunch = "pre_launch" // Onboard time is before launch, e.g. after a clock reset
+	TimeQualityJump      = "jump"       // Onboard time jumped away from the correlation model
+)
+
 // Telemetry represents a database record for spacecraft telemetry data.
 type Telemetry struct {
-	ID          uint      `gorm:"primaryKey"` // Unique identifier for the telemetry record
-	Timestamp   time.Time `gorm:"not null"`   // Time when the telemetry data was recorded
-	Temperature float32   `gorm:"not null"`   // Temperature in degrees Celsius
-	Battery     float32   `gorm:"not null"`   // Battery percentage (0-100%)
-	Altitude    float32   `gorm:"not null"`   // Altitude in kilometers
-	Signal      float32   `gorm:"not null"`   // Signal strength in decibels (dB)
-	Anomaly     bool      `gorm:"not null"`   // Indicates if the entry contains an anomaly (true = anomaly detected)
+	ID            uint      `gorm:"primaryKey"`                                                               // Unique identifier for the telemetry record
+	SpacecraftID  uint16    `gorm:"not null;default:0"`                                                       // Spacecraft that produced the data (0 when unknown)
+	Stream        string    `gorm:"not null;default:''"`                                                      // Stream tag of the listener the packet arrived on
+	APID          uint16    `gorm:"not null;default:0"`                                                       // Application process ID of the source packet
+	SequenceCount uint16    `gorm:"not null;default:0"`                                                       // Sequence count of the source packet
+	Timestamp     time.Time `gorm:"not null;index:idx_telemetries_dataset_timestamp,priority:2"`              // Time when the telemetry data was recorded
+	Temperature   float32   `gorm:"not null"`                                                                 // Temperature in degrees Celsius
+	Battery       float32   `gorm:"not null"`                                                                 // Battery percentage (0-100%)
+	Altitude      float32   `gorm:"not null"`                                                                 // Altitude in kilometers
+	Signal        float32   `gorm:"not null"`                                                                 // Signal strength in decibels (dB)
+	Anomaly       bool      `gorm:"not null"`                                                                 // Indicates if the entry contains an anomaly (true = anomaly detected)
+	Quality       string    `gorm:"not null;default:unverified"`                                              // Integrity check result, one of the Quality constants
+	ReceivedAt    time.Time `gorm:"index"`                                                                    // Ground receipt time of the packet
+	TimeQuality   string    `gorm:"not null;default:unknown"`                                                 // Timestamp trust level, one of the TimeQuality constants
+	Dataset       string    `gorm:"not null;default:live;index:idx_telemetries_dataset_timestamp,priority:1"` // Dataset the record belongs to (live or a replay target)
+	HeaterOn      *bool     // Battery heater state, when the packet reports spacecraft status
+	SafeMode      *bool     // Safe mode state, when the packet reports spacecraft status
+	TxIntervalMs  *uint16   // Telemetry transmit interval, when the packet reports spacecraft status
 }
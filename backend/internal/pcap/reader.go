@@ -0,0 +1,276 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Magic numbers identifying capture file formats
+const (
+	magicMicros     = 0xA1B2C3D4 // Classic pcap, microsecond timestamps
+	magicNanos      = 0xA1B23C4D // Classic pcap, nanosecond timestamps
+	pcapngSHB       = 0x0A0D0D0A // pcapng Section Header Block
+	pcapngByteOrder = 0x1A2B3C4D // pcapng byte-order magic
+)
+
+// pcapng block types
+const (
+	blockInterface      = 0x00000001
+	blockSimplePacket   = 0x00000003
+	blockEnhancedPacket = 0x00000006
+)
+
+// maxBlockSize guards against corrupt length fields allocating huge buffers
+const maxBlockSize = 16 << 20
+
+// ErrUnknownFormat is returned when the file is neither pcap nor pcapng
+var ErrUnknownFormat = errors.New("unrecognised capture file format")
+
+// Record is one captured frame
+type Record struct {
+	Timestamp time.Time
+	Estimated bool // Timestamp was not captured; see Reader.Start
+	LinkType  uint16
+	Data      []byte
+}
+
+// iface describes a pcapng capture interface
+type iface struct {
+	linkType   uint16
+	resolution time.Duration // Duration of one timestamp unit
+}
+
+// Reader reads records from a pcap or pcapng stream
+type Reader struct {
+	r      *bufio.Reader
+	ng     bool
+	order  binary.ByteOrder
+	header [24]byte
+
+	// Classic pcap state
+	linkType   uint16
+	resolution time.Duration
+
+	// pcapng state
+	ifaces   []iface
+	lastTime time.Time // Timestamp of the last record that carried one
+
+	// Start is given to Simple Packet Blocks read before any timestamped
+	// record, such as the file's modification time. Later ones take the
+	// timestamp of the record before them.
+	Start time.Time
+}
+
+// NewReader detects the capture format and reads the file header
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &Reader{r: br}
+	switch {
+	case binary.BigEndian.Uint32(magic) == pcapngSHB:
+		reader.ng = true
+		return reader, nil
+	case binary.LittleEndian.Uint32(magic) == magicMicros, binary.LittleEndian.Uint32(magic) == magicNanos:
+		reader.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(magic) == magicMicros, binary.BigEndian.Uint32(magic) == magicNanos:
+		reader.order = binary.BigEndian
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	if _, err := io.ReadFull(br, reader.header[:]); err != nil {
+		return nil, err
+	}
+	reader.resolution = time.Microsecond
+	if reader.order.Uint32(reader.header[0:4]) == magicNanos {
+		reader.resolution = time.Nanosecond
+	}
+	reader.linkType = uint16(reader.order.Uint32(reader.header[20:24]))
+	return reader, nil
+}
+
+// Next returns the next captured frame, or io.EOF at the end of the file
+func (r *Reader) Next() (Record, error) {
+	if r.ng {
+		return r.nextNG()
+	}
+	return r.nextPcap()
+}
+
+// nextPcap reads one classic pcap record
+func (r *Reader) nextPcap() (Record, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+		return Record{}, err
+	}
+
+	sec := int64(r.order.Uint32(hdr[0:4]))
+	frac := int64(r.order.Uint32(hdr[4:8]))
+	capLen := r.order.Uint32(hdr[8:12])
+	if capLen > maxBlockSize {
+		return Record{}, fmt.Errorf("record length %d too large", capLen)
+	}
+
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return Record{}, unexpected(err)
+	}
+
+	return Record{
+		Timestamp: time.Unix(sec, frac*int64(r.resolution)),
+		LinkType:  r.linkType,
+		Data:      data,
+	}, nil
+}
+
+// nextNG reads pcapng blocks until it finds one carrying a packet
+func (r *Reader) nextNG() (Record, error) {
+	for {
+		blockType, body, err := r.readBlock()
+		if err != nil {
+			return Record{}, err
+		}
+
+		switch blockType {
+		case pcapngSHB:
+			// A new section resets the interface list and may change byte order
+			r.ifaces = nil
+		case blockInterface:
+			if len(body) < 8 {
+				return Record{}, io.ErrUnexpectedEOF
+			}
+			r.ifaces = append(r.ifaces, iface{
+				linkType:   r.order.Uint16(body[0:2]),
+				resolution: r.parseResolution(body[8:]),
+			})
+		case blockEnhancedPacket:
+			if len(body) < 20 {
+				return Record{}, io.ErrUnexpectedEOF
+			}
+			id := r.order.Uint32(body[0:4])
+			if int(id) >= len(r.ifaces) {
+				return Record{}, fmt.Errorf("packet references unknown interface %d", id)
+			}
+			ifc := r.ifaces[id]
+			ts := uint64(r.order.Uint32(body[4:8]))<<32 | uint64(r.order.Uint32(body[8:12]))
+			capLen := r.order.Uint32(body[12:16])
+			if int(capLen) > len(body)-20 {
+				return Record{}, io.ErrUnexpectedEOF
+			}
+			r.lastTime = toTime(ts, ifc.resolution)
+			return Record{
+				Timestamp: r.lastTime,
+				LinkType:  ifc.linkType,
+				Data:      body[20 : 20+capLen],
+			}, nil
+		case blockSimplePacket:
+			if len(body) < 4 || len(r.ifaces) == 0 {
+				return Record{}, io.ErrUnexpectedEOF
+			}
+			origLen := r.order.Uint32(body[0:4])
+			data := body[4:]
+			if int(origLen) < len(data) {
+				data = data[:origLen]
+			}
+			// Simple packets carry no timestamp, so one is estimated
+			ts := r.lastTime
+			if ts.IsZero() {
+				ts = r.Start
+			}
+			return Record{Timestamp: ts, Estimated: true, LinkType: r.ifaces[0].linkType, Data: data}, nil
+		}
+	}
+}
+
+// readBlock reads a pcapng block and returns its type and body
+func (r *Reader) readBlock() (uint32, []byte, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+
+	// The section header's byte-order magic decides how the block is read
+	if binary.BigEndian.Uint32(hdr[0:4]) == pcapngSHB {
+		bom, err := r.r.Peek(4)
+		if err != nil {
+			return 0, nil, unexpected(err)
+		}
+		if binary.LittleEndian.Uint32(bom) == pcapngByteOrder {
+			r.order = binary.LittleEndian
+		} else if binary.BigEndian.Uint32(bom) == pcapngByteOrder {
+			r.order = binary.BigEndian
+		} else {
+			return 0, nil, ErrUnknownFormat
+		}
+	}
+
+	blockType := r.order.Uint32(hdr[0:4])
+	length := r.order.Uint32(hdr[4:8])
+	if length < 12 || length > maxBlockSize || length%4 != 0 {
+		return 0, nil, fmt.Errorf("invalid pcapng block length %d", length)
+	}
+
+	// Body plus the trailing copy of the block length
+	rest := make([]byte, length-8)
+	if _, err := io.ReadFull(r.r, rest); err != nil {
+		return 0, nil, unexpected(err)
+	}
+	return blockType, rest[:len(rest)-4], nil
+}
+
+// parseResolution reads the if_tsresol option, defaulting to microseconds
+func (r *Reader) parseResolution(options []byte) time.Duration {
+	for len(options) >= 4 {
+		code := r.order.Uint16(options[0:2])
+		length := int(r.order.Uint16(options[2:4]))
+		if code == 0 || 4+length > len(options) {
+			break
+		}
+		if code == 9 && length >= 1 {
+			v := options[4]
+			if v&0x80 == 0 {
+				// Negative power of ten
+				res := time.Second
+				for i := 0; i < int(v) && res > 1; i++ {
+					res /= 10
+				}
+				return res
+			}
+			// Negative power of two, approximated to the nearest nanosecond
+			return time.Duration(float64(time.Second) / float64(uint64(1)<<(v&0x7F)))
+		}
+		options = options[4+(length+3)&^3:]
+	}
+	return time.Microsecond
+}
+
+// toTime converts a pcapng timestamp in resolution units to a time
+func toTime(ts uint64, resolution time.Duration) time.Time {
+	if resolution <= 0 {
+		resolution = time.Nanosecond
+	}
+	units := uint64(time.Second / resolution)
+	if units == 0 {
+		units = 1
+	}
+	sec := ts / units
+	rem := ts % units
+	return time.Unix(int64(sec), int64(rem)*int64(resolution))
+}
+
+// unexpected turns a mid-record EOF into io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,247 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// byteOrder is a byte order that can also append
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+var ts = time.Date(2024, 5, 1, 12, 0, 0, 123456000, time.UTC)
+
+// classic builds a classic pcap file whose records are the given frames,
+// one second apart from start
+func classic(order byteOrder, magic uint32, linkType uint32, start time.Time, frames ...[]byte) []byte {
+	b := order.AppendUint32(nil, magic)
+	b = order.AppendUint16(b, 2)
+	b = order.AppendUint16(b, 4)
+	b = append(b, make([]byte, 8)...)
+	b = order.AppendUint32(b, 65535)
+	b = order.AppendUint32(b, linkType)
+
+	frac := uint32(start.Nanosecond() / 1000)
+	if magic == magicNanos {
+		frac = uint32(start.Nanosecond())
+	}
+	for i, frame := range frames {
+		b = order.AppendUint32(b, uint32(start.Unix())+uint32(i))
+		b = order.AppendUint32(b, frac)
+		b = order.AppendUint32(b, uint32(len(frame)))
+		b = order.AppendUint32(b, uint32(len(frame)))
+		b = append(b, frame...)
+	}
+	return b
+}
+
+// block encodes a pcapng block, padding its body to four bytes
+func block(order byteOrder, blockType uint32, body []byte) []byte {
+	padded := append(append([]byte(nil), body...), make([]byte, (4-len(body)%4)%4)...)
+	length := uint32(12 + len(padded))
+	b := order.AppendUint32(nil, blockType)
+	b = order.AppendUint32(b, length)
+	b = append(b, padded...)
+	return order.AppendUint32(b, length)
+}
+
+func sectionHeader(order byteOrder) []byte {
+	body := order.AppendUint32(nil, pcapngByteOrder)
+	body = order.AppendUint16(body, 1)
+	body = order.AppendUint16(body, 0)
+	body = append(body, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	return block(order, pcapngSHB, body)
+}
+
+// interfaceBlock describes an interface, with an if_tsresol option when
+// resolution is non-zero
+func interfaceBlock(order byteOrder, linkType uint16, resolution byte) []byte {
+	body := order.AppendUint16(nil, linkType)
+	body = order.AppendUint16(body, 0)
+	body = order.AppendUint32(body, 65535)
+	if resolution != 0 {
+		body = order.AppendUint16(body, 9)
+		body = order.AppendUint16(body, 1)
+		body = append(body, resolution, 0, 0, 0, 0, 0, 0, 0)
+	}
+	return block(order, blockInterface, body)
+}
+
+func enhancedPacket(order byteOrder, iface uint32, ts uint64, data []byte) []byte {
+	body := order.AppendUint32(nil, iface)
+	body = order.AppendUint32(body, uint32(ts>>32))
+	body = order.AppendUint32(body, uint32(ts))
+	body = order.AppendUint32(body, uint32(len(data)))
+	body = order.AppendUint32(body, uint32(len(data)))
+	return block(order, blockEnhancedPacket, append(body, data...))
+}
+
+func simplePacket(order byteOrder, data []byte) []byte {
+	return block(order, blockSimplePacket, append(order.AppendUint32(nil, uint32(len(data))), data...))
+}
+
+// readAll reads a capture to its end, returning its records and the error
+// that stopped the reader
+func readAll(t *testing.T, file []byte) ([]Record, error) {
+	t.Helper()
+	r, err := NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var records []Record
+	for {
+		rec, err := r.Next()
+		if err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+}
+
+func checkRecord(t *testing.T, got Record, timestamp time.Time, linkType uint16, data string) {
+	t.Helper()
+	if !got.Timestamp.Equal(timestamp) || got.LinkType != linkType || string(got.Data) != data {
+		t.Errorf("record at %s on link %d holds %q, want %s on link %d holding %q",
+			got.Timestamp, got.LinkType, got.Data, timestamp, linkType, data)
+	}
+}
+
+func TestReaderClassic(t *testing.T) {
+	records, err := readAll(t, classic(binary.LittleEndian, magicMicros, LinkTypeEthernet, ts, []byte("first"), []byte("second")))
+	if err != io.EOF || len(records) != 2 {
+		t.Fatalf("read %d records before %v, want 2 before EOF", len(records), err)
+	}
+	checkRecord(t, records[0], ts, LinkTypeEthernet, "first")
+	checkRecord(t, records[1], ts.Add(time.Second), LinkTypeEthernet, "second")
+
+	// Big-endian files may carry nanosecond timestamps
+	records, err = readAll(t, classic(binary.BigEndian, magicNanos, LinkTypeRaw, ts.Add(789), []byte("first")))
+	if err != io.EOF || len(records) != 1 {
+		t.Fatalf("read %d records before %v, want 1 before EOF", len(records), err)
+	}
+	checkRecord(t, records[0], ts.Add(789), LinkTypeRaw, "first")
+
+	// A record cut short is not mistaken for the end of the file
+	file := classic(binary.LittleEndian, magicMicros, LinkTypeEthernet, ts, []byte("first"))
+	if _, err := readAll(t, file[:len(file)-2]); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated record: error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestReaderPcapng(t *testing.T) {
+	le := binary.LittleEndian
+	file := bytes.Join([][]byte{
+		sectionHeader(le),
+		interfaceBlock(le, LinkTypeEthernet, 0),
+		interfaceBlock(le, LinkTypeRaw, 9),
+		enhancedPacket(le, 0, uint64(ts.UnixMicro()), []byte("first")),
+		enhancedPacket(le, 1, uint64(ts.UnixNano()), []byte("second")),
+		simplePacket(le, []byte("third")),
+	}, nil)
+
+	records, err := readAll(t, file)
+	if err != io.EOF || len(records) != 3 {
+		t.Fatalf("read %d records before %v, want 3 before EOF", len(records), err)
+	}
+	checkRecord(t, records[0], ts, LinkTypeEthernet, "first")
+	checkRecord(t, records[1], ts, LinkTypeRaw, "second")
+
+	// Simple packets carry no timestamp, so they take the one before them,
+	// and belong to the first interface
+	checkRecord(t, records[2], ts, LinkTypeEthernet, "third")
+	if records[1].Estimated || !records[2].Estimated {
+		t.Errorf("estimated %v and %v, want only the simple packet estimated", records[1].Estimated, records[2].Estimated)
+	}
+}
+
+func TestReaderPcapngEstimatesFromStart(t *testing.T) {
+	le := binary.LittleEndian
+	file := bytes.Join([][]byte{
+		sectionHeader(le),
+		interfaceBlock(le, LinkTypeRaw, 0),
+		simplePacket(le, []byte("first")),
+	}, nil)
+
+	// Before any timestamped record the reader's start time is used
+	r, err := NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start = ts.Add(-time.Hour)
+	rec, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecord(t, rec, r.Start, LinkTypeRaw, "first")
+	if !rec.Estimated {
+		t.Error("simple packet time not marked estimated")
+	}
+}
+
+func TestReaderPcapngSections(t *testing.T) {
+	// A second section switches byte order and starts a new interface list
+	be := binary.BigEndian
+	file := bytes.Join([][]byte{
+		sectionHeader(binary.LittleEndian),
+		interfaceBlock(binary.LittleEndian, LinkTypeEthernet, 0),
+		sectionHeader(be),
+		interfaceBlock(be, LinkTypeRaw, 0),
+		enhancedPacket(be, 0, uint64(ts.UnixMicro()), []byte("first")),
+		enhancedPacket(be, 1, 0, []byte("second")),
+	}, nil)
+
+	records, err := readAll(t, file)
+	if len(records) != 1 {
+		t.Fatalf("read %d records, want 1", len(records))
+	}
+	checkRecord(t, records[0], ts, LinkTypeRaw, "first")
+	if err == nil || err.Error() != "packet references unknown interface 1" {
+		t.Errorf("packet on a previous section's interface: error %v", err)
+	}
+}
+
+func TestReaderBadBlockLength(t *testing.T) {
+	_, err := readAll(t, append(sectionHeader(binary.LittleEndian), 1, 0, 0, 0, 7, 0, 0, 0))
+	if err == nil || err.Error() != "invalid pcapng block length 7" {
+		t.Errorf("error %v, want an invalid block length", err)
+	}
+}
+
+func TestNewReaderErrors(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader([]byte("GIF89a, not a capture"))); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("unknown format: error %v, want %v", err, ErrUnknownFormat)
+	}
+	if _, err := NewReader(bytes.NewReader(nil)); err != io.EOF {
+		t.Errorf("empty file: error %v, want %v", err, io.EOF)
+	}
+	header := classic(binary.LittleEndian, magicMicros, LinkTypeEthernet, ts)
+	if _, err := NewReader(bytes.NewReader(header[:20])); err != io.ErrUnexpectedEOF {
+		t.Errorf("short header: error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestParseResolution(t *testing.T) {
+	r := &Reader{order: binary.LittleEndian}
+	resolutions := map[byte]time.Duration{
+		3:         time.Millisecond,
+		6:         time.Microsecond,
+		9:         time.Nanosecond,
+		0x80 | 10: 976562, // 2^-10 seconds, in whole nanoseconds
+	}
+	for value, want := range resolutions {
+		options := []byte{9, 0, 1, 0, value, 0, 0, 0, 0, 0, 0, 0}
+		if got := r.parseResolution(options); got != want {
+			t.Errorf("if_tsresol %#x: %s, want %s", value, got, want)
+		}
+	}
+	if got := r.parseResolution(nil); got != time.Microsecond {
+		t.Errorf("default resolution %s, want 1µs", got)
+	}
+}
@@ -0,0 +1,201 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// Link-layer header types (tcpdump.org/linktypes.html)
+const (
+	LinkTypeNull      = 0   // BSD loopback
+	LinkTypeEthernet  = 1   // IEEE 802.3 Ethernet
+	LinkTypeRaw       = 101 // Raw IPv4 or IPv6
+	LinkTypeLinuxSLL  = 113 // Linux cooked capture v1
+	LinkTypeLinuxSLL2 = 276 // Linux cooked capture v2
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86DD
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88A8
+	protocolUDP   = 17
+)
+
+var (
+	// ErrNotUDP is returned for frames that do not carry a UDP datagram
+	ErrNotUDP = errors.New("not a UDP datagram")
+	// ErrFragment is returned for IP fragments, which are not reassembled
+	ErrFragment = errors.New("fragmented IP datagram")
+	// ErrTruncated is returned when a header extends past the captured bytes
+	ErrTruncated = errors.New("truncated frame")
+	// ErrLinkType is returned for link types the decoder does not understand
+	ErrLinkType = errors.New("unsupported link type")
+)
+
+// UDPDatagram is a UDP payload with its addressing
+type UDPDatagram struct {
+	SrcIP   net.IP
+	DstIP   net.IP
+	SrcPort uint16
+	DstPort uint16
+	Payload []byte
+}
+
+// Source returns the sender as a host:port address
+func (d UDPDatagram) Source() string {
+	return (&net.UDPAddr{IP: d.SrcIP, Port: int(d.SrcPort)}).String()
+}
+
+// DecodeUDP strips the link, network and transport headers from a captured frame
+func DecodeUDP(linkType uint16, frame []byte) (UDPDatagram, error) {
+	var etherType uint16
+	var payload []byte
+
+	switch linkType {
+	case LinkTypeEthernet:
+		if len(frame) < 14 {
+			return UDPDatagram{}, ErrTruncated
+		}
+		etherType = binary.BigEndian.Uint16(frame[12:14])
+		payload = frame[14:]
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+			if len(payload) < 4 {
+				return UDPDatagram{}, ErrTruncated
+			}
+			etherType = binary.BigEndian.Uint16(payload[2:4])
+			payload = payload[4:]
+		}
+	case LinkTypeLinuxSLL:
+		if len(frame) < 16 {
+			return UDPDatagram{}, ErrTruncated
+		}
+		etherType = binary.BigEndian.Uint16(frame[14:16])
+		payload = frame[16:]
+	case LinkTypeLinuxSLL2:
+		if len(frame) < 20 {
+			return UDPDatagram{}, ErrTruncated
+		}
+		etherType = binary.BigEndian.Uint16(frame[0:2])
+		payload = frame[20:]
+	case LinkTypeNull:
+		if len(frame) < 4 {
+			return UDPDatagram{}, ErrTruncated
+		}
+		payload = frame[4:]
+		etherType = ipEtherType(payload)
+	case LinkTypeRaw:
+		payload = frame
+		etherType = ipEtherType(payload)
+	default:
+		return UDPDatagram{}, ErrLinkType
+	}
+
+	switch etherType {
+	case etherTypeIPv4:
+		return decodeIPv4(payload)
+	case etherTypeIPv6:
+		return decodeIPv6(payload)
+	default:
+		return UDPDatagram{}, ErrNotUDP
+	}
+}
+
+// ipEtherType infers the network protocol from the IP version nibble
+func ipEtherType(packet []byte) uint16 {
+	if len(packet) == 0 {
+		return 0
+	}
+	switch packet[0] >> 4 {
+	case 4:
+		return etherTypeIPv4
+	case 6:
+		return etherTypeIPv6
+	}
+	return 0
+}
+
+func decodeIPv4(packet []byte) (UDPDatagram, error) {
+	if len(packet) < 20 {
+		return UDPDatagram{}, ErrTruncated
+	}
+	ihl := int(packet[0]&0x0F) * 4
+	total := int(binary.BigEndian.Uint16(packet[2:4]))
+	if ihl < 20 || len(packet) < ihl {
+		return UDPDatagram{}, ErrTruncated
+	}
+	if packet[9] != protocolUDP {
+		return UDPDatagram{}, ErrNotUDP
+	}
+
+	// More-fragments flag or a non-zero offset
+	if binary.BigEndian.Uint16(packet[6:8])&0x3FFF != 0 {
+		return UDPDatagram{}, ErrFragment
+	}
+
+	// Ignore Ethernet padding beyond the IP total length
+	if total >= ihl && total < len(packet) {
+		packet = packet[:total]
+	}
+
+	d, err := decodeUDP(packet[ihl:])
+	d.SrcIP = net.IP(append([]byte(nil), packet[12:16]...))
+	d.DstIP = net.IP(append([]byte(nil), packet[16:20]...))
+	return d, err
+}
+
+func decodeIPv6(packet []byte) (UDPDatagram, error) {
+	if len(packet) < 40 {
+		return UDPDatagram{}, ErrTruncated
+	}
+	payloadLen := int(binary.BigEndian.Uint16(packet[4:6]))
+	next := packet[6]
+	src := net.IP(append([]byte(nil), packet[8:24]...))
+	dst := net.IP(append([]byte(nil), packet[24:40]...))
+
+	body := packet[40:]
+	if payloadLen < len(body) {
+		body = body[:payloadLen]
+	}
+
+	// Walk the extension headers that may precede UDP
+	for next != protocolUDP {
+		switch next {
+		case 0, 43, 60: // Hop-by-hop, routing, destination options
+			if len(body) < 8 {
+				return UDPDatagram{}, ErrTruncated
+			}
+			size := (int(body[1]) + 1) * 8
+			if len(body) < size {
+				return UDPDatagram{}, ErrTruncated
+			}
+			next = body[0]
+			body = body[size:]
+		case 44: // Fragment
+			return UDPDatagram{}, ErrFragment
+		default:
+			return UDPDatagram{}, ErrNotUDP
+		}
+	}
+
+	d, err := decodeUDP(body)
+	d.SrcIP = src
+	d.DstIP = dst
+	return d, err
+}
+
+func decodeUDP(segment []byte) (UDPDatagram, error) {
+	if len(segment) < 8 {
+		return UDPDatagram{}, ErrTruncated
+	}
+	length := int(binary.BigEndian.Uint16(segment[4:6]))
+	if length < 8 || length > len(segment) {
+		return UDPDatagram{}, ErrTruncated
+	}
+	return UDPDatagram{
+		SrcPort: binary.BigEndian.Uint16(segment[0:2]),
+		DstPort: binary.BigEndian.Uint16(segment[2:4]),
+		Payload: segment[8:length],
+	}, nil
+}
@@ -0,0 +1,124 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+)
+
+// udpSegment builds a UDP header and payload
+func udpSegment(src, dst uint16, payload []byte) []byte {
+	b := binary.BigEndian.AppendUint16(nil, src)
+	b = binary.BigEndian.AppendUint16(b, dst)
+	b = binary.BigEndian.AppendUint16(b, uint16(8+len(payload)))
+	b = append(b, 0, 0)
+	return append(b, payload...)
+}
+
+// ipv4Packet wraps a segment in an IPv4 header with the given fragment field
+func ipv4Packet(protocol byte, fragment uint16, segment []byte) []byte {
+	b := []byte{0x45, 0}
+	b = binary.BigEndian.AppendUint16(b, uint16(20+len(segment)))
+	b = append(b, 0, 0)
+	b = binary.BigEndian.AppendUint16(b, fragment)
+	b = append(b, 64, protocol, 0, 0)
+	b = append(b, 192, 168, 1, 10, 10, 0, 0, 1)
+	return append(b, segment...)
+}
+
+// ipv6Packet wraps a payload in an IPv6 header
+func ipv6Packet(next byte, payload []byte) []byte {
+	b := []byte{0x60, 0, 0, 0}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(payload)))
+	b = append(b, next, 64)
+	b = append(b, net.ParseIP("fd00::1")...)
+	b = append(b, net.ParseIP("fd00::2")...)
+	return append(b, payload...)
+}
+
+// ethernet prefixes an Ethernet header, with VLAN tags when given
+func ethernet(etherType uint16, packet []byte, vlans ...uint16) []byte {
+	b := make([]byte, 12)
+	for _, vlan := range vlans {
+		b = binary.BigEndian.AppendUint16(b, etherTypeVLAN)
+		b = binary.BigEndian.AppendUint16(b, vlan)
+	}
+	b = binary.BigEndian.AppendUint16(b, etherType)
+	return append(b, packet...)
+}
+
+// testDatagrams returns frames of each supported link type carrying the
+// same datagram, over IPv4 and IPv6
+func testDatagrams(payload []byte) (segment []byte, frames map[string][]byte) {
+	segment = udpSegment(40000, 8000, payload)
+	v4 := ipv4Packet(protocolUDP, 0x4000, segment) // Don't-fragment set
+
+	// An IPv6 packet whose UDP segment follows a destination options header
+	v6 := ipv6Packet(60, append([]byte{protocolUDP, 0, 1, 4, 0, 0, 0, 0}, segment...))
+
+	return segment, map[string][]byte{
+		"ethernet":              ethernet(etherTypeIPv4, v4),
+		"ethernet padding":      ethernet(etherTypeIPv4, append(v4, 0, 0, 0, 0)),
+		"vlan tagged":           ethernet(etherTypeIPv4, v4, 10, 20),
+		"raw ipv6 with options": v6,
+		"bsd loopback":          append([]byte{2, 0, 0, 0}, v4...),
+		"linux cooked":          append(append(make([]byte, 14), 0x08, 0x00), v4...),
+		"linux cooked v2":       append(append([]byte{0x86, 0xDD}, make([]byte, 18)...), v6...),
+	}
+}
+
+func TestDecodeUDP(t *testing.T) {
+	linkTypes := map[string]uint16{
+		"ethernet":              LinkTypeEthernet,
+		"ethernet padding":      LinkTypeEthernet,
+		"vlan tagged":           LinkTypeEthernet,
+		"raw ipv6 with options": LinkTypeRaw,
+		"bsd loopback":          LinkTypeNull,
+		"linux cooked":          LinkTypeLinuxSLL,
+		"linux cooked v2":       LinkTypeLinuxSLL2,
+	}
+
+	payload := []byte("telemetry")
+	_, frames := testDatagrams(payload)
+	for name, frame := range frames {
+		d, err := DecodeUDP(linkTypes[name], frame)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		want := "192.168.1.10:40000"
+		if d.SrcIP.To4() == nil {
+			want = "[fd00::1]:40000"
+		}
+		if d.Source() != want || d.DstPort != 8000 || !bytes.Equal(d.Payload, payload) {
+			t.Errorf("%s: decoded %s -> port %d with payload %q", name, d.Source(), d.DstPort, d.Payload)
+		}
+	}
+}
+
+func TestDecodeUDPRejects(t *testing.T) {
+	segment, frames := testDatagrams([]byte("telemetry"))
+	v4 := ipv4Packet(protocolUDP, 0, segment)
+
+	check := func(name string, linkType uint16, frame []byte, want error) {
+		t.Helper()
+		if _, err := DecodeUDP(linkType, frame); !errors.Is(err, want) {
+			t.Errorf("%s: error %v, want %v", name, err, want)
+		}
+	}
+
+	check("tcp", LinkTypeRaw, ipv4Packet(6, 0, segment), ErrNotUDP)
+	check("arp", LinkTypeEthernet, ethernet(0x0806, make([]byte, 28)), ErrNotUDP)
+
+	// Fragments are dropped rather than reassembled
+	check("ipv4 fragment", LinkTypeRaw, ipv4Packet(protocolUDP, 0x2000, segment), ErrFragment)
+	check("ipv6 fragment", LinkTypeRaw, ipv6Packet(44, make([]byte, 16)), ErrFragment)
+
+	check("short ethernet", LinkTypeEthernet, frames["ethernet"][:10], ErrTruncated)
+	check("short ipv4", LinkTypeRaw, v4[:19], ErrTruncated)
+	check("short udp", LinkTypeRaw, v4[:len(v4)-2], ErrTruncated)
+	check("unknown link type", 147, v4, ErrLinkType)
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+)
+
+// InsertCommand adds a command to the command history, setting its ID
+func (r *TelemetryRepository) InsertCommand(c *models.CommandHistory) error {
+	return r.db.Create(c).Error
+}
+
+// UpdateCommand saves the current state of a recorded command
+func (r *TelemetryRepository) UpdateCommand(c *models.CommandHistory) error {
+	return r.db.Save(c).Error
+}
+
+// GetCommandHistory retrieves the most recent commands, newest first
+func (r *TelemetryRepository) GetCommandHistory(limit int) ([]models.CommandHistory, error) {
+	var commands []models.CommandHistory
+	result := r.db.Order("released_at DESC").Limit(limit).Find(&commands)
+	return commands, result.Error
+}
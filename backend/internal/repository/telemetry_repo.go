@@ -1,9 +1,13 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
@@ -14,9 +18,22 @@ import (
 	"gorm.io/gorm"
 )
 
+// quietKey marks a statement whose inserts must not be broadcast
+const quietKey = "telemetry:quiet"
+
 type TelemetryRepository struct {
 	db       *gorm.DB
 	wsServer *websocket.WebSocketServer
+	dataset  string   // Dataset that read queries are limited to
+	source   Source   // Spacecraft and APID that read queries are limited to
+	rollups  []rollup // Continuous aggregates, coarsest first; empty without TimescaleDB
+}
+
+// Source limits read queries to the telemetry of one spacecraft, one APID or
+// both. A nil field matches every value.
+type Source struct {
+	SpacecraftID *uint16
+	APID         *uint16
 }
 
 // NewTelemetryRepository creates a new repository with database connection
@@ -32,10 +49,13 @@ func NewTelemetryRepository(cfg *config.DatabaseConfig, wsServer *websocket.WebS
 	}
 
 	// Run migrations
-	if err := db.AutoMigrate(&models.Telemetry{}); err != nil {
+	if err := db.AutoMigrate(&models.Telemetry{}, &models.RawPacket{}, &models.CommandHistory{}); err != nil {
 		log.Fatal("Failed to migrate database schema:", err)
 	}
 
+	// Partition telemetry by time and keep rollups when TimescaleDB is installed
+	rollups := setupTimescale(db)
+
 	// Setup GORM hooks by registering callbacks
 	setupHooks(db, wsServer)
 
@@ -43,7 +63,47 @@ func NewTelemetryRepository(cfg *config.DatabaseConfig, wsServer *websocket.WebS
 	return &TelemetryRepository{
 		db:       db,
 		wsServer: wsServer,
+		dataset:  models.DatasetLive,
+		rollups:  rollups,
+	}
+}
+
+// WithDataset returns a repository whose read queries are limited to dataset
+func (r *TelemetryRepository) WithDataset(dataset string) *TelemetryRepository {
+	scoped := *r
+	scoped.dataset = dataset
+	return &scoped
+}
+
+// WithSource returns a repository whose read queries are limited to source
+func (r *TelemetryRepository) WithSource(source Source) *TelemetryRepository {
+	scoped := *r
+	scoped.source = source
+	return &scoped
+}
+
+// scopeSQL returns the condition limiting rows to the repository's dataset,
+// bound through placeholder, and to its source. The source's IDs are written
+// into the condition so that it suits named and positional arguments alike.
+func (r *TelemetryRepository) scopeSQL(placeholder string) string {
+	condition := "dataset = " + placeholder
+	if r.source.SpacecraftID != nil {
+		condition += fmt.Sprintf(" AND spacecraft_id = %d", *r.source.SpacecraftID)
+	}
+	if r.source.APID != nil {
+		condition += fmt.Sprintf(" AND apid = %d", *r.source.APID)
 	}
+	return condition
+}
+
+// Transaction runs fn with a repository whose writes belong to one database
+// transaction, committed only if fn returns nil
+func (r *TelemetryRepository) Transaction(fn func(tx *TelemetryRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		scoped := *r
+		scoped.db = tx
+		return fn(&scoped)
+	})
 }
 
 // setupHooks registers GORM callbacks for telemetry events
@@ -51,6 +111,10 @@ func setupHooks(db *gorm.DB, wsServer *websocket.WebSocketServer) {
 	// After Create hook will be called after inserting a new record
 	db.Callback().Create().After("gorm:after_create").Register("after_create_telemetry", func(tx *gorm.DB) {
 		// Only process if this is a telemetry record
+		if quiet, ok := tx.Get(quietKey); ok && quiet.(bool) {
+			return
+		}
+
 		if telemetry, ok := tx.Statement.Model.(*models.Telemetry); ok {
 			// Get the newly created record
 			if tx.Statement.ReflectValue.Kind() == reflect.Struct {
@@ -74,47 +138,452 @@ func (r *TelemetryRepository) InsertTelemetry(t models.Telemetry) error {
 	return nil
 }
 
+// InsertTelemetryQuietly adds a telemetry record without broadcasting it to
+// WebSocket clients, for reprocessed data that is not live
+func (r *TelemetryRepository) InsertTelemetryQuietly(t models.Telemetry) error {
+	result := r.db.Set(quietKey, true).Create(&t)
+	if result.Error != nil {
+		log.Println("Failed to insert telemetry:", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// DeleteTelemetryReceived removes the records of a dataset whose packets were
+// received within a time range, so a replay can overwrite them
+func (r *TelemetryRepository) DeleteTelemetryReceived(dataset string, startTime, endTime time.Time) (int64, error) {
+	result := r.db.Where("dataset = ? AND received_at BETWEEN ? AND ?", dataset, startTime, endTime).
+		Delete(&models.Telemetry{})
+	return result.RowsAffected, result.Error
+}
+
+// InsertRawPacket adds a datagram to the raw packet archive
+func (r *TelemetryRepository) InsertRawPacket(p models.RawPacket) error {
+	return r.db.Create(&p).Error
+}
+
+// InsertRawPackets adds several datagrams to the raw packet archive in one statement
+func (r *TelemetryRepository) InsertRawPackets(packets []models.RawPacket) error {
+	if len(packets) == 0 {
+		return nil
+	}
+	return r.db.Create(&packets).Error
+}
+
+// EachRawPacket streams archived datagrams received within a time range, in
+// receipt order, to fn without loading the whole range into memory
+func (r *TelemetryRepository) EachRawPacket(startTime, endTime time.Time, fn func(models.RawPacket) error) error {
+	rows, err := r.db.Model(&models.RawPacket{}).
+		Where("received_at BETWEEN ? AND ?", startTime, endTime).
+		Order("received_at, id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.RawPacket
+		if err := r.db.ScanRows(rows, &p); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 // GetTelemetry retrieves all telemetry entries within a time range
 func (r *TelemetryRepository) GetTelemetry(startTime, endTime time.Time) ([]models.Telemetry, error) {
 	var telemetry []models.Telemetry
-	result := r.db.Where("timestamp BETWEEN ? AND ?", startTime, endTime).Find(&telemetry)
+	result := r.db.Where(r.scopeSQL("?")+" AND timestamp BETWEEN ? AND ?", r.dataset, startTime, endTime).Find(&telemetry)
 	return telemetry, result.Error
 }
 
+// CountTelemetry counts the telemetry entries within a time range
+func (r *TelemetryRepository) CountTelemetry(startTime, endTime time.Time) (int64, error) {
+	var count int64
+	result := r.db.Model(&models.Telemetry{}).
+		Where(r.scopeSQL("?")+" AND timestamp BETWEEN ? AND ?", r.dataset, startTime, endTime).
+		Count(&count)
+	return count, result.Error
+}
+
+// EachTelemetry streams the telemetry entries within a time range, in time
+// order, to fn without loading the whole range into memory
+func (r *TelemetryRepository) EachTelemetry(startTime, endTime time.Time, fn func(models.Telemetry) error) error {
+	rows, err := r.db.Model(&models.Telemetry{}).
+		Where(r.scopeSQL("?")+" AND timestamp BETWEEN ? AND ?", r.dataset, startTime, endTime).
+		Order("timestamp, id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.Telemetry
+		if err := r.db.ScanRows(rows, &t); err != nil {
+			return err
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 // GetLatestTelemetry retrieves the most recent telemetry entry
 func (r *TelemetryRepository) GetLatestTelemetry() (models.Telemetry, error) {
 	var telemetry models.Telemetry
-	result := r.db.Order("timestamp DESC").First(&telemetry)
+	result := r.db.Where(r.scopeSQL("?"), r.dataset).Order("timestamp DESC").First(&telemetry)
 	return telemetry, result.Error
 }
 
 // GetAnomalies retrieves all anomalous telemetry entries within a time range
 func (r *TelemetryRepository) GetAnomalies(startTime, endTime time.Time) ([]models.Telemetry, error) {
 	var anomalies []models.Telemetry
-	result := r.db.Where("timestamp BETWEEN ? AND ? AND anomaly = ?", startTime, endTime, true).Find(&anomalies)
+	result := r.db.Where(r.scopeSQL("?")+" AND timestamp BETWEEN ? AND ? AND anomaly = ?", r.dataset, startTime, endTime, true).Find(&anomalies)
 	return anomalies, result.Error
 }
 
-// GetAggregatedTelemetry computes statistics for telemetry data
-func (r *TelemetryRepository) GetAggregatedTelemetry(startTime, endTime time.Time) (dto.AggregatedTelemetry, error) {
-	var agg dto.AggregatedTelemetry
+// telemetryParameters are the telemetry columns that statistics are computed for
+var telemetryParameters = []string{"temperature", "battery", "altitude", "signal"}
+
+// Statistics that can be requested from GetAggregatedTelemetry. Percentiles
+// are requested as "p" followed by the percentile, such as p5 or p99.9.
+const (
+	StatMin       = "min"
+	StatMax       = "max"
+	StatAvg       = "avg"
+	StatStdDev    = "stddev"    // Sample standard deviation
+	StatCount     = "count"     // Samples in the range
+	StatAnomalies = "anomalies" // Count and percentage of anomalous samples
+)
+
+// DefaultStatistics are computed when none are requested
+var DefaultStatistics = []string{StatMin, StatMax, StatAvg}
+
+// ErrUnknownStatistic is returned for a statistic that cannot be computed
+var ErrUnknownStatistic = errors.New("unknown statistic")
+
+// statisticSQL returns the aggregate expression computing a per-parameter
+// statistic of column. Percentiles interpolate between samples, as
+// percentile_cont does.
+func statisticSQL(stat, column string) (string, error) {
+	switch stat {
+	case StatMin:
+		return fmt.Sprintf("MIN(%s)", column), nil
+	case StatMax:
+		return fmt.Sprintf("MAX(%s)", column), nil
+	case StatAvg:
+		return fmt.Sprintf("AVG(%s)", column), nil
+	case StatStdDev:
+		return fmt.Sprintf("STDDEV_SAMP(%s)", column), nil
+	}
+
+	if strings.HasPrefix(stat, "p") {
+		p, err := strconv.ParseFloat(stat[1:], 64)
+		if err == nil && p >= 0 && p <= 100 {
+			return fmt.Sprintf("PERCENTILE_CONT(%s) WITHIN GROUP (ORDER BY %s)",
+				strconv.FormatFloat(p/100, 'f', -1, 64), column), nil
+		}
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownStatistic, stat)
+}
+
+// GetAggregatedTelemetry computes the requested statistics for telemetry
+// data within a time range
+func (r *TelemetryRepository) GetAggregatedTelemetry(startTime, endTime time.Time, stats []string) (dto.AggregatedTelemetry, error) {
+	agg := dto.AggregatedTelemetry{
+		StartTime:  startTime,
+		EndTime:    endTime,
+		Parameters: make(map[string]dto.ParameterStats),
+	}
+
+	var perParameter []string
+	for _, stat := range stats {
+		if stat == StatCount || stat == StatAnomalies {
+			continue
+		}
+		if _, err := statisticSQL(stat, "value"); err != nil {
+			return agg, err
+		}
+		perParameter = append(perParameter, stat)
+	}
+
+	// Rollups hold enough to combine everything except percentiles
+	query, args := r.rollupAggregateQuery(startTime, endTime, perParameter)
+	if query == "" {
+		// Range-wide counts come first, then each parameter's statistics in order
+		columns := []string{"COUNT(*)", "COUNT(*) FILTER (WHERE anomaly)"}
+		for _, column := range telemetryParameters {
+			for _, stat := range perParameter {
+				expr, _ := statisticSQL(stat, column)
+				columns = append(columns, expr)
+			}
+		}
+		query = `SELECT ` + strings.Join(columns, ", ") + `
+            FROM telemetries
+            WHERE ` + r.scopeSQL("?") + ` AND timestamp BETWEEN ? AND ?`
+		args = []interface{}{r.dataset, startTime, endTime}
+	}
+
+	rows, err := r.db.Raw(query, args...).Rows()
+	if err != nil {
+		return agg, err
+	}
+	defer rows.Close()
+
+	var count, anomalies int64
+	values := make([]sql.NullFloat64, len(telemetryParameters)*len(perParameter))
+	dest := []interface{}{&count, &anomalies}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	if rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return agg, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return agg, err
+	}
+
+	for i, column := range telemetryParameters {
+		parameter := make(dto.ParameterStats)
+		for j, stat := range perParameter {
+			parameter[stat] = nil
+			if v := values[i*len(perParameter)+j]; v.Valid {
+				parameter[stat] = &v.Float64
+			}
+		}
+		agg.Parameters[column] = parameter
+	}
+
+	for _, stat := range stats {
+		switch stat {
+		case StatCount:
+			agg.Count = &count
+		case StatAnomalies:
+			agg.Anomalies = &dto.AnomalyStats{Count: anomalies}
+			if count > 0 {
+				agg.Anomalies.Percent = 100 * float64(anomalies) / float64(count)
+			}
+		}
+	}
+	return agg, nil
+}
+
+// rollupStatisticSQL returns the expression combining rollup rows into a
+// per-parameter statistic of column, or false if rollups cannot provide it
+func rollupStatisticSQL(stat, column string) (string, bool) {
+	switch stat {
+	case StatMin:
+		return fmt.Sprintf("MIN(min_%s)", column), true
+	case StatMax:
+		return fmt.Sprintf("MAX(max_%s)", column), true
+	case StatAvg:
+		return fmt.Sprintf("SUM(sum_%s) / NULLIF(SUM(count), 0)", column), true
+	case StatStdDev:
+		return fmt.Sprintf("SQRT(GREATEST(SUM(sumsq_%[1]s) - POWER(SUM(sum_%[1]s), 2) / NULLIF(SUM(count), 0), 0) / "+
+			"NULLIF(SUM(count) - 1, 0))", column), true
+	}
+	return "", false
+}
+
+// rollupAggregateQuery builds a query computing the range-wide counts and
+// per-parameter statistics from the rollups, with the edges of the range
+// read from the raw table. It returns an empty query when there are no
+// rollups or they cannot provide one of the statistics.
+func (r *TelemetryRepository) rollupAggregateQuery(startTime, endTime time.Time, perParameter []string) (string, []interface{}) {
+	if len(r.rollups) == 0 {
+		return "", nil
+	}
+
+	rawColumns := []string{"COUNT(*) AS count", "SUM(CASE WHEN anomaly THEN 1 ELSE 0 END) AS anomalies"}
+	rollupColumns := []string{"count", "anomalies"}
+	columns := []string{"CAST(COALESCE(SUM(count), 0) AS bigint)", "CAST(COALESCE(SUM(anomalies), 0) AS bigint)"}
+	for _, p := range telemetryParameters {
+		rawColumns = append(rawColumns, fmt.Sprintf(
+			"MIN(%[1]s) AS min_%[1]s, MAX(%[1]s) AS max_%[1]s, SUM(CAST(%[1]s AS double precision)) AS sum_%[1]s, "+
+				"SUM(POWER(CAST(%[1]s AS double precision), 2)) AS sumsq_%[1]s", p))
+		rollupColumns = append(rollupColumns, fmt.Sprintf("min_%[1]s, max_%[1]s, sum_%[1]s, sumsq_%[1]s", p))
+		for _, stat := range perParameter {
+			expr, ok := rollupStatisticSQL(stat, p)
+			if !ok {
+				return "", nil
+			}
+			columns = append(columns, expr)
+		}
+	}
+
+	var parts []string
+	var args []interface{}
+	segments := splitRange(startTime, endTime, r.rollups)
+	for i, seg := range segments {
+		switch {
+		case seg.rollup != nil:
+			parts = append(parts, `SELECT `+strings.Join(rollupColumns, ", ")+` FROM `+seg.rollup.view+`
+                WHERE `+r.scopeSQL("?")+` AND bucket >= ? AND bucket < ?`)
+		case i == len(segments)-1:
+			parts = append(parts, `SELECT `+strings.Join(rawColumns, ", ")+` FROM telemetries
+                WHERE `+r.scopeSQL("?")+` AND timestamp >= ? AND timestamp <= ?`)
+		case seg.start.Before(seg.end):
+			parts = append(parts, `SELECT `+strings.Join(rawColumns, ", ")+` FROM telemetries
+                WHERE `+r.scopeSQL("?")+` AND timestamp >= ? AND timestamp < ?`)
+		default:
+			continue
+		}
+		args = append(args, r.dataset, seg.start, seg.end)
+	}
+
+	query := `SELECT ` + strings.Join(columns, ", ") + `
+        FROM (` + strings.Join(parts, "\n            UNION ALL ") + `) AS parts`
+	return query, args
+}
+
+// bucketOrigin is where buckets that are not a whole calendar unit are
+// counted from. It is the default origin of TimescaleDB's time_bucket, a
+// Monday, so buckets fall on the same boundaries with or without it.
+var bucketOrigin = time.Date(2000, time.January, 3, 0, 0, 0, 0, time.UTC)
+
+// calendarUnits are the bucket widths date_trunc computes directly
+var calendarUnits = map[time.Duration]string{
+	time.Second:        "second",
+	time.Minute:        "minute",
+	time.Hour:          "hour",
+	24 * time.Hour:     "day",
+	7 * 24 * time.Hour: "week",
+}
+
+// bucketSQL returns the SQL expression giving the start of the bucket of
+// the given width that a timestamptz column falls in. TimescaleDB's
+// time_bucket is used when the rollups are, so that buckets match theirs;
+// otherwise date_trunc in UTC for whole calendar units, and whole widths
+// from bucketOrigin for the rest.
+func (r *TelemetryRepository) bucketSQL(column string, width time.Duration) string {
+	seconds := int64(width / time.Second)
+	if len(r.rollups) > 0 {
+		return fmt.Sprintf("time_bucket(INTERVAL '%d seconds', %s)", seconds, column)
+	}
+	if unit, ok := calendarUnits[width]; ok {
+		return fmt.Sprintf("(date_trunc('%s', %s AT TIME ZONE 'UTC') AT TIME ZONE 'UTC')", unit, column)
+	}
+	return fmt.Sprintf("TO_TIMESTAMP(FLOOR((EXTRACT(EPOCH FROM %[1]s) - %[2]d) / %[3]d) * %[3]d + %[2]d)",
+		column, bucketOrigin.Unix(), seconds)
+}
+
+// GetBucketedTelemetry computes statistics for consecutive time buckets of
+// the given width. Buckets start on whole calendar units in UTC, weeks on
+// Mondays, and other widths on whole multiples from bucketOrigin, as
+// TimescaleDB's time_bucket places them, so the first bucket may start
+// before startTime. Every bucket up to endTime is returned, with nil
+// statistics where there is no data. When a rollup's buckets divide the width
+// the statistics are combined from it, and the edge buckets then cover whole
+// rollup buckets rather than stopping at startTime and endTime.
+func (r *TelemetryRepository) GetBucketedTelemetry(startTime, endTime time.Time, width time.Duration) ([]dto.TelemetryBucket, error) {
+	source := r.rollupFor(width)
+
+	var columns, selected []string
+	for _, p := range telemetryParameters {
+		selected = append(selected, fmt.Sprintf(
+			"stats.min_%[1]s, stats.max_%[1]s, stats.avg_%[1]s, stats.first_%[1]s, stats.last_%[1]s", p))
+		if source != nil {
+			columns = append(columns, fmt.Sprintf(
+				"MIN(min_%[1]s) AS min_%[1]s, MAX(max_%[1]s) AS max_%[1]s, SUM(sum_%[1]s) / SUM(count) AS avg_%[1]s, "+
+					"(ARRAY_AGG(first_%[1]s ORDER BY first_time))[1] AS first_%[1]s, "+
+					"(ARRAY_AGG(last_%[1]s ORDER BY last_time DESC))[1] AS last_%[1]s", p))
+			continue
+		}
+		columns = append(columns, fmt.Sprintf(
+			"MIN(%[1]s) AS min_%[1]s, MAX(%[1]s) AS max_%[1]s, AVG(%[1]s) AS avg_%[1]s, "+
+				"(ARRAY_AGG(%[1]s ORDER BY timestamp, id))[1] AS first_%[1]s, "+
+				"(ARRAY_AGG(%[1]s ORDER BY timestamp DESC, id DESC))[1] AS last_%[1]s", p))
+	}
+
+	stats := `
+            SELECT
+                ` + r.bucketSQL("timestamp", width) + ` AS bucket,
+                COUNT(*) AS count, ` + strings.Join(columns, ", ") + `
+            FROM telemetries
+            WHERE ` + r.scopeSQL("@dataset") + ` AND timestamp BETWEEN @start AND @end
+            GROUP BY 1`
+	from := startTime
+	if source != nil {
+		stats = `
+            SELECT
+                ` + r.bucketSQL("bucket", width) + ` AS bucket,
+                CAST(SUM(count) AS bigint) AS count, ` + strings.Join(columns, ", ") + `
+            FROM ` + source.view + `
+            WHERE ` + r.scopeSQL("@dataset") + ` AND bucket BETWEEN @from AND @end
+            GROUP BY 1`
+		from = startTime.Truncate(source.resolution)
+	}
+
 	query := `
-        SELECT 
-            MIN(temperature) AS min_temperature, MAX(temperature) AS max_temperature, AVG(temperature) AS avg_temperature,
-            MIN(battery) AS min_battery, MAX(battery) AS max_battery, AVG(battery) AS avg_battery,
-            MIN(altitude) AS min_altitude, MAX(altitude) AS max_altitude, AVG(altitude) AS avg_altitude,
-            MIN(signal) AS min_signal, MAX(signal) AS max_signal, AVG(signal) AS avg_signal
-        FROM telemetries
-        WHERE timestamp BETWEEN ? AND ?
+        WITH series AS (
+            SELECT generate_series(
+                ` + r.bucketSQL("CAST(@start AS timestamptz)", width) + `,
+                CAST(@end AS timestamptz),
+                @width * INTERVAL '1 second'
+            ) AS bucket
+        ), stats AS (` + stats + `
+        )
+        SELECT series.bucket, COALESCE(stats.count, 0), ` + strings.Join(selected, ", ") + `
+        FROM series LEFT JOIN stats ON stats.bucket = series.bucket
+        ORDER BY series.bucket
     `
-	result := r.db.Raw(query, startTime, endTime).Scan(&agg)
-	return agg, result.Error
+	rows, err := r.db.Raw(query, map[string]interface{}{
+		"dataset": r.dataset,
+		"start":   startTime,
+		"from":    from,
+		"end":     endTime,
+		"width":   int64(width / time.Second),
+	}).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []dto.TelemetryBucket{}
+	for rows.Next() {
+		var bucket dto.TelemetryBucket
+		values := make([]sql.NullFloat64, 5*len(telemetryParameters))
+		dest := []interface{}{&bucket.Start, &bucket.Count}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		if bucket.Count > 0 {
+			stats := make([]*dto.BucketStats, len(telemetryParameters))
+			for i := range stats {
+				v := values[5*i : 5*i+5]
+				stats[i] = &dto.BucketStats{
+					Min:   float32(v[0].Float64),
+					Max:   float32(v[1].Float64),
+					Avg:   float32(v[2].Float64),
+					First: float32(v[3].Float64),
+					Last:  float32(v[4].Float64),
+				}
+			}
+			bucket.Temperature, bucket.Battery, bucket.Altitude, bucket.Signal = stats[0], stats[1], stats[2], stats[3]
+		}
+		bucket.Start = bucket.Start.UTC()
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
 }
 
 // GetLastTelemetry retrieves the last N telemetry records
 func (r *TelemetryRepository) GetLastTelemetry(count int) ([]models.Telemetry, error) {
 	var telemetryData []models.Telemetry
-	err := r.db.Order("timestamp DESC").Limit(count).Find(&telemetryData).Error
+	err := r.db.Where(r.scopeSQL("?"), r.dataset).Order("timestamp DESC").Limit(count).Find(&telemetryData).Error
 	return telemetryData, err
 }
 
@@ -123,7 +592,7 @@ func (r *TelemetryRepository) GetPaginatedTelemetry(page, limit int, startTime,
 	var telemetry []models.Telemetry
 	var total int64
 
-	db := r.db.Model(&models.Telemetry{})
+	db := r.db.Model(&models.Telemetry{}).Where(r.scopeSQL("?"), r.dataset)
 
 	// Apply time filters if provided
 	if startTime != nil && endTime != nil {
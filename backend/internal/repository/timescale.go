@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// rollup is a TimescaleDB continuous aggregate of the telemetry table. Each
+// row summarises one dataset, spacecraft and APID over one bucket, keeping
+// sums rather than averages so that rows can be combined into coarser
+// buckets or across sources.
+type rollup struct {
+	view       string
+	resolution time.Duration
+	offset     time.Duration // How far back the refresh policy looks
+	schedule   time.Duration // How often the refresh policy runs
+}
+
+// telemetryRollups are the continuous aggregates created on TimescaleDB,
+// coarsest first
+var telemetryRollups = []rollup{
+	{view: "telemetry_1h", resolution: time.Hour, offset: 7 * 24 * time.Hour, schedule: time.Hour},
+	{view: "telemetry_1m", resolution: time.Minute, offset: 24 * time.Hour, schedule: time.Minute},
+}
+
+// setupTimescale converts the telemetry table to a hypertable and creates
+// its continuous aggregates when TimescaleDB is available. It returns the
+// rollups queries may use, or nil to query the raw table only.
+func setupTimescale(db *gorm.DB) []rollup {
+	var version sql.NullString
+	db.Raw("SELECT default_version FROM pg_available_extensions WHERE name = 'timescaledb'").Scan(&version)
+	if !version.Valid {
+		log.Println("TimescaleDB not available; queries will use the raw telemetry table")
+		return nil
+	}
+
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS timescaledb").Error; err != nil {
+		log.Printf("Failed to enable TimescaleDB, using the raw telemetry table: %v", err)
+		return nil
+	}
+	if err := createHypertable(db); err != nil {
+		log.Printf("Failed to create telemetry hypertable, using the raw telemetry table: %v", err)
+		return nil
+	}
+
+	for _, r := range telemetryRollups {
+		if err := dropOutdatedRollup(db, r); err != nil {
+			log.Printf("Failed to replace continuous aggregate %s, using the raw telemetry table: %v", r.view, err)
+			return nil
+		}
+		if err := createRollup(db, r); err != nil {
+			log.Printf("Failed to create continuous aggregate %s, using the raw telemetry table: %v", r.view, err)
+			return nil
+		}
+	}
+
+	log.Println("TimescaleDB hypertable and continuous aggregates ready")
+	return telemetryRollups
+}
+
+// createHypertable partitions the telemetry table on timestamp. Unique
+// constraints on a hypertable must include the partitioning column, so the
+// primary key is widened first.
+func createHypertable(db *gorm.DB) error {
+	var count int64
+	err := db.Raw("SELECT COUNT(*) FROM timescaledb_information.hypertables WHERE hypertable_name = 'telemetries'").
+		Scan(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+
+	log.Println("Converting telemetries to a hypertable...")
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			"ALTER TABLE telemetries DROP CONSTRAINT IF EXISTS telemetries_pkey",
+			"ALTER TABLE telemetries ADD PRIMARY KEY (id, timestamp)",
+			"SELECT create_hypertable('telemetries', 'timestamp', chunk_time_interval => INTERVAL '1 day', migrate_data => true)",
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// dropOutdatedRollup drops a continuous aggregate created before rows were
+// kept per spacecraft and APID, so that it is created again with them. The
+// new one is filled from the raw table when created.
+func dropOutdatedRollup(db *gorm.DB, r rollup) error {
+	var state struct {
+		Found    bool
+		UpToDate bool
+	}
+	err := db.Raw(`
+        SELECT to_regclass(?) IS NOT NULL AS found,
+            EXISTS (SELECT 1 FROM pg_attribute
+                WHERE attrelid = to_regclass(?) AND attname = 'apid' AND NOT attisdropped) AS up_to_date`,
+		r.view, r.view).Scan(&state).Error
+	if err != nil || !state.Found || state.UpToDate {
+		return err
+	}
+
+	log.Printf("Recreating continuous aggregate %s with spacecraft and APID columns...", r.view)
+	return db.Exec(fmt.Sprintf("DROP MATERIALIZED VIEW %s", r.view)).Error
+}
+
+// createRollup creates a continuous aggregate and its refresh policy. The
+// aggregate is filled on creation, and reads combine it with rows newer than
+// the last refresh.
+func createRollup(db *gorm.DB, r rollup) error {
+	columns := []string{
+		"COUNT(*) AS count",
+		"SUM(CASE WHEN anomaly THEN 1 ELSE 0 END) AS anomalies",
+		"MIN(timestamp) AS first_time",
+		"MAX(timestamp) AS last_time",
+	}
+	for _, p := range telemetryParameters {
+		columns = append(columns, fmt.Sprintf(
+			"MIN(%[1]s) AS min_%[1]s, MAX(%[1]s) AS max_%[1]s, "+
+				"SUM(CAST(%[1]s AS double precision)) AS sum_%[1]s, "+
+				"SUM(POWER(CAST(%[1]s AS double precision), 2)) AS sumsq_%[1]s, "+
+				"first(%[1]s, timestamp) AS first_%[1]s, last(%[1]s, timestamp) AS last_%[1]s", p))
+	}
+
+	view := fmt.Sprintf(`
+        CREATE MATERIALIZED VIEW IF NOT EXISTS %s
+        WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+        SELECT time_bucket(INTERVAL '%d seconds', timestamp) AS bucket, dataset, spacecraft_id, apid, %s
+        FROM telemetries
+        GROUP BY bucket, dataset, spacecraft_id, apid
+        WITH DATA`, r.view, int64(r.resolution/time.Second), strings.Join(columns, ", "))
+	if err := db.Exec(view).Error; err != nil {
+		return err
+	}
+
+	policy := fmt.Sprintf(`
+        SELECT add_continuous_aggregate_policy('%s',
+            start_offset => INTERVAL '%d seconds',
+            end_offset => INTERVAL '%d seconds',
+            schedule_interval => INTERVAL '%d seconds',
+            if_not_exists => true)`,
+		r.view, int64(r.offset/time.Second), int64(r.resolution/time.Second), int64(r.schedule/time.Second))
+	return db.Exec(policy).Error
+}
+
+// rollupFor returns the coarsest rollup whose buckets divide width evenly,
+// or nil when buckets of that width must be computed from the raw table
+func (r *TelemetryRepository) rollupFor(width time.Duration) *rollup {
+	for i := range r.rollups {
+		if width%r.rollups[i].resolution == 0 {
+			return &r.rollups[i]
+		}
+	}
+	return nil
+}
+
+// rangeSegment is part of a time range read from one source: a rollup, or
+// the raw table when rollup is nil. Segments run from start up to end, and
+// only the last one includes end.
+type rangeSegment struct {
+	rollup     *rollup
+	start, end time.Time
+}
+
+// splitRange divides a time range so that the whole buckets of the coarsest
+// rollup are read from it, the partial buckets at either edge from finer
+// rollups, and the remainder from the raw table
+func splitRange(start, end time.Time, rollups []rollup) []rangeSegment {
+	if len(rollups) == 0 {
+		return []rangeSegment{{start: start, end: end}}
+	}
+
+	r := &rollups[0]
+	innerStart := start.Truncate(r.resolution)
+	if innerStart.Before(start) {
+		innerStart = innerStart.Add(r.resolution)
+	}
+	innerEnd := end.Truncate(r.resolution)
+	if !innerStart.Before(innerEnd) {
+		return splitRange(start, end, rollups[1:])
+	}
+
+	segments := splitRange(start, innerStart, rollups[1:])
+	segments = append(segments, rangeSegment{rollup: r, start: innerStart, end: innerEnd})
+	return append(segments, splitRange(innerEnd, end, rollups[1:])...)
+}
+
+// TimestampRangeReceived returns the earliest and latest timestamps of the
+// records of a dataset received within a time range. ok is false when there
+// are none.
+func (r *TelemetryRepository) TimestampRangeReceived(dataset string, startTime, endTime time.Time) (first, last time.Time, ok bool, err error) {
+	var span struct {
+		FirstTimestamp sql.NullTime
+		LastTimestamp  sql.NullTime
+	}
+	err = r.db.Raw("SELECT MIN(timestamp) AS first_timestamp, MAX(timestamp) AS last_timestamp FROM telemetries WHERE dataset = ? AND received_at BETWEEN ? AND ?",
+		dataset, startTime, endTime).Scan(&span).Error
+	if err != nil || !span.FirstTimestamp.Valid {
+		return first, last, false, err
+	}
+	return span.FirstTimestamp.Time, span.LastTimestamp.Time, true, nil
+}
+
+// RefreshRollups recomputes the continuous aggregates over a time range.
+// Refresh policies only revisit recent buckets, so this is needed after
+// older telemetry is rewritten.
+func (r *TelemetryRepository) RefreshRollups(startTime, endTime time.Time) error {
+	for _, rollup := range r.rollups {
+		from := startTime.Truncate(rollup.resolution)
+		to := endTime.Truncate(rollup.resolution).Add(rollup.resolution)
+		call := fmt.Sprintf("CALL refresh_continuous_aggregate('%s', CAST(? AS timestamptz), CAST(? AS timestamptz))", rollup.view)
+		err := r.db.Exec(call, from, to).Error
+		if err != nil {
+			return fmt.Errorf("refresh %s: %w", rollup.view, err)
+		}
+	}
+	return nil
+}
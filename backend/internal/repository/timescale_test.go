@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/pkg/dto"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// rollupTestRepo connects to the TimescaleDB database named by
+// TEST_DATABASE_DSN, skipping the test when none is configured
+func rollupTestRepo(t *testing.T) *TelemetryRepository {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Telemetry{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	rollups := setupTimescale(db)
+	if len(rollups) == 0 {
+		t.Skip("TimescaleDB continuous aggregates not available")
+	}
+	return &TelemetryRepository{db: db, dataset: fmt.Sprintf("rollup-test-%d", time.Now().UnixNano()), rollups: rollups}
+}
+
+// seedRollupTest writes three hours of telemetry from two spacecraft and
+// three APIDs, with samples every 37 seconds so that they fall unevenly
+// across buckets, and refreshes the rollups over them
+func seedRollupTest(t *testing.T, repo *TelemetryRepository, start, end time.Time) {
+	t.Helper()
+	sources := []struct{ spacecraft, apid uint16 }{{1, 16}, {1, 18}, {2, 32}}
+
+	var records []models.Telemetry
+	for i, ts := 0, start; ts.Before(end); i, ts = i+1, ts.Add(37*time.Second) {
+		for j, s := range sources {
+			records = append(records, models.Telemetry{
+				SpacecraftID: s.spacecraft,
+				APID:         s.apid,
+				Timestamp:    ts.Add(time.Duration(j) * time.Second),
+				Temperature:  float32(20 + (i*7+j*3)%31),
+				Battery:      float32(50 + (i*11+j)%47),
+				Altitude:     float32(500 + (i*13+j*5)%101),
+				Signal:       float32(-60 + (i*3+j)%23),
+				Anomaly:      (i+j)%17 == 0,
+				ReceivedAt:   ts,
+				Dataset:      repo.dataset,
+			})
+		}
+	}
+	if err := repo.db.CreateInBatches(records, 500).Error; err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	t.Cleanup(func() {
+		repo.db.Where("dataset = ?", repo.dataset).Delete(&models.Telemetry{})
+		repo.RefreshRollups(start, end)
+	})
+	if err := repo.RefreshRollups(start, end); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+}
+
+// nearlyEqual reports whether two statistics agree to within float32 precision
+func nearlyEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-4*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+// TestRollupsMatchRawQueries checks that statistics combined from the
+// continuous aggregates agree with those computed from the raw table, for
+// every source filter
+func TestRollupsMatchRawQueries(t *testing.T) {
+	repo := rollupTestRepo(t)
+	start := time.Date(2020, time.January, 6, 0, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Hour)
+	seedRollupTest(t, repo, start, end)
+
+	id := func(v uint16) *uint16 { return &v }
+	sources := []struct {
+		name   string
+		source Source
+	}{
+		{"all", Source{}},
+		{"spacecraft", Source{SpacecraftID: id(1)}},
+		{"apid", Source{APID: id(32)}},
+		{"spacecraft and apid", Source{SpacecraftID: id(1), APID: id(18)}},
+		{"no match", Source{SpacecraftID: id(2), APID: id(16)}},
+	}
+
+	for _, tc := range sources {
+		t.Run(tc.name, func(t *testing.T) {
+			rolled := repo.WithSource(tc.source)
+			raw := *rolled
+			raw.rollups = nil
+
+			// Edges inside rollup buckets exercise each source of splitRange
+			from, to := start.Add(17*time.Minute+30*time.Second), end.Add(-16*time.Minute-5*time.Second)
+			stats := []string{StatMin, StatMax, StatAvg, StatStdDev, StatCount, StatAnomalies}
+			want, err := raw.GetAggregatedTelemetry(from, to, stats)
+			if err != nil {
+				t.Fatalf("raw aggregate: %v", err)
+			}
+			got, err := rolled.GetAggregatedTelemetry(from, to, stats)
+			if err != nil {
+				t.Fatalf("rollup aggregate: %v", err)
+			}
+			compareAggregates(t, got, want)
+
+			// Bucket edges follow rollup buckets, so the whole seeded range is read
+			for _, width := range []time.Duration{time.Minute, 5 * time.Minute, time.Hour, 2 * time.Hour} {
+				want, err := raw.GetBucketedTelemetry(start, end.Add(-time.Second), width)
+				if err != nil {
+					t.Fatalf("raw %s buckets: %v", width, err)
+				}
+				got, err := rolled.GetBucketedTelemetry(start, end.Add(-time.Second), width)
+				if err != nil {
+					t.Fatalf("rollup %s buckets: %v", width, err)
+				}
+				compareBuckets(t, width, got, want)
+			}
+		})
+	}
+}
+
+// compareAggregates fails the test where two range-wide results differ
+func compareAggregates(t *testing.T, got, want dto.AggregatedTelemetry) {
+	t.Helper()
+	if *got.Count != *want.Count || got.Anomalies.Count != want.Anomalies.Count {
+		t.Errorf("count %d, anomalies %d; raw table gives %d and %d",
+			*got.Count, got.Anomalies.Count, *want.Count, want.Anomalies.Count)
+	}
+	for parameter, stats := range want.Parameters {
+		for stat, w := range stats {
+			g := got.Parameters[parameter][stat]
+			switch {
+			case (g == nil) != (w == nil):
+				t.Errorf("%s %s: got %v, raw table gives %v", parameter, stat, g, w)
+			case g != nil && !nearlyEqual(*g, *w):
+				t.Errorf("%s %s: got %g, raw table gives %g", parameter, stat, *g, *w)
+			}
+		}
+	}
+}
+
+// compareBuckets fails the test where two bucketed results differ
+func compareBuckets(t *testing.T, width time.Duration, got, want []dto.TelemetryBucket) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: %d buckets, raw table gives %d", width, len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if !g.Start.Equal(w.Start) || g.Count != w.Count {
+			t.Fatalf("%s bucket %d: starts %s with %d samples; raw table gives %s with %d",
+				width, i, g.Start, g.Count, w.Start, w.Count)
+		}
+		parameters := []struct {
+			name     string
+			got, raw *dto.BucketStats
+		}{
+			{"temperature", g.Temperature, w.Temperature},
+			{"battery", g.Battery, w.Battery},
+			{"altitude", g.Altitude, w.Altitude},
+			{"signal", g.Signal, w.Signal},
+		}
+		for _, p := range parameters {
+			if (p.got == nil) != (p.raw == nil) {
+				t.Fatalf("%s bucket %d %s: got %v, raw table gives %v", width, i, p.name, p.got, p.raw)
+			}
+			if p.got == nil {
+				continue
+			}
+			if p.got.Min != p.raw.Min || p.got.Max != p.raw.Max || p.got.First != p.raw.First || p.got.Last != p.raw.Last ||
+				!nearlyEqual(float64(p.got.Avg), float64(p.raw.Avg)) {
+				t.Errorf("%s bucket %d %s: got %+v, raw table gives %+v", width, i, p.name, *p.got, *p.raw)
+			}
+		}
+	}
+}
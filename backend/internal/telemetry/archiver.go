@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"log"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/repository"
+)
+
+// archiveFlushInterval is the longest a queued datagram waits to be archived
+const archiveFlushInterval = 500 * time.Millisecond
+
+// archiver stores raw datagrams from a bounded queue in batches, so bursts
+// of traffic cannot pile up goroutines and database connections. Datagrams
+// that arrive while the queue is full are dropped and counted.
+type archiver struct {
+	repo      *repository.TelemetryRepository
+	queue     chan models.RawPacket
+	batchSize int
+}
+
+// newArchiver creates an archiver holding up to queueSize datagrams
+func newArchiver(repo *repository.TelemetryRepository, queueSize, batchSize int) *archiver {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &archiver{
+		repo:      repo,
+		queue:     make(chan models.RawPacket, queueSize),
+		batchSize: batchSize,
+	}
+}
+
+// Enqueue queues a datagram for archiving without blocking. It returns
+// false if the queue is full and the datagram was dropped.
+func (a *archiver) Enqueue(raw models.RawPacket) bool {
+	select {
+	case a.queue <- raw:
+		return true
+	default:
+		return false
+	}
+}
+
+// run writes queued datagrams whenever a batch fills or the flush interval passes
+func (a *archiver) run() {
+	ticker := time.NewTicker(archiveFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.RawPacket, 0, a.batchSize)
+	for {
+		select {
+		case raw := <-a.queue:
+			batch = append(batch, raw)
+			if len(batch) < a.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		if err := a.repo.InsertRawPackets(batch); err != nil {
+			log.Printf("Failed to archive %d raw packets: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+}
@@ -0,0 +1,34 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+)
+
+func TestArchiverDropsWhenFull(t *testing.T) {
+	// Nothing drains the queue until run is started
+	a := newArchiver(nil, 3, 10)
+	for i := 0; i < 3; i++ {
+		if !a.Enqueue(models.RawPacket{Data: []byte{byte(i)}}) {
+			t.Fatalf("datagram %d dropped from a queue with room", i)
+		}
+	}
+	if a.Enqueue(models.RawPacket{Data: []byte{3}}) {
+		t.Fatal("datagram queued beyond the queue size")
+	}
+
+	// Queued datagrams keep their arrival order
+	for i := 0; i < 3; i++ {
+		if raw := <-a.queue; raw.Data[0] != byte(i) {
+			t.Fatalf("datagram %d dequeued in position %d", raw.Data[0], i)
+		}
+	}
+}
+
+func TestArchiverSizeLimits(t *testing.T) {
+	a := newArchiver(nil, 0, -5)
+	if cap(a.queue) != 1 || a.batchSize != 1 {
+		t.Errorf("queue of %d and batches of %d, want 1 and 1", cap(a.queue), a.batchSize)
+	}
+}
@@ -0,0 +1,209 @@
+package clock
+
+import (
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+)
+
+// minFitSamples is the number of pairs needed before the model is trusted
+const minFitSamples = 10
+
+// jumpResetCount is the number of consecutive jumps after which the onboard
+// clock is assumed to have been reset or steered and the model is rebuilt
+const jumpResetCount = 5
+
+// defaultLaunchTime bounds plausible onboard times when no launch time is configured
+var defaultLaunchTime = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Correlation describes the fitted onboard-to-ground clock model of a spacecraft.
+// Ground time is modelled as onboard + Offset + Drift * (onboard - ReferenceTime).
+type Correlation struct {
+	SpacecraftID  uint16    `json:"spacecraft_id"`
+	Offset        float64   `json:"offset_seconds"`
+	Drift         float64   `json:"drift"`
+	ReferenceTime time.Time `json:"reference_time"`
+	Samples       int       `json:"samples"`
+	RMSResidual   float64   `json:"rms_residual_seconds"`
+	Fitted        bool      `json:"fitted"`
+	Rejected      uint64    `json:"rejected"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// pair is one onboard time matched with its ground receipt time
+type pair struct {
+	onboard time.Time
+	ground  time.Time
+}
+
+// spacecraftClock holds the correlation state of one spacecraft
+type spacecraftClock struct {
+	pairs []pair
+	jumps int
+	model Correlation
+}
+
+// Correlator fits a linear offset and drift model between onboard and
+// ground time for every spacecraft, flags implausible onboard times and
+// optionally corrects timestamps with the model
+type Correlator struct {
+	mu              sync.Mutex
+	clocks          map[uint16]*spacecraftClock
+	window          int
+	correct         bool
+	launchTime      time.Time
+	futureTolerance time.Duration
+	jumpThreshold   time.Duration
+}
+
+// NewCorrelator creates a correlator using the clock settings in cfg
+func NewCorrelator(cfg *config.IngestConfig) *Correlator {
+	window := cfg.ClockWindow
+	if window < minFitSamples {
+		window = minFitSamples
+	}
+
+	launchTime := cfg.LaunchTime
+	if launchTime.IsZero() {
+		launchTime = defaultLaunchTime
+	}
+
+	return &Correlator{
+		clocks:          make(map[uint16]*spacecraftClock),
+		window:          window,
+		correct:         cfg.ClockCorrection,
+		launchTime:      launchTime,
+		futureTolerance: cfg.ClockFutureTolerance,
+		jumpThreshold:   cfg.ClockJumpThreshold,
+	}
+}
+
+// Correlate records an onboard/ground time pair and returns the timestamp to
+// store along with its time quality flag. Implausible onboard times are
+// flagged and excluded from the model.
+func (c *Correlator) Correlate(spacecraftID uint16, onboard, ground time.Time) (time.Time, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sc, ok := c.clocks[spacecraftID]
+	if !ok {
+		sc = &spacecraftClock{model: Correlation{SpacecraftID: spacecraftID}}
+		c.clocks[spacecraftID] = sc
+	}
+
+	quality := c.check(sc, onboard, ground)
+	if quality != models.TimeQualityGood {
+		sc.model.Rejected++
+		if c.correct {
+			// The onboard clock cannot be trusted, so fall back to receipt time
+			return ground, quality
+		}
+		return onboard, quality
+	}
+
+	sc.jumps = 0
+	sc.pairs = append(sc.pairs, pair{onboard: onboard, ground: ground})
+	if len(sc.pairs) > c.window {
+		sc.pairs = sc.pairs[len(sc.pairs)-c.window:]
+	}
+	sc.fit(ground)
+
+	if c.correct && sc.model.Fitted {
+		return sc.model.toGround(onboard), models.TimeQualityCorrected
+	}
+	return onboard, models.TimeQualityGood
+}
+
+// Correlations returns the current model of every spacecraft
+func (c *Correlator) Correlations() []Correlation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make([]Correlation, 0, len(c.clocks))
+	for _, sc := range c.clocks {
+		result = append(result, sc.model)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].SpacecraftID < result[j].SpacecraftID
+	})
+	return result
+}
+
+// check classifies an onboard time as plausible or not
+func (c *Correlator) check(sc *spacecraftClock, onboard, ground time.Time) string {
+	if onboard.Before(c.launchTime) {
+		return models.TimeQualityPreLaunch
+	}
+	if onboard.Sub(ground) > c.futureTolerance {
+		return models.TimeQualityFuture
+	}
+
+	if sc.model.Fitted {
+		predicted := sc.model.toGround(onboard)
+		if residual := ground.Sub(predicted); residual > c.jumpThreshold || residual < -c.jumpThreshold {
+			sc.jumps++
+			if sc.jumps >= jumpResetCount {
+				// Consistent jumps mean the clock really moved; start a new model
+				log.Printf("Clock: SCID %d onboard time jumped by %s, rebuilding correlation",
+					sc.model.SpacecraftID, residual)
+				sc.pairs = nil
+				sc.jumps = 0
+				sc.model = Correlation{SpacecraftID: sc.model.SpacecraftID, Rejected: sc.model.Rejected}
+			}
+			return models.TimeQualityJump
+		}
+	}
+
+	return models.TimeQualityGood
+}
+
+// fit recomputes the least-squares offset and drift over the stored pairs
+func (sc *spacecraftClock) fit(now time.Time) {
+	n := len(sc.pairs)
+	ref := sc.pairs[0].onboard
+
+	var sumX, sumY, sumXX, sumXY float64
+	for _, p := range sc.pairs {
+		x := p.onboard.Sub(ref).Seconds()
+		y := p.ground.Sub(p.onboard).Seconds()
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+
+	fn := float64(n)
+	offset := sumY / fn
+	drift := 0.0
+	if denom := fn*sumXX - sumX*sumX; denom > 0 {
+		drift = (fn*sumXY - sumX*sumY) / denom
+		offset = (sumY - drift*sumX) / fn
+	}
+
+	var sumSq float64
+	for _, p := range sc.pairs {
+		x := p.onboard.Sub(ref).Seconds()
+		r := p.ground.Sub(p.onboard).Seconds() - (offset + drift*x)
+		sumSq += r * r
+	}
+
+	sc.model.Offset = offset
+	sc.model.Drift = drift
+	sc.model.ReferenceTime = ref
+	sc.model.Samples = n
+	sc.model.RMSResidual = math.Sqrt(sumSq / fn)
+	sc.model.Fitted = n >= minFitSamples
+	sc.model.UpdatedAt = now
+}
+
+// toGround maps an onboard time to ground time using the model
+func (m Correlation) toGround(onboard time.Time) time.Time {
+	x := onboard.Sub(m.ReferenceTime).Seconds()
+	correction := m.Offset + m.Drift*x
+	return onboard.Add(time.Duration(correction * float64(time.Second)))
+}
@@ -0,0 +1,190 @@
+package clock
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+)
+
+var start = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestCorrelator(correct bool) *Correlator {
+	return NewCorrelator(&config.IngestConfig{
+		ClockWindow:          20,
+		ClockFutureTolerance: 5 * time.Second,
+		ClockJumpThreshold:   time.Second,
+		ClockCorrection:      correct,
+	})
+}
+
+// clockPair returns the onboard time of sample i from a clock running
+// offset behind ground time and gaining drift seconds per second, with the
+// ground time it is received at
+func clockPair(i int, offset, drift float64) (onboard, ground time.Time) {
+	onboard = start.Add(time.Duration(i) * 10 * time.Second)
+	elapsed := float64(i) * 10
+	ground = onboard.Add(time.Duration((offset + drift*elapsed) * float64(time.Second)))
+	return onboard, ground
+}
+
+// feed correlates samples first to last-1 and fails on any not flagged good
+func feed(t *testing.T, c *Correlator, first, last int, offset, drift float64) {
+	t.Helper()
+	for i := first; i < last; i++ {
+		onboard, ground := clockPair(i, offset, drift)
+		if _, quality := c.Correlate(1, onboard, ground); quality != models.TimeQualityGood {
+			t.Fatalf("sample %d flagged %s", i, quality)
+		}
+	}
+}
+
+func model(c *Correlator) Correlation {
+	for _, m := range c.Correlations() {
+		if m.SpacecraftID == 1 {
+			return m
+		}
+	}
+	return Correlation{}
+}
+
+func TestCorrelatorFitsOffsetAndDrift(t *testing.T) {
+	c := newTestCorrelator(false)
+
+	feed(t, c, 0, minFitSamples-1, 2.5, 1e-4)
+	if model(c).Fitted {
+		t.Fatalf("model fitted after %d samples", minFitSamples-1)
+	}
+
+	feed(t, c, minFitSamples-1, 30, 2.5, 1e-4)
+	m := model(c)
+	if !m.Fitted || m.Samples != 20 {
+		t.Fatalf("fitted %v over %d samples, want a fit over the 20-sample window", m.Fitted, m.Samples)
+	}
+
+	// The window starts at sample 10, one hundred seconds in
+	if want := 2.5 + 1e-4*100; math.Abs(m.Offset-want) > 1e-6 {
+		t.Errorf("offset %g, want %g", m.Offset, want)
+	}
+	if math.Abs(m.Drift-1e-4) > 1e-8 {
+		t.Errorf("drift %g, want 1e-4", m.Drift)
+	}
+	if m.RMSResidual > 1e-6 {
+		t.Errorf("RMS residual %g for an exact line", m.RMSResidual)
+	}
+}
+
+func TestCorrelatorCorrectsOnceFitted(t *testing.T) {
+	c := newTestCorrelator(true)
+
+	// Before the fit there is nothing to correct with
+	onboard, ground := clockPair(0, 2.5, 1e-4)
+	if got, quality := c.Correlate(1, onboard, ground); !got.Equal(onboard) || quality != models.TimeQualityGood {
+		t.Fatalf("unfitted sample stored as %s (%s), want the onboard time", got, quality)
+	}
+
+	for i := 1; i < 30; i++ {
+		onboard, ground = clockPair(i, 2.5, 1e-4)
+		c.Correlate(1, onboard, ground)
+	}
+	onboard, ground = clockPair(30, 2.5, 1e-4)
+	got, quality := c.Correlate(1, onboard, ground)
+	if quality != models.TimeQualityCorrected {
+		t.Fatalf("quality %s, want %s", quality, models.TimeQualityCorrected)
+	}
+	if diff := got.Sub(ground); diff > time.Millisecond || diff < -time.Millisecond {
+		t.Errorf("corrected time %s is %s from ground time", got, diff)
+	}
+}
+
+func TestCorrelatorRejectsOutliers(t *testing.T) {
+	c := newTestCorrelator(false)
+	feed(t, c, 0, 20, 2.5, 0)
+	before := model(c)
+
+	// One late packet is flagged and left out of the model
+	onboard, ground := clockPair(20, 2.5, 0)
+	if _, quality := c.Correlate(1, onboard, ground.Add(3*time.Second)); quality != models.TimeQualityJump {
+		t.Fatalf("outlier flagged %s, want %s", quality, models.TimeQualityJump)
+	}
+	after := model(c)
+	if after.Rejected != 1 || after.Samples != before.Samples || after.Offset != before.Offset {
+		t.Errorf("model changed by an outlier: %+v, was %+v", after, before)
+	}
+
+	// The next good sample clears the jump count
+	feed(t, c, 21, 22, 2.5, 0)
+}
+
+func TestCorrelatorRebuildsAfterClockJump(t *testing.T) {
+	c := newTestCorrelator(false)
+	feed(t, c, 0, 20, 2.5, 0)
+
+	// The onboard clock is stepped by a minute; every sample is a jump
+	// until the model is abandoned
+	for i := 20; i < 20+jumpResetCount; i++ {
+		onboard, ground := clockPair(i, 62.5, 0)
+		if _, quality := c.Correlate(1, onboard, ground); quality != models.TimeQualityJump {
+			t.Fatalf("sample %d flagged %s, want %s", i, quality, models.TimeQualityJump)
+		}
+	}
+	if m := model(c); m.Fitted || m.Samples != 0 || m.Rejected != jumpResetCount {
+		t.Fatalf("model %+v not rebuilt after %d jumps", m, jumpResetCount)
+	}
+
+	feed(t, c, 30, 40, 62.5, 0)
+	if m := model(c); !m.Fitted || math.Abs(m.Offset-62.5) > 1e-6 {
+		t.Errorf("rebuilt model %+v, want an offset of 62.5s", m)
+	}
+}
+
+func TestCorrelatorFlagsImplausibleTimes(t *testing.T) {
+	ground := start
+	checks := []struct {
+		onboard time.Time
+		want    string
+	}{
+		{time.Date(1999, time.June, 1, 0, 0, 0, 0, time.UTC), models.TimeQualityPreLaunch},
+		{ground.Add(6 * time.Second), models.TimeQualityFuture},
+		{ground.Add(4 * time.Second), models.TimeQualityGood},
+	}
+
+	for _, correct := range []bool{false, true} {
+		for _, check := range checks {
+			c := newTestCorrelator(correct)
+			got, quality := c.Correlate(1, check.onboard, ground)
+			if quality != check.want {
+				t.Errorf("onboard %s: quality %s, want %s", check.onboard, quality, check.want)
+			}
+
+			// Untrusted times fall back to receipt time when correcting
+			want := check.onboard
+			if correct && quality != models.TimeQualityGood {
+				want = ground
+			}
+			if !got.Equal(want) {
+				t.Errorf("onboard %s with correction %v stored as %s, want %s", check.onboard, correct, got, want)
+			}
+		}
+	}
+}
+
+func TestCorrelationsPerSpacecraft(t *testing.T) {
+	c := newTestCorrelator(false)
+	for _, id := range []uint16{7, 3, 5} {
+		onboard, ground := clockPair(0, 1, 0)
+		c.Correlate(id, onboard, ground)
+	}
+
+	correlations := c.Correlations()
+	if len(correlations) != 3 {
+		t.Fatalf("%d correlations, want 3", len(correlations))
+	}
+	for i, id := range []uint16{3, 5, 7} {
+		if correlations[i].SpacecraftID != id || correlations[i].Samples != 1 {
+			t.Errorf("correlation %d is %+v, want spacecraft %d with one sample", i, correlations[i], id)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package coding
+
+import (
+	"bytes"
+	"math/bits"
+	"sync"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// Stats counts synchronisation and error correction events, which describe
+// the quality of the link
+type Stats struct {
+	Codeblocks       uint64 `json:"codeblocks"`
+	Codewords        uint64 `json:"codewords"`
+	CorrectedSymbols uint64 `json:"corrected_symbols"`
+	Uncorrectable    uint64 `json:"uncorrectable_codewords"`
+	DroppedFrames    uint64 `json:"dropped_frames"`
+	SyncLosses       uint64 `json:"sync_losses"`
+	SkippedBytes     uint64 `json:"skipped_bytes"`
+}
+
+// Decoder recovers transfer frames from a byte stream of channel access data
+// units: an ASM followed by a Reed-Solomon codeblock interleaved to the
+// configured depth. Frames whose codeblock cannot be corrected are dropped.
+type Decoder struct {
+	mu         sync.Mutex
+	interleave int
+	blockSize  int
+	tolerance  int
+	buffer     []byte
+	locked     bool
+	stats      Stats
+}
+
+// NewDecoder creates a decoder using the coding settings in cfg
+func NewDecoder(cfg *config.IngestConfig) *Decoder {
+	interleave := cfg.RSInterleave
+	if interleave < 1 {
+		interleave = 1
+	}
+
+	return &Decoder{
+		interleave: interleave,
+		blockSize:  ccsds.RSCodewordSize * interleave,
+		tolerance:  cfg.ASMTolerance,
+	}
+}
+
+// FrameSize returns the length of the transfer frames carried by the decoder's codeblocks
+func (d *Decoder) FrameSize() int {
+	return ccsds.RSDataSize * d.interleave
+}
+
+// Push appends received bytes to the stream and returns every frame that
+// became available. Bytes may be split arbitrarily between calls.
+func (d *Decoder) Push(data []byte) [][]byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.buffer = append(d.buffer, data...)
+	cadu := len(ccsds.ASM) + d.blockSize
+
+	var frames [][]byte
+	offset := 0
+	for {
+		if !d.locked {
+			// Search for an exact marker before trusting the stream again
+			i := bytes.Index(d.buffer[offset:], ccsds.ASM)
+			if i < 0 {
+				keep := len(ccsds.ASM) - 1
+				if skip := len(d.buffer) - offset - keep; skip > 0 {
+					offset += skip
+					d.stats.SkippedBytes += uint64(skip)
+				}
+				break
+			}
+			offset += i
+			d.stats.SkippedBytes += uint64(i)
+			d.locked = true
+		}
+
+		if len(d.buffer)-offset < cadu {
+			break
+		}
+
+		// Once locked, tolerate a few bit errors in the marker
+		if !d.matchASM(d.buffer[offset : offset+len(ccsds.ASM)]) {
+			d.locked = false
+			d.stats.SyncLosses++
+			continue
+		}
+
+		if frame := d.decodeBlock(d.buffer[offset+len(ccsds.ASM) : offset+cadu]); frame != nil {
+			frames = append(frames, frame)
+		}
+		offset += cadu
+	}
+
+	d.buffer = append(d.buffer[:0], d.buffer[offset:]...)
+	return frames
+}
+
+// Stats returns the link quality counters so far
+func (d *Decoder) Stats() Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stats
+}
+
+// matchASM reports whether marker is within the bit error tolerance of the ASM
+func (d *Decoder) matchASM(marker []byte) bool {
+	flipped := 0
+	for i := range ccsds.ASM {
+		flipped += bits.OnesCount8(marker[i] ^ ccsds.ASM[i])
+	}
+	return flipped <= d.tolerance
+}
+
+// decodeBlock de-interleaves and corrects one codeblock, returning its frame
+func (d *Decoder) decodeBlock(block []byte) []byte {
+	d.stats.Codeblocks++
+
+	frame := make([]byte, d.FrameSize())
+	codeword := make([]byte, ccsds.RSCodewordSize)
+	failed := false
+	for i := 0; i < d.interleave; i++ {
+		for j := range codeword {
+			codeword[j] = block[j*d.interleave+i]
+		}
+
+		d.stats.Codewords++
+		n := ccsds.RSDecode(codeword)
+		if n < 0 {
+			d.stats.Uncorrectable++
+			failed = true
+			continue
+		}
+		d.stats.CorrectedSymbols += uint64(n)
+
+		for j := 0; j < ccsds.RSDataSize; j++ {
+			frame[j*d.interleave+i] = codeword[j]
+		}
+	}
+
+	if failed {
+		d.stats.DroppedFrames++
+		return nil
+	}
+	return frame
+}
@@ -0,0 +1,104 @@
+package coding
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+var rng = rand.New(rand.NewSource(1))
+
+// testFrame returns a frame of random bytes for the given interleave depth
+func testFrame(depth int) []byte {
+	frame := make([]byte, ccsds.RSDataSize*depth)
+	rng.Read(frame)
+	return frame
+}
+
+// cadu wraps a frame in an ASM and its interleaved codeblock
+func cadu(frame []byte, depth int) []byte {
+	return append(append([]byte(nil), ccsds.ASM...), ccsds.RSEncodeBlock(frame, depth)...)
+}
+
+// decode pushes stream into a new decoder in chunks of every given size and
+// checks that each decoder returns want and counts stats
+func decode(t *testing.T, cfg *config.IngestConfig, stream []byte, want [][]byte, stats Stats) {
+	t.Helper()
+	for _, chunk := range []int{1, 17, 300, len(stream)} {
+		d := NewDecoder(cfg)
+		var got [][]byte
+		for rest := stream; len(rest) > 0; {
+			n := chunk
+			if n > len(rest) {
+				n = len(rest)
+			}
+			got = append(got, d.Push(rest[:n])...)
+			rest = rest[n:]
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("chunks of %d: %d frames, want %d", chunk, len(got), len(want))
+		}
+		for i := range got {
+			if !bytes.Equal(got[i], want[i]) {
+				t.Fatalf("chunks of %d: frame %d differs", chunk, i)
+			}
+		}
+		if d.Stats() != stats {
+			t.Fatalf("chunks of %d: stats %+v, want %+v", chunk, d.Stats(), stats)
+		}
+	}
+}
+
+func TestDecoderBackToBack(t *testing.T) {
+	a, b := testFrame(1), testFrame(1)
+	decode(t, &config.IngestConfig{}, append(cadu(a, 1), cadu(b, 1)...), [][]byte{a, b},
+		Stats{Codeblocks: 2, Codewords: 2})
+}
+
+func TestDecoderSkipsLeadingBytes(t *testing.T) {
+	a := testFrame(2)
+	decode(t, &config.IngestConfig{RSInterleave: 2}, append([]byte{0x00, 0x11, 0x22}, cadu(a, 2)...), [][]byte{a},
+		Stats{Codeblocks: 1, Codewords: 2, SkippedBytes: 3})
+}
+
+func TestDecoderCorrectsSymbols(t *testing.T) {
+	// Interleaving spreads a burst of errors across both codewords
+	a := testFrame(2)
+	stream := cadu(a, 2)
+	for i := 0; i < 10; i++ {
+		stream[len(ccsds.ASM)+i*7] ^= 0xFF
+	}
+	decode(t, &config.IngestConfig{RSInterleave: 2}, stream, [][]byte{a},
+		Stats{Codeblocks: 1, Codewords: 2, CorrectedSymbols: 10})
+}
+
+func TestDecoderDropsUncorrectableFrames(t *testing.T) {
+	a, b := testFrame(1), testFrame(1)
+	bad := cadu(a, 1)
+	for i := 0; i < 40; i++ {
+		bad[len(ccsds.ASM)+i] ^= 0x5A
+	}
+	decode(t, &config.IngestConfig{}, append(bad, cadu(b, 1)...), [][]byte{b},
+		Stats{Codeblocks: 2, Codewords: 2, Uncorrectable: 1, DroppedFrames: 1})
+}
+
+func TestDecoderMarkerErrors(t *testing.T) {
+	cfg := &config.IngestConfig{ASMTolerance: 2}
+	a, b, c := testFrame(1), testFrame(1), testFrame(1)
+
+	// Two flipped bits are within tolerance once locked
+	second := cadu(b, 1)
+	second[0] ^= 0x81
+	decode(t, cfg, append(cadu(a, 1), second...), [][]byte{a, b},
+		Stats{Codeblocks: 2, Codewords: 2})
+
+	// A marker beyond tolerance loses lock, and its CADU is skipped while
+	// searching for the next exact marker
+	second[0] ^= 0x7E
+	decode(t, cfg, bytes.Join([][]byte{cadu(a, 1), second, cadu(c, 1)}, nil), [][]byte{a, c},
+		Stats{Codeblocks: 2, Codewords: 2, SyncLosses: 1, SkippedBytes: uint64(len(ccsds.ASM) + ccsds.RSCodewordSize)})
+}
@@ -0,0 +1,228 @@
+package frames
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+)
+
+// idleAPID marks CCSDS idle packets, which carry no telemetry
+const idleAPID = 0x7FF
+
+// Packet is a space packet extracted from one or more transfer frames
+type Packet struct {
+	SpacecraftID     uint16
+	VirtualChannelID uint8
+	Data             []byte
+}
+
+// ChannelKey identifies a virtual channel of a spacecraft
+type ChannelKey struct {
+	SpacecraftID     uint16
+	VirtualChannelID uint8
+}
+
+// ChannelStats counts frame-level events on one virtual channel
+type ChannelStats struct {
+	Frames     uint64 `json:"frames"`
+	LostFrames uint64 `json:"lost_frames"`
+	Packets    uint64 `json:"packets"`
+	Discarded  uint64 `json:"discarded_bytes"`
+}
+
+// ChannelReport is the counters of one virtual channel
+type ChannelReport struct {
+	SpacecraftID     uint16 `json:"spacecraft_id"`
+	VirtualChannelID uint8  `json:"virtual_channel_id"`
+	ChannelStats
+}
+
+// Stats counts rejected frames and the events on every virtual channel
+type Stats struct {
+	FECFErrors   uint64          `json:"fecf_errors"`
+	DecodeErrors uint64          `json:"decode_errors"`
+	Channels     []ChannelReport `json:"channels"`
+}
+
+// virtualChannel tracks packet extraction state for one virtual channel
+type virtualChannel struct {
+	nextCount uint8  // Expected VC frame count of the next frame
+	started   bool   // At least one frame has been seen
+	synced    bool   // buffer holds the start of a packet
+	buffer    []byte // Bytes of a packet spanning frames
+	stats     ChannelStats
+}
+
+// Demultiplexer splits a stream of TM Transfer Frames into space packets,
+// keeping separate extraction state for every spacecraft and virtual channel
+type Demultiplexer struct {
+	mu          sync.Mutex
+	channels    map[ChannelKey]*virtualChannel
+	hasFECF     bool
+	spacecraft  map[uint16]bool
+	crcErrors   uint64
+	otherErrors uint64
+}
+
+// NewDemultiplexer creates a demultiplexer using the frame settings in cfg
+func NewDemultiplexer(cfg *config.IngestConfig) *Demultiplexer {
+	spacecraft := make(map[uint16]bool)
+	for _, id := range cfg.FrameSpacecraftIDs {
+		spacecraft[id] = true
+	}
+
+	return &Demultiplexer{
+		channels:   make(map[ChannelKey]*virtualChannel),
+		hasFECF:    cfg.FrameFECF,
+		spacecraft: spacecraft,
+	}
+}
+
+// Push decodes one transfer frame and returns every space packet completed by it
+func (d *Demultiplexer) Push(data []byte) ([]Packet, error) {
+	frame, err := ParseTMFrame(data, d.hasFECF)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err != nil {
+		if errors.Is(err, ErrBadFECF) {
+			d.crcErrors++
+		} else {
+			d.otherErrors++
+		}
+		return nil, err
+	}
+
+	if len(d.spacecraft) > 0 && !d.spacecraft[frame.SpacecraftID] {
+		return nil, nil
+	}
+
+	key := ChannelKey{SpacecraftID: frame.SpacecraftID, VirtualChannelID: frame.VirtualChannelID}
+	vc, ok := d.channels[key]
+	if !ok {
+		vc = &virtualChannel{}
+		d.channels[key] = vc
+	}
+
+	vc.stats.Frames++
+	if vc.started && frame.VCFrameCount != vc.nextCount {
+		lost := uint64(frame.VCFrameCount - vc.nextCount)
+		vc.stats.LostFrames += lost
+		log.Printf("Frames: SCID %d VC %d lost %d frame(s) (expected count %d, got %d)",
+			key.SpacecraftID, key.VirtualChannelID, lost, vc.nextCount, frame.VCFrameCount)
+		vc.dropPartial()
+	}
+	vc.started = true
+	vc.nextCount = frame.VCFrameCount + 1
+
+	// Only packet-synchronous data can be split into packets
+	if frame.SyncFlag || frame.FirstHeaderPointer == FHPIdleData {
+		return nil, nil
+	}
+
+	var packets [][]byte
+	field := frame.DataField
+	fhp := int(frame.FirstHeaderPointer)
+
+	if fhp != FHPNoPacketStart {
+		if fhp > len(field) {
+			d.otherErrors++
+			vc.dropPartial()
+			return nil, ErrFrameTooShort
+		}
+
+		// Bytes before the first header pointer finish the packet in progress
+		if vc.synced {
+			vc.buffer = append(vc.buffer, field[:fhp]...)
+			packets = vc.extract()
+			if len(vc.buffer) > 0 {
+				log.Printf("Frames: SCID %d VC %d packet boundary mismatch at first header pointer",
+					key.SpacecraftID, key.VirtualChannelID)
+				vc.dropPartial()
+			}
+		} else {
+			vc.stats.Discarded += uint64(fhp)
+		}
+
+		vc.synced = true
+		vc.buffer = append(vc.buffer[:0], field[fhp:]...)
+	} else if vc.synced {
+		vc.buffer = append(vc.buffer, field...)
+	} else {
+		// No packet starts here and we have nothing to continue
+		vc.stats.Discarded += uint64(len(field))
+		return nil, nil
+	}
+
+	packets = append(packets, vc.extract()...)
+
+	result := make([]Packet, 0, len(packets))
+	for _, p := range packets {
+		if binary.BigEndian.Uint16(p[0:2])&0x07FF == idleAPID {
+			continue
+		}
+		result = append(result, Packet{
+			SpacecraftID:     key.SpacecraftID,
+			VirtualChannelID: key.VirtualChannelID,
+			Data:             p,
+		})
+	}
+	vc.stats.Packets += uint64(len(result))
+
+	return result, nil
+}
+
+// Stats returns a snapshot of the error and per-channel counters
+func (d *Demultiplexer) Stats() Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := Stats{
+		FECFErrors:   d.crcErrors,
+		DecodeErrors: d.otherErrors,
+		Channels:     make([]ChannelReport, 0, len(d.channels)),
+	}
+	for key, vc := range d.channels {
+		stats.Channels = append(stats.Channels, ChannelReport{
+			SpacecraftID:     key.SpacecraftID,
+			VirtualChannelID: key.VirtualChannelID,
+			ChannelStats:     vc.stats,
+		})
+	}
+	sort.Slice(stats.Channels, func(i, j int) bool {
+		a, b := stats.Channels[i], stats.Channels[j]
+		if a.SpacecraftID != b.SpacecraftID {
+			return a.SpacecraftID < b.SpacecraftID
+		}
+		return a.VirtualChannelID < b.VirtualChannelID
+	})
+	return stats
+}
+
+// extract removes every complete packet from the front of the buffer
+func (vc *virtualChannel) extract() [][]byte {
+	var packets [][]byte
+	for len(vc.buffer) >= 6 {
+		size := 6 + int(binary.BigEndian.Uint16(vc.buffer[4:6])) + 1
+		if len(vc.buffer) < size {
+			break
+		}
+		packet := make([]byte, size)
+		copy(packet, vc.buffer[:size])
+		packets = append(packets, packet)
+		vc.buffer = vc.buffer[size:]
+	}
+	return packets
+}
+
+// dropPartial abandons any packet in progress until the next first header pointer
+func (vc *virtualChannel) dropPartial() {
+	vc.stats.Discarded += uint64(len(vc.buffer))
+	vc.buffer = nil
+	vc.synced = false
+}
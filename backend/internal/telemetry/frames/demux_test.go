@@ -0,0 +1,220 @@
+package frames
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+)
+
+// testPacket builds a telemetry packet whose data field is size bytes
+func testPacket(apid uint16, size int) []byte {
+	packet := make([]byte, 6, 6+size)
+	binary.BigEndian.PutUint16(packet[0:2], apid)
+	binary.BigEndian.PutUint16(packet[2:4], 0xC000)
+	binary.BigEndian.PutUint16(packet[4:6], uint16(size-1))
+	for i := 0; i < size; i++ {
+		packet = append(packet, byte(apid)+byte(i))
+	}
+	return packet
+}
+
+// testFrames lays packets one after another in frames with size-byte data
+// fields, filling the last with an idle packet, and returns the frames
+// numbered from count
+func testFrames(spacecraftID uint16, count uint8, size int, packets ...[]byte) [][]byte {
+	var stream []byte
+	var starts []int
+	for _, p := range packets {
+		starts = append(starts, len(stream))
+		stream = append(stream, p...)
+	}
+	if rem := (size - len(stream)%size) % size; rem > 0 {
+		starts = append(starts, len(stream))
+		stream = append(stream, testPacket(idleAPID, rem-6)...)
+	}
+
+	var frames [][]byte
+	for offset := 0; offset < len(stream); offset += size {
+		fhp := uint16(FHPNoPacketStart)
+		for _, start := range starts {
+			if start >= offset && start < offset+size {
+				fhp = uint16(start - offset)
+				break
+			}
+		}
+		frames = append(frames, testFrame(spacecraftID, 0, count, fhp, stream[offset:offset+size]))
+		count++
+	}
+	return frames
+}
+
+// pushFrames feeds frames to d and returns the packets extracted from them
+func pushFrames(t *testing.T, d *Demultiplexer, frames ...[]byte) [][]byte {
+	t.Helper()
+	var packets [][]byte
+	for i, frame := range frames {
+		extracted, err := d.Push(frame)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		for _, p := range extracted {
+			packets = append(packets, p.Data)
+		}
+	}
+	return packets
+}
+
+// checkPackets fails the test unless got holds the wanted packets in order
+func checkPackets(t *testing.T, got [][]byte, want ...[]byte) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%d packets, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Fatalf("packet %d is %x, want %x", i, got[i], want[i])
+		}
+	}
+}
+
+// channelStats returns the counters of a spacecraft's first virtual channel
+func channelStats(t *testing.T, d *Demultiplexer, spacecraftID uint16) ChannelStats {
+	t.Helper()
+	for _, c := range d.Stats().Channels {
+		if c.SpacecraftID == spacecraftID && c.VirtualChannelID == 0 {
+			return c.ChannelStats
+		}
+	}
+	t.Fatalf("no stats for spacecraft %d", spacecraftID)
+	return ChannelStats{}
+}
+
+func TestDemultiplexerExtractsPackets(t *testing.T) {
+	short, long := testPacket(16, 20), testPacket(17, 150)
+	d := NewDemultiplexer(&config.IngestConfig{FrameFECF: true})
+
+	// Two packets in one frame, then one spanning three frames; the idle
+	// packets filling each frame are dropped
+	frames := append(testFrames(42, 0, 64, short, short), testFrames(42, 1, 64, long)...)
+	checkPackets(t, pushFrames(t, d, frames...), short, short, long)
+
+	stats := channelStats(t, d, 42)
+	if stats.Frames != 4 || stats.Packets != 3 || stats.LostFrames != 0 {
+		t.Errorf("stats %+v, want 4 frames and 3 packets", stats)
+	}
+}
+
+func TestDemultiplexerLostFrame(t *testing.T) {
+	short, long := testPacket(16, 20), testPacket(17, 150)
+	spanning := testFrames(42, 0, 64, long)
+	d := NewDemultiplexer(&config.IngestConfig{FrameFECF: true})
+
+	// Losing the middle frame abandons the long packet; the next packet
+	// starts at a first header pointer and is extracted
+	packets := pushFrames(t, d, spanning[0], spanning[2], testFrames(42, 3, 64, short)[0])
+	checkPackets(t, packets, short)
+
+	stats := channelStats(t, d, 42)
+	if stats.LostFrames != 1 {
+		t.Errorf("%d lost frames, want 1", stats.LostFrames)
+	}
+	// The first frame's data, then the end of the packet in the third
+	if stats.Discarded != 64+28 {
+		t.Errorf("%d bytes discarded, want %d", stats.Discarded, 64+28)
+	}
+}
+
+func TestDemultiplexerFrameCountWraps(t *testing.T) {
+	short := testPacket(16, 20)
+	d := NewDemultiplexer(&config.IngestConfig{FrameFECF: true})
+
+	frames := append(testFrames(42, 255, 64, short), testFrames(42, 0, 64, short)...)
+	checkPackets(t, pushFrames(t, d, frames...), short, short)
+	if lost := channelStats(t, d, 42).LostFrames; lost != 0 {
+		t.Errorf("%d lost frames across the count wrap", lost)
+	}
+}
+
+func TestDemultiplexerRejectsFrames(t *testing.T) {
+	short := testPacket(16, 20)
+
+	corrupt := testFrames(42, 0, 64, short)[0]
+	corrupt[10] ^= 0x80
+	d := NewDemultiplexer(&config.IngestConfig{FrameFECF: true})
+	if _, err := d.Push(corrupt); !errors.Is(err, ErrBadFECF) {
+		t.Fatalf("error %v, want %v", err, ErrBadFECF)
+	}
+	if stats := d.Stats(); stats.FECFErrors != 1 || stats.DecodeErrors != 0 {
+		t.Errorf("%d FECF and %d decode errors, want 1 and 0", stats.FECFErrors, stats.DecodeErrors)
+	}
+
+	// Frames of spacecraft not listed are ignored without error
+	d = NewDemultiplexer(&config.IngestConfig{FrameFECF: true, FrameSpacecraftIDs: []uint16{42}})
+	if packets := pushFrames(t, d, testFrames(7, 0, 64, short)...); len(packets) != 0 {
+		t.Errorf("%d packets from an unlisted spacecraft", len(packets))
+	}
+}
+
+// TestDemultiplexerGroundTruth feeds the demultiplexer frames sent through
+// the generator's impaired link and checks its counts against what the link
+// did. testdata/impaired_frames.bin is written by the generator's
+// TestImpairedFrameCapture with -update; each frame is preceded by its
+// length as two big-endian bytes.
+func TestDemultiplexerGroundTruth(t *testing.T) {
+	capture, err := os.ReadFile("testdata/impaired_frames.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile("testdata/impaired_frames.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var truth struct {
+		Offered   uint64 `json:"offered"`
+		Delivered uint64 `json:"delivered"`
+		Lost      uint64 `json:"lost"`
+		BurstLost uint64 `json:"burst_lost"`
+		Corrupted uint64 `json:"corrupted"`
+	}
+	if err := json.Unmarshal(data, &truth); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDemultiplexer(&config.IngestConfig{FrameFECF: true})
+	var frames, packets uint64
+	for len(capture) > 0 {
+		size := int(binary.BigEndian.Uint16(capture))
+		frame := capture[2 : 2+size]
+		capture = capture[2+size:]
+		frames++
+
+		extracted, _ := d.Push(frame)
+		packets += uint64(len(extracted))
+	}
+
+	stats := d.Stats()
+	if frames != truth.Delivered {
+		t.Fatalf("%d frames in the capture, link delivered %d", frames, truth.Delivered)
+	}
+	if stats.FECFErrors != truth.Corrupted {
+		t.Errorf("%d FECF errors, link corrupted %d frames", stats.FECFErrors, truth.Corrupted)
+	}
+	if len(stats.Channels) != 1 {
+		t.Fatalf("%d channels, want 1", len(stats.Channels))
+	}
+
+	// Frames rejected for their FECF leave gaps in the count like lost ones
+	ch := stats.Channels[0]
+	if want := truth.Lost + truth.BurstLost + truth.Corrupted; ch.LostFrames != want {
+		t.Errorf("%d lost frames, link lost %d and corrupted %d", ch.LostFrames, truth.Lost+truth.BurstLost, truth.Corrupted)
+	}
+	// Each frame carries one packet
+	if want := truth.Offered - truth.Lost - truth.BurstLost - truth.Corrupted; packets != want {
+		t.Errorf("%d packets extracted, want %d", packets, want)
+	}
+}
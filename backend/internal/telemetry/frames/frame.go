@@ -0,0 +1,104 @@
+package frames
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// Sizes of the fixed TM Transfer Frame fields (CCSDS 132.0-B)
+const (
+	PrimaryHeaderSize = 6
+	OCFSize           = 4
+	FECFSize          = 2
+)
+
+// Special First Header Pointer values
+const (
+	FHPNoPacketStart = 0x7FF // No packet starts in this frame's data field
+	FHPIdleData      = 0x7FE // The data field contains only idle data
+)
+
+var (
+	// ErrFrameTooShort is returned when a frame cannot hold its declared fields
+	ErrFrameTooShort = errors.New("frame too short")
+	// ErrBadFECF is returned when the Frame Error Control Field does not match
+	ErrBadFECF = errors.New("frame error control field mismatch")
+	// ErrBadVersion is returned for frames that are not TM Transfer Frames
+	ErrBadVersion = errors.New("unsupported transfer frame version")
+)
+
+// TMFrame is a decoded TM Transfer Frame
+type TMFrame struct {
+	Version            uint8  // Transfer Frame Version Number (2 bits)
+	SpacecraftID       uint16 // Spacecraft Identifier (10 bits)
+	VirtualChannelID   uint8  // Virtual Channel Identifier (3 bits)
+	OCFFlag            bool   // Operational Control Field present
+	MCFrameCount       uint8  // Master channel frame count
+	VCFrameCount       uint8  // Virtual channel frame count
+	SecondaryHeader    bool   // Transfer frame secondary header present
+	SyncFlag           bool   // Data field is not packet-synchronous (VCA service)
+	PacketOrder        bool   // Packet order flag
+	SegmentLengthID    uint8  // Segment length identifier (2 bits)
+	FirstHeaderPointer uint16 // Offset of the first packet header in the data field (11 bits)
+	DataField          []byte // Transfer frame data field
+}
+
+// ParseTMFrame decodes a TM Transfer Frame. When hasFECF is set the last two
+// bytes are verified as a CRC-16-CCITT over the rest of the frame.
+func ParseTMFrame(data []byte, hasFECF bool) (TMFrame, error) {
+	var frame TMFrame
+
+	end := len(data)
+	if hasFECF {
+		end -= FECFSize
+	}
+	if end < PrimaryHeaderSize {
+		return frame, ErrFrameTooShort
+	}
+
+	if hasFECF {
+		want := binary.BigEndian.Uint16(data[end:])
+		if got := ccsds.CRC16CCITT(data[:end]); got != want {
+			return frame, fmt.Errorf("%w: got %04x, want %04x", ErrBadFECF, got, want)
+		}
+	}
+
+	id := binary.BigEndian.Uint16(data[0:2])
+	status := binary.BigEndian.Uint16(data[4:6])
+
+	frame.Version = uint8(id >> 14)
+	if frame.Version != 0 {
+		return frame, fmt.Errorf("%w %d", ErrBadVersion, frame.Version)
+	}
+	frame.SpacecraftID = (id >> 4) & 0x03FF
+	frame.VirtualChannelID = uint8(id>>1) & 0x07
+	frame.OCFFlag = id&0x0001 != 0
+	frame.MCFrameCount = data[2]
+	frame.VCFrameCount = data[3]
+	frame.SecondaryHeader = status&0x8000 != 0
+	frame.SyncFlag = status&0x4000 != 0
+	frame.PacketOrder = status&0x2000 != 0
+	frame.SegmentLengthID = uint8(status>>11) & 0x03
+	frame.FirstHeaderPointer = status & 0x07FF
+
+	start := PrimaryHeaderSize
+	if frame.SecondaryHeader {
+		if start >= end {
+			return frame, ErrFrameTooShort
+		}
+		// Secondary header length field holds the header size minus one
+		start += int(data[start]&0x3F) + 1
+	}
+	if frame.OCFFlag {
+		end -= OCFSize
+	}
+	if start > end {
+		return frame, ErrFrameTooShort
+	}
+
+	frame.DataField = data[start:end]
+	return frame, nil
+}
@@ -0,0 +1,92 @@
+package frames
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// testFrame builds a TM Transfer Frame with an FECF around a data field
+func testFrame(spacecraftID uint16, vc, count uint8, fhp uint16, data []byte) []byte {
+	frame := make([]byte, PrimaryHeaderSize, PrimaryHeaderSize+len(data)+FECFSize)
+	binary.BigEndian.PutUint16(frame[0:2], (spacecraftID&0x03FF)<<4|uint16(vc&0x07)<<1)
+	frame[2], frame[3] = count, count
+	binary.BigEndian.PutUint16(frame[4:6], 0x1800|fhp)
+	frame = append(frame, data...)
+	return binary.BigEndian.AppendUint16(frame, ccsds.CRC16CCITT(frame))
+}
+
+// reseal replaces the FECF of a frame edited after it was built
+func reseal(frame []byte) []byte {
+	end := len(frame) - FECFSize
+	binary.BigEndian.PutUint16(frame[end:], ccsds.CRC16CCITT(frame[:end]))
+	return frame
+}
+
+func TestParseTMFrame(t *testing.T) {
+	data := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	frame, err := ParseTMFrame(testFrame(42, 3, 17, 2, data), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.SpacecraftID != 42 || frame.VirtualChannelID != 3 || frame.VCFrameCount != 17 || frame.FirstHeaderPointer != 2 {
+		t.Errorf("SCID %d VC %d count %d FHP %d, want 42, 3, 17 and 2",
+			frame.SpacecraftID, frame.VirtualChannelID, frame.VCFrameCount, frame.FirstHeaderPointer)
+	}
+	if !bytes.Equal(frame.DataField, data) {
+		t.Errorf("data field %x, want %x", frame.DataField, data)
+	}
+}
+
+func TestParseTMFrameOptionalFields(t *testing.T) {
+	data := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+	// Without an FECF the last two bytes are data
+	good := testFrame(42, 3, 17, 2, data)
+	frame, err := ParseTMFrame(good, false)
+	if err != nil || !bytes.Equal(frame.DataField, good[PrimaryHeaderSize:]) {
+		t.Errorf("without FECF: data field %x (%v), want %x", frame.DataField, err, good[PrimaryHeaderSize:])
+	}
+
+	// An operational control field ends the data field early
+	withOCF := testFrame(42, 3, 17, 2, data)
+	withOCF[1] |= 0x01
+	frame, err = ParseTMFrame(reseal(withOCF), true)
+	if err != nil || !frame.OCFFlag || !bytes.Equal(frame.DataField, data[:len(data)-OCFSize]) {
+		t.Errorf("with OCF: data field %x (%v), want %x", frame.DataField, err, data[:len(data)-OCFSize])
+	}
+
+	// A two-byte secondary header precedes the data field
+	withSecondary := testFrame(42, 3, 17, 0, append([]byte{0x01, 0xEE}, data...))
+	withSecondary[4] |= 0x80
+	frame, err = ParseTMFrame(reseal(withSecondary), true)
+	if err != nil || !frame.SecondaryHeader || !bytes.Equal(frame.DataField, data) {
+		t.Errorf("with secondary header: data field %x (%v), want %x", frame.DataField, err, data)
+	}
+}
+
+func TestParseTMFrameErrors(t *testing.T) {
+	good := testFrame(42, 3, 17, 2, []byte{1, 2, 3, 4})
+	corrupt := append([]byte(nil), good...)
+	corrupt[len(corrupt)-1] ^= 0x01
+
+	if _, err := ParseTMFrame(corrupt, true); !errors.Is(err, ErrBadFECF) {
+		t.Errorf("corrupt frame: error %v, want %v", err, ErrBadFECF)
+	}
+	if _, err := ParseTMFrame(good[:PrimaryHeaderSize+1], true); !errors.Is(err, ErrFrameTooShort) {
+		t.Errorf("short frame: error %v, want %v", err, ErrFrameTooShort)
+	}
+	if _, err := ParseTMFrame(nil, false); !errors.Is(err, ErrFrameTooShort) {
+		t.Errorf("empty frame: error %v, want %v", err, ErrFrameTooShort)
+	}
+
+	// AOS frames carry version 2 in the same bits
+	aos := append([]byte(nil), good...)
+	aos[0] |= 0x40
+	if _, err := ParseTMFrame(reseal(aos), true); !errors.Is(err, ErrBadVersion) {
+		t.Errorf("version 2 frame: error %v, want %v", err, ErrBadVersion)
+	}
+}
@@ -0,0 +1,128 @@
+package framing
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// Stats counts framing events on one stream
+type Stats struct {
+	Bytes        uint64 `json:"bytes"`
+	Packets      uint64 `json:"packets"`
+	Resyncs      uint64 `json:"resyncs"`
+	SkippedBytes uint64 `json:"skipped_bytes"`
+}
+
+// Framer splits a byte stream into CCSDS space packets using the primary
+// header's PacketLength. When a header fails validation the framer drops
+// bytes until it finds a header that is itself valid and is followed by
+// another valid header, which resynchronises after corruption. A Framer is
+// not safe for concurrent use.
+type Framer struct {
+	r         *bufio.Reader
+	maxLength int
+	apids     map[uint16]bool
+	synced    bool
+	stats     Stats
+}
+
+// NewFramer creates a framer reading from r. Packets longer than maxLength
+// bytes are treated as corruption. If apids is non-empty, headers with
+// other APIDs are also treated as corruption.
+func NewFramer(r io.Reader, maxLength int, apids []uint16) *Framer {
+	allowed := make(map[uint16]bool)
+	for _, apid := range apids {
+		allowed[apid] = true
+	}
+
+	return &Framer{
+		r:         bufio.NewReaderSize(r, maxLength+2*ccsds.PrimaryHeaderSize),
+		maxLength: maxLength,
+		apids:     allowed,
+		synced:    true,
+	}
+}
+
+// Next returns the next complete packet. It returns io.EOF when the stream
+// ends cleanly between packets.
+func (f *Framer) Next() ([]byte, error) {
+	for {
+		header, err := f.r.Peek(ccsds.PrimaryHeaderSize)
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(header) > 0 {
+				f.skip(len(header))
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		size, ok := f.packetSize(header)
+		if ok && !f.synced {
+			ok = f.confirm(size)
+		}
+		if !ok {
+			if f.synced {
+				f.synced = false
+				f.stats.Resyncs++
+			}
+			f.skip(1)
+			continue
+		}
+		f.synced = true
+
+		packet := make([]byte, size)
+		if n, err := io.ReadFull(f.r, packet); err != nil {
+			f.stats.Bytes += uint64(n)
+			if errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		f.stats.Bytes += uint64(size)
+		f.stats.Packets++
+		return packet, nil
+	}
+}
+
+// Stats returns the framing counters so far
+func (f *Framer) Stats() Stats {
+	return f.stats
+}
+
+// packetSize validates a primary header and returns the full packet size
+func (f *Framer) packetSize(header []byte) (int, bool) {
+	h, err := ccsds.ParsePrimaryHeader(header)
+	if err != nil || h.Version() != 0 {
+		return 0, false
+	}
+	if len(f.apids) > 0 && !f.apids[h.APID()] {
+		return 0, false
+	}
+
+	size := h.PacketSize()
+	if size > f.maxLength {
+		return 0, false
+	}
+	return size, true
+}
+
+// confirm checks that a candidate packet is followed by another valid
+// header. At the end of the stream the candidate is accepted on its own.
+func (f *Framer) confirm(size int) bool {
+	next, err := f.r.Peek(size + ccsds.PrimaryHeaderSize)
+	if err != nil {
+		return len(next) >= size
+	}
+	_, ok := f.packetSize(next[size:])
+	return ok
+}
+
+// skip discards n bytes that cannot belong to a packet
+func (f *Framer) skip(n int) {
+	discarded, _ := f.r.Discard(n)
+	f.stats.Bytes += uint64(discarded)
+	f.stats.SkippedBytes += uint64(discarded)
+}
@@ -0,0 +1,132 @@
+package framing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// packet encodes a telemetry packet with size bytes of data
+func packet(apid uint16, size int) []byte {
+	b := make([]byte, ccsds.PrimaryHeaderSize, ccsds.PrimaryHeaderSize+size)
+	binary.BigEndian.PutUint16(b[0:2], apid)
+	binary.BigEndian.PutUint16(b[2:4], 0xC000)
+	binary.BigEndian.PutUint16(b[4:6], uint16(size-1))
+	return append(b, bytes.Repeat([]byte{byte(apid)}, size)...)
+}
+
+// frameAll reads packets until the framer stops, returning them with the
+// error that stopped it
+func frameAll(f *Framer) ([][]byte, error) {
+	var packets [][]byte
+	for {
+		p, err := f.Next()
+		if err != nil {
+			return packets, err
+		}
+		packets = append(packets, p)
+	}
+}
+
+// checkPackets fails unless got holds exactly the packets in want
+func checkPackets(t *testing.T, got [][]byte, want ...[]byte) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%d packets, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("packet %d is %x, want %x", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFramerBackToBack(t *testing.T) {
+	a, b, c := packet(1, 10), packet(2, 4), packet(3, 20)
+	f := NewFramer(bytes.NewReader(bytes.Join([][]byte{a, b, c}, nil)), 64, nil)
+
+	got, err := frameAll(f)
+	if err != io.EOF {
+		t.Fatalf("error %v, want EOF", err)
+	}
+	checkPackets(t, got, a, b, c)
+	if want := (Stats{Bytes: uint64(len(a) + len(b) + len(c)), Packets: 3}); f.Stats() != want {
+		t.Errorf("stats %+v, want %+v", f.Stats(), want)
+	}
+}
+
+func TestFramerResyncs(t *testing.T) {
+	a, b := packet(1, 10), packet(2, 4)
+
+	// Garbage between packets is skipped in one resync
+	f := NewFramer(bytes.NewReader(bytes.Join([][]byte{a, {0xFF, 0xFF, 0xFF}, b}, nil)), 64, nil)
+	got, _ := frameAll(f)
+	checkPackets(t, got, a, b)
+	if want := (Stats{Bytes: uint64(len(a) + 3 + len(b)), Packets: 2, Resyncs: 1, SkippedBytes: 3}); f.Stats() != want {
+		t.Errorf("garbage: stats %+v, want %+v", f.Stats(), want)
+	}
+
+	// A length beyond the limit is corruption, not a packet to wait for
+	f = NewFramer(bytes.NewReader(append([]byte{0x08, 0x01, 0xC0, 0x00, 0xFF, 0xFF}, a...)), 64, nil)
+	got, _ = frameAll(f)
+	checkPackets(t, got, a)
+	if want := (Stats{Bytes: uint64(6 + len(a)), Packets: 1, Resyncs: 1, SkippedBytes: 6}); f.Stats() != want {
+		t.Errorf("oversized length: stats %+v, want %+v", f.Stats(), want)
+	}
+
+	// So is an APID the stream is not expected to carry
+	f = NewFramer(bytes.NewReader(append(append([]byte(nil), b...), a...)), 64, []uint16{1})
+	got, _ = frameAll(f)
+	checkPackets(t, got, a)
+	if want := (Stats{Bytes: uint64(len(b) + len(a)), Packets: 1, Resyncs: 1, SkippedBytes: uint64(len(b))}); f.Stats() != want {
+		t.Errorf("unlisted APID: stats %+v, want %+v", f.Stats(), want)
+	}
+}
+
+func TestFramerStreamEnds(t *testing.T) {
+	a, b := packet(1, 10), packet(2, 4)
+
+	// The stream ends inside a packet
+	f := NewFramer(bytes.NewReader(append(append([]byte(nil), a...), b[:len(b)-2]...)), 64, nil)
+	got, err := frameAll(f)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated packet: error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	checkPackets(t, got, a)
+	if want := (Stats{Bytes: uint64(len(a) + len(b) - 2), Packets: 1}); f.Stats() != want {
+		t.Errorf("truncated packet: stats %+v, want %+v", f.Stats(), want)
+	}
+
+	// The stream ends inside a header, whose bytes are skipped
+	f = NewFramer(bytes.NewReader(append(append([]byte(nil), a...), b[:3]...)), 64, nil)
+	got, err = frameAll(f)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("partial header: error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	checkPackets(t, got, a)
+	if want := (Stats{Bytes: uint64(len(a) + 3), Packets: 1, SkippedBytes: 3}); f.Stats() != want {
+		t.Errorf("partial header: stats %+v, want %+v", f.Stats(), want)
+	}
+
+	if _, err := NewFramer(bytes.NewReader(nil), 64, nil).Next(); err != io.EOF {
+		t.Errorf("empty stream: error %v, want EOF", err)
+	}
+}
+
+func TestFramerChunkedReads(t *testing.T) {
+	// Packets split across reads are framed as if they had arrived whole
+	a, b, c := packet(1, 10), packet(2, 30), packet(3, 1)
+	stream := bytes.Join([][]byte{a, b, c}, nil)
+	f := NewFramer(io.MultiReader(
+		bytes.NewReader(stream[:3]), bytes.NewReader(stream[3:20]), bytes.NewReader(stream[20:]),
+	), 64, nil)
+
+	got, err := frameAll(f)
+	if err != io.EOF {
+		t.Fatalf("error %v, want EOF", err)
+	}
+	checkPackets(t, got, a, b, c)
+}
@@ -0,0 +1,241 @@
+package guard
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+)
+
+// HMACSize is the length of the HMAC-SHA256 trailer on authenticated datagrams
+const HMACSize = sha256.Size
+
+// logInterval limits how often each kind of rejection is logged
+const logInterval = 10 * time.Second
+
+var (
+	// ErrNotAllowed is returned for sources outside the listener's allow-list
+	ErrNotAllowed = errors.New("source not allowed")
+	// ErrRateLimited is returned when a source exceeds its packet rate
+	ErrRateLimited = errors.New("source rate limited")
+	// ErrBadHMAC is returned for datagrams whose HMAC trailer is missing or wrong
+	ErrBadHMAC = errors.New("invalid HMAC trailer")
+)
+
+// Stats counts accepted and rejected datagrams
+type Stats struct {
+	Accepted    uint64 `json:"accepted"`
+	NotAllowed  uint64 `json:"not_allowed"`
+	RateLimited uint64 `json:"rate_limited"`
+	BadHMAC     uint64 `json:"bad_hmac"`
+}
+
+// bucket is the token bucket of one source address
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rejectLog throttles the log messages of one kind of rejection
+type rejectLog struct {
+	last       time.Time
+	suppressed int
+}
+
+// Guard applies a listener's security controls: a CIDR allow-list, a
+// per-source token bucket and an optional HMAC-SHA256 trailer. A Guard is
+// safe for concurrent use.
+type Guard struct {
+	name  string
+	allow []*net.IPNet
+	rate  float64
+	burst float64
+	key   []byte
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	logs    map[error]*rejectLog
+
+	accepted    atomic.Uint64
+	notAllowed  atomic.Uint64
+	rateLimited atomic.Uint64
+	badHMAC     atomic.Uint64
+}
+
+// NewGuard creates the guard for a listener
+func NewGuard(cfg config.ListenerConfig) *Guard {
+	burst := float64(cfg.Burst)
+	if burst <= 0 {
+		// Allow one second worth of packets by default
+		burst = cfg.Rate
+		if burst < 1 {
+			burst = 1
+		}
+	}
+
+	return &Guard{
+		name:    cfg.Name,
+		allow:   cfg.Allow,
+		rate:    cfg.Rate,
+		burst:   burst,
+		key:     cfg.HMACKey,
+		buckets: make(map[string]*bucket),
+		logs:    make(map[error]*rejectLog),
+	}
+}
+
+// Admit checks a source against the allow-list. TCP listeners call it once
+// per connection.
+func (g *Guard) Admit(ip net.IP, now time.Time) error {
+	if !g.allowed(ip) {
+		g.reject(ErrNotAllowed, ip, now)
+		return ErrNotAllowed
+	}
+	return nil
+}
+
+// Check applies every control to a datagram from ip. It returns the data
+// with any HMAC trailer removed.
+func (g *Guard) Check(ip net.IP, data []byte, now time.Time) ([]byte, error) {
+	if err := g.Admit(ip, now); err != nil {
+		return nil, err
+	}
+	if !g.take(ip, now) {
+		g.reject(ErrRateLimited, ip, now)
+		return nil, ErrRateLimited
+	}
+	if g.key != nil {
+		var ok bool
+		if data, ok = g.verify(data); !ok {
+			g.reject(ErrBadHMAC, ip, now)
+			return nil, ErrBadHMAC
+		}
+	}
+	g.accepted.Add(1)
+	return data, nil
+}
+
+// Expire forgets sources whose buckets have refilled
+func (g *Guard) Expire(now time.Time) {
+	if g.rate <= 0 {
+		return
+	}
+	idle := time.Duration(g.burst / g.rate * float64(time.Second))
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for source, b := range g.buckets {
+		if now.Sub(b.last) >= idle {
+			delete(g.buckets, source)
+		}
+	}
+}
+
+// Stats returns the guard's counters
+func (g *Guard) Stats() Stats {
+	return Stats{
+		Accepted:    g.accepted.Load(),
+		NotAllowed:  g.notAllowed.Load(),
+		RateLimited: g.rateLimited.Load(),
+		BadHMAC:     g.badHMAC.Load(),
+	}
+}
+
+// allowed reports whether ip matches the allow-list. An empty list allows all.
+func (g *Guard) allowed(ip net.IP) bool {
+	if len(g.allow) == 0 {
+		return true
+	}
+	for _, network := range g.allow {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// take removes a token from the source's bucket
+func (g *Guard) take(ip net.IP, now time.Time) bool {
+	if g.rate <= 0 {
+		return true
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	source := ip.String()
+	b, ok := g.buckets[source]
+	if !ok {
+		b = &bucket{tokens: g.burst, last: now}
+		g.buckets[source] = b
+	}
+
+	// Refill for the time since the last packet
+	b.tokens += now.Sub(b.last).Seconds() * g.rate
+	if b.tokens > g.burst {
+		b.tokens = g.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// verify checks and strips the HMAC trailer
+func (g *Guard) verify(data []byte) ([]byte, bool) {
+	if len(data) < HMACSize {
+		return nil, false
+	}
+	body, trailer := data[:len(data)-HMACSize], data[len(data)-HMACSize:]
+
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), trailer) {
+		return nil, false
+	}
+	return body, true
+}
+
+// reject counts a rejection and logs it, at most once per logInterval for
+// each kind so that a flood cannot flood the log as well
+func (g *Guard) reject(reason error, ip net.IP, now time.Time) {
+	switch reason {
+	case ErrNotAllowed:
+		g.notAllowed.Add(1)
+	case ErrRateLimited:
+		g.rateLimited.Add(1)
+	case ErrBadHMAC:
+		g.badHMAC.Add(1)
+	}
+
+	g.mu.Lock()
+	l, ok := g.logs[reason]
+	if !ok {
+		l = &rejectLog{}
+		g.logs[reason] = l
+	}
+	if now.Sub(l.last) < logInterval {
+		l.suppressed++
+		g.mu.Unlock()
+		return
+	}
+	suppressed := l.suppressed
+	l.last = now
+	l.suppressed = 0
+	g.mu.Unlock()
+
+	if suppressed > 0 {
+		log.Printf("Rejected packet from %s on %s: %v (%d similar rejections not logged)", ip, g.name, reason, suppressed)
+	} else {
+		log.Printf("Rejected packet from %s on %s: %v", ip, g.name, reason)
+	}
+}
@@ -0,0 +1,118 @@
+package guard
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+)
+
+var (
+	start   = time.Unix(1700000000, 0)
+	inside  = net.ParseIP("10.1.2.3")
+	outside = net.ParseIP("192.168.1.1")
+	payload = []byte("telemetry")
+)
+
+// signed appends an HMAC-SHA256 trailer to data
+func signed(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(append([]byte(nil), data...))
+}
+
+func TestGuardOpenListener(t *testing.T) {
+	g := NewGuard(config.ListenerConfig{})
+	data, err := g.Check(outside, payload, start)
+	if err != nil || string(data) != string(payload) {
+		t.Fatalf("check returned %q (%v), want the datagram unchanged", data, err)
+	}
+	if want := (Stats{Accepted: 1}); g.Stats() != want {
+		t.Errorf("stats %+v, want %+v", g.Stats(), want)
+	}
+}
+
+func TestGuardAllowList(t *testing.T) {
+	_, lan, _ := net.ParseCIDR("10.0.0.0/8")
+	g := NewGuard(config.ListenerConfig{Allow: []*net.IPNet{lan}})
+
+	if _, err := g.Check(inside, payload, start); err != nil {
+		t.Errorf("source inside the allow-list: %v", err)
+	}
+	if _, err := g.Check(outside, payload, start); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("source outside the allow-list: error %v, want %v", err, ErrNotAllowed)
+	}
+	if err := g.Admit(outside, start); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("connection from outside the allow-list: error %v, want %v", err, ErrNotAllowed)
+	}
+	if want := (Stats{Accepted: 1, NotAllowed: 2}); g.Stats() != want {
+		t.Errorf("stats %+v, want %+v", g.Stats(), want)
+	}
+}
+
+func TestGuardRateLimit(t *testing.T) {
+	g := NewGuard(config.ListenerConfig{Rate: 2, Burst: 2})
+
+	// The burst is spent, then the source must wait for a refill
+	for i, want := range []error{nil, nil, ErrRateLimited} {
+		if _, err := g.Check(inside, payload, start); !errors.Is(err, want) {
+			t.Fatalf("datagram %d: error %v, want %v", i, err, want)
+		}
+	}
+
+	// Each source has its own bucket
+	if _, err := g.Check(outside, payload, start); err != nil {
+		t.Errorf("second source: %v", err)
+	}
+
+	// Half a second at two per second refills one token
+	if _, err := g.Check(inside, payload, start.Add(500*time.Millisecond)); err != nil {
+		t.Errorf("after refilling: %v", err)
+	}
+	if want := (Stats{Accepted: 4, RateLimited: 1}); g.Stats() != want {
+		t.Errorf("stats %+v, want %+v", g.Stats(), want)
+	}
+}
+
+func TestGuardHMAC(t *testing.T) {
+	key := []byte("secret")
+	g := NewGuard(config.ListenerConfig{HMACKey: key})
+
+	// The trailer is stripped from authenticated datagrams
+	data, err := g.Check(inside, signed(key, payload), start)
+	if err != nil || string(data) != string(payload) {
+		t.Fatalf("signed datagram returned %q (%v), want %q", data, err, payload)
+	}
+
+	for name, data := range map[string][]byte{
+		"unsigned":               payload,
+		"wrong key":              signed([]byte("other"), payload),
+		"trailing bytes":         append(signed(key, payload), 0),
+		"shorter than a trailer": payload[:4],
+	} {
+		if _, err := g.Check(inside, data, start); !errors.Is(err, ErrBadHMAC) {
+			t.Errorf("%s: error %v, want %v", name, err, ErrBadHMAC)
+		}
+	}
+	if want := (Stats{Accepted: 1, BadHMAC: 4}); g.Stats() != want {
+		t.Errorf("stats %+v, want %+v", g.Stats(), want)
+	}
+}
+
+func TestGuardExpire(t *testing.T) {
+	g := NewGuard(config.ListenerConfig{Rate: 10, Burst: 5})
+	g.Check(inside, nil, start)
+
+	g.Expire(start.Add(100 * time.Millisecond))
+	if len(g.buckets) != 1 {
+		t.Fatal("bucket expired before it refilled")
+	}
+	g.Expire(start.Add(500 * time.Millisecond))
+	if len(g.buckets) != 0 {
+		t.Fatal("refilled bucket was kept")
+	}
+}
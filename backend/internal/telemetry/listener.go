@@ -0,0 +1,389 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/framing"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/guard"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+const (
+	// maxDatagramSize is the largest UDP payload a listener will accept
+	maxDatagramSize = 65535
+	// maxPacketSize is the largest space packet a primary header can describe
+	maxPacketSize = ccsds.PrimaryHeaderSize + ccsds.MaxDataLength
+	// udpQueueSize is the number of extracted packets a UDP listener holds
+	// for its worker before it stops reading from the socket
+	udpQueueSize = 1024
+)
+
+// ListenerStats describes the traffic seen by one listener
+type ListenerStats struct {
+	Name         string            `json:"name"`
+	Protocol     string            `json:"protocol"`
+	Address      string            `json:"address"`
+	Port         string            `json:"port"`
+	Multicast    bool              `json:"multicast"`
+	SpacecraftID uint16            `json:"spacecraft_id"`
+	Stream       string            `json:"stream"`
+	Datagrams    uint64            `json:"datagrams"`
+	Bytes        uint64            `json:"bytes"`
+	Archive      ArchiveStats      `json:"archive"`
+	Connections  []ConnectionStats `json:"connections,omitempty"`
+	Security     guard.Stats       `json:"security"`
+	PipelineStats
+}
+
+// ArchiveStats counts the datagrams a listener queued for the raw packet
+// archive and those dropped because the archive could not keep up
+type ArchiveStats struct {
+	Queued  uint64 `json:"queued"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// ConnectionStats describes one TCP telemetry connection
+type ConnectionStats struct {
+	Remote      string    `json:"remote"`
+	ConnectedAt time.Time `json:"connected_at"`
+	framing.Stats
+	Stored uint64 `json:"stored"`
+	Failed uint64 `json:"failed"`
+}
+
+// listener receives telemetry on one configured endpoint. A UDP listener
+// feeds one pipeline; every TCP connection gets its own, so that reassembly
+// and frame state of concurrent streams cannot mix.
+type listener struct {
+	server    *TelemetryServer
+	cfg       config.ListenerConfig
+	pipeline  *Pipeline // UDP only
+	guard     *guard.Guard
+	datagrams atomic.Uint64
+	bytes     atomic.Uint64
+	queued    atomic.Uint64
+	dropped   atomic.Uint64
+	conns     map[*tcpConn]struct{}
+	closed    PipelineStats // Pipeline totals of closed connections
+	connsMu   sync.Mutex
+}
+
+// tcpConn tracks the pipeline and statistics of an open connection
+type tcpConn struct {
+	pipeline *Pipeline
+	mu       sync.Mutex
+	stats    ConnectionStats
+}
+
+func newListener(server *TelemetryServer, cfg config.ListenerConfig) *listener {
+	l := &listener{
+		server: server,
+		cfg:    cfg,
+		guard:  guard.NewGuard(cfg),
+		conns:  make(map[*tcpConn]struct{}),
+	}
+	if cfg.Protocol != "tcp" {
+		l.pipeline = server.newPipeline(cfg)
+	}
+	return l
+}
+
+// serve runs the listener until a fatal error
+func (l *listener) serve() {
+	if l.cfg.Protocol == "tcp" {
+		l.serveTCP()
+	} else {
+		l.serveUDP()
+	}
+}
+
+// snapshot returns the listener's current statistics
+func (l *listener) snapshot() ListenerStats {
+	stats := ListenerStats{
+		Name:         l.cfg.Name,
+		Protocol:     l.cfg.Protocol,
+		Address:      l.cfg.Address,
+		Port:         l.cfg.Port,
+		Multicast:    l.cfg.Multicast(),
+		SpacecraftID: l.cfg.SpacecraftID,
+		Stream:       l.cfg.Stream,
+		Datagrams:    l.datagrams.Load(),
+		Bytes:        l.bytes.Load(),
+		Archive:      ArchiveStats{Queued: l.queued.Load(), Dropped: l.dropped.Load()},
+		Security:     l.guard.Stats(),
+	}
+	if l.pipeline != nil {
+		stats.PipelineStats = l.pipeline.Stats()
+	}
+
+	l.connsMu.Lock()
+	stats.PipelineStats.add(l.closed)
+	for c := range l.conns {
+		stats.PipelineStats.add(c.pipeline.Stats())
+		c.mu.Lock()
+		stats.Connections = append(stats.Connections, c.stats)
+		c.mu.Unlock()
+	}
+	l.connsMu.Unlock()
+
+	sort.Slice(stats.Connections, func(i, j int) bool {
+		return stats.Connections[i].ConnectedAt.Before(stats.Connections[j].ConnectedAt)
+	})
+	return stats
+}
+
+// expire discards timed-out segment groups and idle rate limit state
+func (l *listener) expire(now time.Time) {
+	if l.pipeline != nil {
+		l.pipeline.Expire(now)
+	}
+
+	l.connsMu.Lock()
+	for c := range l.conns {
+		c.pipeline.Expire(now)
+	}
+	l.connsMu.Unlock()
+
+	l.guard.Expire(now)
+}
+
+// listenUDP opens a unicast or multicast UDP socket for the listener
+func (l *listener) listenUDP() (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(l.cfg.Address, l.cfg.Port))
+	if err != nil {
+		return nil, err
+	}
+
+	var ifi *net.Interface
+	if l.cfg.Interface != "" {
+		ifi, err = net.InterfaceByName(l.cfg.Interface)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if l.cfg.Multicast() {
+		return net.ListenMulticastUDP("udp", ifi, addr)
+	}
+
+	// Bind to the interface's address when one is named without an address
+	if ifi != nil && l.cfg.Address == "" {
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("interface %s has no addresses", ifi.Name)
+		}
+		if ipnet, ok := addrs[0].(*net.IPNet); ok {
+			addr.IP = ipnet.IP
+		}
+	}
+	return net.ListenUDP("udp", addr)
+}
+
+// serveUDP receives datagrams until the socket fails
+func (l *listener) serveUDP() {
+	conn, err := l.listenUDP()
+	if err != nil {
+		log.Fatalf("UDP listen error on %s: %v", l.cfg.Name, err)
+	}
+	defer conn.Close()
+
+	log.Printf("UDP listener %s on port %s (%s mode)...", l.cfg.Name, l.cfg.Port, l.cfg.Mode)
+
+	buffer := make([]byte, maxDatagramSize) // Buffer for incoming packets
+
+	// One worker stores packets in the order they were extracted
+	packets := make(chan Packet, udpQueueSize)
+	defer close(packets)
+	go l.handlePackets(packets)
+
+	// Continuously listen for packets
+	for {
+		n, source, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			log.Printf("Error receiving UDP packet on %s: %v", l.cfg.Name, err)
+			continue
+		}
+		receivedAt := time.Now()
+		l.datagrams.Add(1)
+		l.bytes.Add(uint64(n))
+
+		// Drop datagrams from unknown, flooding or unauthenticated sources
+		data, err := l.guard.Check(source.IP, buffer[:n], receivedAt)
+		if err != nil {
+			continue
+		}
+
+		// Keep the original bytes so history can be reprocessed later
+		l.archive(receivedAt, source.String(), data)
+
+		// Reassemble in arrival order, then hand each packet to the worker
+		for _, packet := range l.pipeline.Extract(data, receivedAt) {
+			packets <- packet
+		}
+	}
+}
+
+// handlePackets processes the packets a UDP listener extracts until the
+// channel is closed
+func (l *listener) handlePackets(packets <-chan Packet) {
+	for packet := range packets {
+		l.pipeline.Handle(packet)
+	}
+}
+
+// archive queues received bytes for the raw packet archive when it is enabled
+func (l *listener) archive(receivedAt time.Time, source string, data []byte) {
+	if !l.server.cfg.ArchiveRaw {
+		return
+	}
+	if l.server.archive(l.rawPacket(receivedAt, source, data)) {
+		l.queued.Add(1)
+	} else if l.dropped.Add(1)%1000 == 1 {
+		log.Printf("Raw packet archive queue full on %s; %d datagrams dropped so far", l.cfg.Name, l.dropped.Load())
+	}
+}
+
+// rawPacket builds the archive record for received bytes
+func (l *listener) rawPacket(receivedAt time.Time, source string, data []byte) models.RawPacket {
+	return models.RawPacket{
+		ReceivedAt:   receivedAt,
+		Source:       source,
+		Mode:         l.cfg.Mode,
+		SpacecraftID: l.cfg.SpacecraftID,
+		Stream:       l.cfg.Stream,
+		Data:         append([]byte(nil), data...),
+	}
+}
+
+// serveTCP accepts connections carrying a stream of space packets. Each
+// connection is framed and processed in its own goroutine.
+func (l *listener) serveTCP() {
+	ln, err := net.Listen("tcp", net.JoinHostPort(l.cfg.Address, l.cfg.Port))
+	if err != nil {
+		log.Fatalf("TCP listen error on %s: %v", l.cfg.Name, err)
+	}
+	defer ln.Close()
+
+	log.Printf("TCP listener %s on port %s...", l.cfg.Name, l.cfg.Port)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Printf("Error accepting TCP connection on %s: %v", l.cfg.Name, err)
+			continue
+		}
+		go l.handleConn(conn)
+	}
+}
+
+// handleConn frames and processes packets until the connection closes
+func (l *listener) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	remote := conn.RemoteAddr().String()
+	var ip net.IP
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		ip = addr.IP
+	}
+	if err := l.guard.Admit(ip, time.Now()); err != nil {
+		return
+	}
+
+	c := &tcpConn{
+		pipeline: l.server.newPipeline(l.cfg),
+		stats:    ConnectionStats{Remote: remote, ConnectedAt: time.Now()},
+	}
+
+	l.connsMu.Lock()
+	l.conns[c] = struct{}{}
+	l.connsMu.Unlock()
+
+	log.Printf("TCP telemetry connection from %s on %s", remote, l.cfg.Name)
+
+	// Packet streams are framed by their headers; CADU streams are passed on
+	// in whatever chunks arrive and synchronised by the pipeline
+	var framer *framing.Framer
+	var chunk []byte
+	if l.cfg.Mode == config.IngestModeCADU {
+		chunk = make([]byte, maxDatagramSize)
+	} else {
+		framer = framing.NewFramer(conn, maxPacketSize, l.server.cfg.TCPAPIDs)
+	}
+
+	for {
+		var data []byte
+		var err error
+		if framer != nil {
+			data, err = framer.Next()
+		} else {
+			var n int
+			n, err = conn.Read(chunk)
+			data = chunk[:n]
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("TCP connection %s: %v", remote, err)
+			}
+			break
+		}
+		receivedAt := time.Now()
+		read := uint64(len(data))
+		l.datagrams.Add(1)
+		l.bytes.Add(read)
+
+		// Process what the guard passes on, as UDP listeners do, so any
+		// control that rewrites data applies to streams too
+		if data, err = l.guard.Check(ip, data, receivedAt); err != nil {
+			continue
+		}
+
+		l.archive(receivedAt, remote, data)
+
+		var stored, failed uint64
+		for _, packet := range c.pipeline.Extract(data, receivedAt) {
+			if err := c.pipeline.Handle(packet); err != nil {
+				failed++
+			} else {
+				stored++
+			}
+		}
+
+		c.mu.Lock()
+		if framer != nil {
+			c.stats.Stats = framer.Stats()
+		} else {
+			c.stats.Bytes += read
+		}
+		c.stats.Stored += stored
+		c.stats.Failed += failed
+		c.mu.Unlock()
+	}
+
+	l.connsMu.Lock()
+	delete(l.conns, c)
+	l.closed.add(c.pipeline.Stats())
+	l.connsMu.Unlock()
+
+	c.mu.Lock()
+	if framer != nil {
+		c.stats.Stats = framer.Stats()
+	}
+	final := c.stats
+	c.mu.Unlock()
+
+	log.Printf("TCP connection %s closed: %d bytes, %d packets, %d stored, %d failed, %d resyncs, %d bytes skipped",
+		remote, final.Bytes, final.Packets, final.Stored, final.Failed, final.Resyncs, final.SkippedBytes)
+}
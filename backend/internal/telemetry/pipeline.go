@@ -0,0 +1,244 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/commanding"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/repository"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/clock"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/coding"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/frames"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/processor"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/reassembly"
+)
+
+// ErrStoreFailed is returned by Handle when decoded telemetry could not be stored
+var ErrStoreFailed = errors.New("failed to store telemetry")
+
+// Packet is a complete space packet ready to be decoded
+type Packet struct {
+	SpacecraftID uint16
+	Data         []byte
+	ReceivedAt   time.Time
+}
+
+// Pipeline turns received datagrams into stored telemetry: channel
+// decoding, frame demultiplexing, segment reassembly, decoding, limit checking, clock
+// correlation and storage
+type Pipeline struct {
+	repo        *repository.TelemetryRepository
+	processor   *processor.TelemetryProcessor
+	reassembler *reassembly.Reassembler
+	demux       *frames.Demultiplexer
+	decoder     *coding.Decoder // Set in CADU mode only
+	clock       *clock.Correlator
+	verifier    *commanding.Verifier // Receives command acknowledgements; nil when replaying
+	mode        string
+	spacecraft  uint16 // Spacecraft tag for packets that do not carry one
+	stream      string
+	dataset     string
+	broadcast   bool
+	storeErrors atomic.Uint64
+}
+
+// PipelineStats counts the outcome of every packet handled by a pipeline
+type PipelineStats struct {
+	processor.Stats
+	StoreErrors uint64        `json:"store_errors"`
+	Link        *coding.Stats `json:"link,omitempty"`   // Channel decoding counters in CADU mode
+	Frames      *frames.Stats `json:"frames,omitempty"` // Transfer frame counters in frame and CADU modes
+}
+
+// NewPipeline creates the pipeline for live telemetry arriving on the
+// endpoint described by source. verifier may be nil.
+func NewPipeline(repo *repository.TelemetryRepository, correlator *clock.Correlator, verifier *commanding.Verifier, cfg *config.IngestConfig, source config.ListenerConfig) *Pipeline {
+	p := &Pipeline{
+		repo:        repo,
+		processor:   processor.NewTelemetryProcessor(cfg),
+		reassembler: reassembly.NewReassembler(cfg),
+		demux:       frames.NewDemultiplexer(cfg),
+		clock:       correlator,
+		verifier:    verifier,
+		mode:        source.Mode,
+		spacecraft:  source.SpacecraftID,
+		stream:      source.Stream,
+		dataset:     models.DatasetLive,
+		broadcast:   true,
+	}
+	if source.Mode == config.IngestModeCADU {
+		p.decoder = coding.NewDecoder(cfg)
+	}
+	return p
+}
+
+// NewReplayPipeline creates a pipeline that stores into dataset without
+// broadcasting. It keeps its own reassembly and clock state so a replay
+// cannot disturb live ingest.
+func NewReplayPipeline(repo *repository.TelemetryRepository, cfg *config.IngestConfig, source config.ListenerConfig, dataset string) *Pipeline {
+	p := NewPipeline(repo, clock.NewCorrelator(cfg), nil, cfg, source)
+	p.dataset = dataset
+	p.broadcast = false
+	return p
+}
+
+// Extract demultiplexes and reassembles one datagram, returning the complete
+// packets it produced. It must be called in arrival order.
+func (p *Pipeline) Extract(data []byte, receivedAt time.Time) []Packet {
+	switch p.mode {
+	case config.IngestModeFrame:
+		return p.demultiplex(data, receivedAt)
+	case config.IngestModeCADU:
+		var packets []Packet
+		for _, frame := range p.decoder.Push(data) {
+			packets = append(packets, p.demultiplex(frame, receivedAt)...)
+		}
+		return packets
+	default:
+		return p.reassemble(p.spacecraft, data, receivedAt)
+	}
+}
+
+// demultiplex extracts the packets completed by one transfer frame
+func (p *Pipeline) demultiplex(frame []byte, receivedAt time.Time) []Packet {
+	extracted, err := p.demux.Push(frame)
+	if err != nil {
+		log.Printf("Error decoding transfer frame: %v", err)
+		return nil
+	}
+
+	var packets []Packet
+	for _, e := range extracted {
+		packets = append(packets, p.reassemble(e.SpacecraftID, e.Data, receivedAt)...)
+	}
+	return packets
+}
+
+// reassemble buffers segments until their packet is complete
+func (p *Pipeline) reassemble(spacecraftID uint16, data []byte, receivedAt time.Time) []Packet {
+	packet, err := p.reassembler.Push(spacecraftID, data, receivedAt)
+	if err != nil {
+		log.Printf("Error reassembling telemetry packet: %v", err)
+		return nil
+	}
+	if packet == nil {
+		return nil
+	}
+	return []Packet{{SpacecraftID: spacecraftID, Data: packet, ReceivedAt: receivedAt}}
+}
+
+// Expire discards segment groups that have waited too long
+func (p *Pipeline) Expire(now time.Time) {
+	p.reassembler.Expire(now)
+}
+
+// Handle decodes a complete packet and stores the resulting telemetry
+func (p *Pipeline) Handle(packet Packet) error {
+	// Command acknowledgements are not telemetry records
+	if p.verifier != nil && p.verifier.HandleAck(packet.SpacecraftID, packet.Data, packet.ReceivedAt) {
+		return nil
+	}
+
+	// Process the packet using the telemetry processor
+	telemetry, err := p.processor.ProcessPacket(packet.Data)
+	if err != nil {
+		log.Printf("Error processing telemetry packet: %v", err)
+		return err
+	}
+	telemetry.SpacecraftID = packet.SpacecraftID
+	telemetry.ReceivedAt = packet.ReceivedAt
+	telemetry.Stream = p.stream
+	telemetry.Dataset = p.dataset
+
+	// Check the onboard time against ground receipt time
+	telemetry.Timestamp, telemetry.TimeQuality = p.clock.Correlate(packet.SpacecraftID, telemetry.Timestamp, packet.ReceivedAt)
+	if telemetry.TimeQuality != models.TimeQualityGood && telemetry.TimeQuality != models.TimeQualityCorrected {
+		log.Printf("Implausible onboard time from SCID %d: %s (%s)", packet.SpacecraftID, telemetry.Timestamp.Format(time.RFC3339), telemetry.TimeQuality)
+	}
+
+	// Store the telemetry data
+	if p.broadcast {
+		err = p.repo.InsertTelemetry(telemetry)
+	} else {
+		err = p.repo.InsertTelemetryQuietly(telemetry)
+	}
+	if err != nil {
+		p.storeErrors.Add(1)
+		log.Printf("Failed to insert telemetry: %v", err)
+		return fmt.Errorf("%w: %w", ErrStoreFailed, err)
+	}
+
+	if p.verifier != nil {
+		p.verifier.ObserveTelemetry(telemetry)
+	}
+	return nil
+}
+
+// Stats returns the decode and storage counters of the pipeline
+func (p *Pipeline) Stats() PipelineStats {
+	stats := PipelineStats{
+		Stats:       p.processor.Stats(),
+		StoreErrors: p.storeErrors.Load(),
+	}
+	if p.decoder != nil {
+		link := p.decoder.Stats()
+		stats.Link = &link
+	}
+	if p.mode == config.IngestModeFrame || p.mode == config.IngestModeCADU {
+		frameStats := p.demux.Stats()
+		stats.Frames = &frameStats
+	}
+	return stats
+}
+
+// add accumulates the counters of another pipeline into s
+func (s *PipelineStats) add(o PipelineStats) {
+	s.Processed += o.Processed
+	s.CRCErrors += o.CRCErrors
+	s.DecodeErrors += o.DecodeErrors
+	s.StoreErrors += o.StoreErrors
+
+	if o.Link != nil {
+		if s.Link == nil {
+			s.Link = &coding.Stats{}
+		}
+		s.Link.Codeblocks += o.Link.Codeblocks
+		s.Link.Codewords += o.Link.Codewords
+		s.Link.CorrectedSymbols += o.Link.CorrectedSymbols
+		s.Link.Uncorrectable += o.Link.Uncorrectable
+		s.Link.DroppedFrames += o.Link.DroppedFrames
+		s.Link.SyncLosses += o.Link.SyncLosses
+		s.Link.SkippedBytes += o.Link.SkippedBytes
+	}
+
+	if o.Frames != nil {
+		if s.Frames == nil {
+			s.Frames = &frames.Stats{}
+		}
+		s.Frames.FECFErrors += o.Frames.FECFErrors
+		s.Frames.DecodeErrors += o.Frames.DecodeErrors
+		for _, ch := range o.Frames.Channels {
+			s.Frames.Channels = addChannel(s.Frames.Channels, ch)
+		}
+	}
+}
+
+// addChannel adds a virtual channel's counters to the matching entry of
+// channels, appending one if there is none
+func addChannel(channels []frames.ChannelReport, ch frames.ChannelReport) []frames.ChannelReport {
+	for i := range channels {
+		if channels[i].SpacecraftID == ch.SpacecraftID && channels[i].VirtualChannelID == ch.VirtualChannelID {
+			channels[i].Frames += ch.Frames
+			channels[i].LostFrames += ch.LostFrames
+			channels[i].Packets += ch.Packets
+			channels[i].Discarded += ch.Discarded
+			return channels
+		}
+	}
+	return append(channels, ch)
+}
@@ -1,63 +1,154 @@
 package processor
 
 import (
-	"bytes"
 	"encoding/binary"
-	"time"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
 
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
 	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/timecode"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
 )
 
+// subsystemIDSize is the length of the subsystem ID closing the secondary header
+const subsystemIDSize = 2
+
+var (
+	// ErrCRCMismatch is returned when a packet's error control field does not match its contents
+	ErrCRCMismatch = errors.New("packet error control field mismatch")
+	// ErrTruncated is returned when a packet is shorter than its headers declare
+	ErrTruncated = ccsds.ErrTruncated
+)
+
+// Stats counts the outcome of every packet handed to the processor
+type Stats struct {
+	Processed    uint64 `json:"processed"`
+	CRCErrors    uint64 `json:"crc_errors"`
+	DecodeErrors uint64 `json:"decode_errors"`
+}
+
 // TelemetryProcessor processes CCSDS telemetry packets
-type TelemetryProcessor struct{}
+type TelemetryProcessor struct {
+	crcAPIDs     map[uint16]bool
+	timeDecoder  *timecode.Decoder
+	processed    atomic.Uint64
+	crcErrors    atomic.Uint64
+	decodeErrors atomic.Uint64
+}
 
 // NewTelemetryProcessor creates a new processor instance
-func NewTelemetryProcessor() *TelemetryProcessor {
-	return &TelemetryProcessor{}
+func NewTelemetryProcessor(cfg *config.IngestConfig) *TelemetryProcessor {
+	crcAPIDs := make(map[uint16]bool)
+	for _, apid := range cfg.CRCAPIDs {
+		crcAPIDs[apid] = true
+	}
+
+	timeDecoder, err := timecode.NewDecoder(cfg)
+	if err != nil {
+		log.Fatal("Invalid time code configuration:", err)
+	}
+
+	return &TelemetryProcessor{
+		crcAPIDs:    crcAPIDs,
+		timeDecoder: timeDecoder,
+	}
 }
 
 // ProcessPacket decodes a CCSDS packet and returns a telemetry model
 func (p *TelemetryProcessor) ProcessPacket(data []byte) (models.Telemetry, error) {
-	reader := bytes.NewReader(data)
+	telemetry, err := p.decode(data)
+	switch {
+	case errors.Is(err, ErrCRCMismatch):
+		p.crcErrors.Add(1)
+	case err != nil:
+		p.decodeErrors.Add(1)
+	default:
+		p.processed.Add(1)
+	}
+	return telemetry, err
+}
 
-	// Decode headers and payload
-	primaryHeader := models.CCSDSPrimaryHeader{}
-	secondaryHeader := models.CCSDSSecondaryHeader{}
-	payload := models.TelemetryPayload{}
+// Stats returns a snapshot of the processor counters
+func (p *TelemetryProcessor) Stats() Stats {
+	return Stats{
+		Processed:    p.processed.Load(),
+		CRCErrors:    p.crcErrors.Load(),
+		DecodeErrors: p.decodeErrors.Load(),
+	}
+}
 
-	if err := binary.Read(reader, binary.BigEndian, &primaryHeader); err != nil {
+// decode verifies and unpacks a single packet
+func (p *TelemetryProcessor) decode(data []byte) (models.Telemetry, error) {
+	packet, err := ccsds.Parse(data)
+	if err != nil {
 		return models.Telemetry{}, err
 	}
+	header := packet.Header()
 
-	if err := binary.Read(reader, binary.BigEndian, &secondaryHeader); err != nil {
-		return models.Telemetry{}, err
+	// Verify the packet error control field before trusting any payload value
+	quality := models.QualityUnverified
+	body := packet.Data()
+	if p.crcAPIDs[header.APID()] {
+		if len(body) < ccsds.CRCSize {
+			return models.Telemetry{}, ErrTruncated
+		}
+		end := len(packet) - ccsds.CRCSize
+		want := binary.BigEndian.Uint16(packet[end:])
+		if got := ccsds.CRC16CCITT(packet[:end]); got != want {
+			return models.Telemetry{}, fmt.Errorf("%w: APID %d got %04x, want %04x",
+				ErrCRCMismatch, header.APID(), got, want)
+		}
+		quality = models.QualityVerified
+		body = packet[ccsds.PrimaryHeaderSize:end]
 	}
 
-	if err := binary.Read(reader, binary.BigEndian, &payload); err != nil {
+	// The secondary header is the onboard time code followed by the subsystem ID
+	codeSize := p.timeDecoder.Size()
+	if len(body) < codeSize+subsystemIDSize+ccsds.TelemetryPayloadSize {
+		return models.Telemetry{}, ErrTruncated
+	}
+	timestamp, err := p.timeDecoder.Decode(body[:codeSize])
+	if err != nil {
 		return models.Telemetry{}, err
 	}
+	body = body[codeSize+subsystemIDSize:]
 
-	// Convert timestamp
-	timestamp := time.Unix(int64(secondaryHeader.Timestamp), 0)
-
-	// Check for anomalies
-	anomaly := p.DetectAnomaly(payload)
+	payload, err := ccsds.ParseTelemetryPayload(body)
+	if err != nil {
+		return models.Telemetry{}, err
+	}
+	body = body[ccsds.TelemetryPayloadSize:]
 
 	// Create telemetry record
 	telemetry := models.Telemetry{
-		Timestamp:   timestamp,
-		Temperature: payload.Temperature,
-		Battery:     payload.Battery,
-		Altitude:    payload.Altitude,
-		Signal:      payload.Signal,
-		Anomaly:     anomaly,
+		APID:          header.APID(),
+		SequenceCount: header.SeqCount(),
+		Timestamp:     timestamp,
+		Temperature:   payload.Temperature,
+		Battery:       payload.Battery,
+		Altitude:      payload.Altitude,
+		Signal:        payload.Signal,
+		Anomaly:       p.DetectAnomaly(payload),
+		Quality:       quality,
+	}
+
+	// Spacecraft status follows the payload when the packet has room for it
+	if status, err := ccsds.ParseSpacecraftStatus(body); err == nil {
+		heaterOn := status.HeaterState == 1
+		safeMode := status.SafeMode == 1
+		telemetry.HeaterOn = &heaterOn
+		telemetry.SafeMode = &safeMode
+		telemetry.TxIntervalMs = &status.TxIntervalMs
 	}
 
 	return telemetry, nil
 }
 
 // DetectAnomaly checks telemetry values against defined thresholds
-func (p *TelemetryProcessor) DetectAnomaly(payload models.TelemetryPayload) bool {
+func (p *TelemetryProcessor) DetectAnomaly(payload ccsds.TelemetryPayload) bool {
 	return payload.Temperature > 35.0 ||
 		payload.Battery < 40.0 ||
 		payload.Altitude < 400.0 ||
@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/timecode"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// nominal is a payload inside every anomaly threshold
+var nominal = ccsds.TelemetryPayload{Temperature: 25, Battery: 90, Altitude: 520, Signal: -50}
+
+// encodePacket builds a telemetry packet, ending it with a CRC when withCRC is set
+func encodePacket(apid uint16, payload ccsds.TelemetryPayload, withCRC bool) []byte {
+	var body bytes.Buffer
+	binary.Write(&body, binary.BigEndian, ccsds.SecondaryHeader{Timestamp: 1700000000, SubsystemID: 1})
+	binary.Write(&body, binary.BigEndian, payload)
+
+	length := body.Len()
+	if withCRC {
+		length += ccsds.CRCSize
+	}
+	var packet bytes.Buffer
+	binary.Write(&packet, binary.BigEndian, ccsds.PrimaryHeader{
+		PacketID:      0x0800 | apid,
+		PacketSeqCtrl: 0xC000,
+		PacketLength:  uint16(length - 1),
+	})
+	packet.Write(body.Bytes())
+	if withCRC {
+		binary.Write(&packet, binary.BigEndian, ccsds.CRC16CCITT(packet.Bytes()))
+	}
+	return packet.Bytes()
+}
+
+func TestProcessPacketQuality(t *testing.T) {
+	p := NewTelemetryProcessor(&config.IngestConfig{TimeCode: timecode.FormatUnix, CRCAPIDs: []uint16{2}})
+
+	// APIDs without a configured CRC are stored unverified
+	telemetry, err := p.ProcessPacket(encodePacket(1, nominal, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if telemetry.Quality != models.QualityUnverified {
+		t.Errorf("APID 1 quality %q, want %q", telemetry.Quality, models.QualityUnverified)
+	}
+
+	telemetry, err = p.ProcessPacket(encodePacket(2, nominal, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if telemetry.Quality != models.QualityVerified {
+		t.Errorf("APID 2 quality %q, want %q", telemetry.Quality, models.QualityVerified)
+	}
+	if telemetry.Timestamp.Unix() != 1700000000 || telemetry.Temperature != nominal.Temperature || telemetry.Signal != nominal.Signal || telemetry.Anomaly {
+		t.Errorf("decoded %+v from %+v", telemetry, nominal)
+	}
+}
+
+func TestProcessPacketRejectsBadCRC(t *testing.T) {
+	p := NewTelemetryProcessor(&config.IngestConfig{TimeCode: timecode.FormatUnix, CRCAPIDs: []uint16{2}})
+
+	packet := encodePacket(2, nominal, true)
+	packet[len(packet)-5] ^= 0x10 // Inside the signal value
+	if _, err := p.ProcessPacket(packet); !errors.Is(err, ErrCRCMismatch) {
+		t.Fatalf("corrupt packet: error %v, want %v", err, ErrCRCMismatch)
+	}
+
+	// A configured APID without room for its CRC is truncated
+	short := encodePacket(2, nominal, true)[:ccsds.PrimaryHeaderSize+1]
+	short[5] = 0
+	if _, err := p.ProcessPacket(short); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("short packet: error %v, want %v", err, ErrTruncated)
+	}
+
+	if stats := p.Stats(); stats != (Stats{CRCErrors: 1, DecodeErrors: 1}) {
+		t.Errorf("stats %+v, want one CRC and one decode error", stats)
+	}
+}
+
+func TestDetectAnomaly(t *testing.T) {
+	p := NewTelemetryProcessor(&config.IngestConfig{TimeCode: timecode.FormatUnix})
+	if p.DetectAnomaly(nominal) {
+		t.Errorf("%+v flagged as an anomaly", nominal)
+	}
+
+	for _, payload := range []ccsds.TelemetryPayload{
+		{Temperature: 36, Battery: 90, Altitude: 520, Signal: -50},
+		{Temperature: 25, Battery: 39, Altitude: 520, Signal: -50},
+		{Temperature: 25, Battery: 90, Altitude: 399, Signal: -50},
+		{Temperature: 25, Battery: 90, Altitude: 520, Signal: -81},
+	} {
+		if !p.DetectAnomaly(payload) {
+			t.Errorf("%+v not flagged as an anomaly", payload)
+		}
+	}
+}
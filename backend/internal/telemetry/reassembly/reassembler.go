@@ -0,0 +1,208 @@
+package reassembly
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// ErrShortPacket is returned when a datagram is smaller than its headers claim
+var ErrShortPacket = errors.New("packet shorter than primary header length")
+
+// groupKey identifies the packet stream a segment belongs to
+type groupKey struct {
+	spacecraftID uint16
+	apid         uint16
+}
+
+// Reassembler joins segmented CCSDS packets back into complete packets.
+// Segments are buffered per spacecraft and APID and released once the first segment,
+// the last segment and every sequence count in between have arrived.
+type Reassembler struct {
+	mu          sync.Mutex
+	groups      map[groupKey]*group
+	timeout     time.Duration
+	maxBytes    int
+	maxSegments int
+}
+
+// group holds the segments received so far for one packet stream
+type group struct {
+	segments  map[uint16][]byte // Data fields keyed by sequence count
+	header    ccsds.PrimaryHeader
+	firstSeq  uint16
+	lastSeq   uint16
+	haveFirst bool
+	haveLast  bool
+	size      int
+	started   time.Time
+}
+
+// NewReassembler creates a reassembler using the limits in cfg
+func NewReassembler(cfg *config.IngestConfig) *Reassembler {
+	maxBytes := cfg.ReassemblyMaxBytes
+	if maxBytes <= 0 || maxBytes > ccsds.MaxDataLength {
+		maxBytes = ccsds.MaxDataLength
+	}
+
+	return &Reassembler{
+		groups:      make(map[groupKey]*group),
+		timeout:     cfg.ReassemblyTimeout,
+		maxBytes:    maxBytes,
+		maxSegments: cfg.ReassemblyMaxSegments,
+	}
+}
+
+// Push adds a packet received from spacecraftID to the reassembler. Standalone packets are returned
+// unchanged. Segments are buffered and nil is returned until their group
+// is complete, at which point the joined packet is returned with its
+// sequence flags set to standalone.
+func (r *Reassembler) Push(spacecraftID uint16, data []byte, now time.Time) ([]byte, error) {
+	header, err := ccsds.ParsePrimaryHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	end := header.PacketSize()
+	if len(data) < end {
+		return nil, ErrShortPacket
+	}
+
+	if header.SeqFlags() == ccsds.SeqFlagStandalone {
+		packet := make([]byte, end)
+		copy(packet, data)
+		return packet, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.expireLocked(now)
+
+	key := groupKey{spacecraftID: spacecraftID, apid: header.APID()}
+	seq := header.SeqCount()
+	g, ok := r.groups[key]
+	if !ok {
+		g = &group{segments: make(map[uint16][]byte), started: now}
+		r.groups[key] = g
+	}
+
+	// A group whose first or last segment was lost is left behind by the
+	// next group; its segments come before the new first segment, or it
+	// already has a last one
+	switch header.SeqFlags() {
+	case ccsds.SeqFlagFirst:
+		if (g.haveFirst && g.firstSeq != seq) || (!g.haveFirst && g.precedes(seq, r.maxSegments)) {
+			r.discardLocked(key, "new first segment before group completed")
+			g = &group{segments: make(map[uint16][]byte), started: now}
+			r.groups[key] = g
+		}
+		g.header = header
+		g.firstSeq = seq
+		g.haveFirst = true
+	case ccsds.SeqFlagLast:
+		if g.haveLast && g.lastSeq != seq {
+			r.discardLocked(key, "new last segment before group completed")
+			g = &group{segments: make(map[uint16][]byte), started: now}
+			r.groups[key] = g
+		}
+		g.lastSeq = seq
+		g.haveLast = true
+	}
+
+	if _, dup := g.segments[seq]; dup {
+		log.Printf("Reassembly: duplicate segment SCID %d APID %d seq %d ignored", key.spacecraftID, key.apid, seq)
+		return nil, nil
+	}
+
+	segment := make([]byte, header.DataLength())
+	copy(segment, data[ccsds.PrimaryHeaderSize:end])
+	g.segments[seq] = segment
+	g.size += len(segment)
+
+	if g.size > r.maxBytes {
+		r.discardLocked(key, "maximum packet size exceeded")
+		return nil, nil
+	}
+	if len(g.segments) > r.maxSegments {
+		r.discardLocked(key, "maximum segment count exceeded")
+		return nil, nil
+	}
+
+	if !g.complete() {
+		return nil, nil
+	}
+
+	delete(r.groups, key)
+	return g.join(), nil
+}
+
+// Expire discards every group that has been waiting longer than the timeout
+func (r *Reassembler) Expire(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expireLocked(now)
+}
+
+// expireLocked discards timed-out groups; the caller must hold r.mu
+func (r *Reassembler) expireLocked(now time.Time) {
+	for key, g := range r.groups {
+		if now.Sub(g.started) > r.timeout {
+			r.discardLocked(key, "timed out")
+		}
+	}
+}
+
+// discardLocked drops the group for key and logs why; the caller must hold r.mu
+func (r *Reassembler) discardLocked(key groupKey, reason string) {
+	g := r.groups[key]
+	delete(r.groups, key)
+	log.Printf("Reassembly: discarded incomplete group SCID %d APID %d (%d segments, %d bytes): %s",
+		key.spacecraftID, key.apid, len(g.segments), g.size, reason)
+}
+
+// precedes reports whether a buffered segment comes before seq, so that it
+// cannot belong to a group of at most window segments starting at seq.
+// Segments that arrived early, ahead of their first segment, are kept.
+func (g *group) precedes(seq uint16, window int) bool {
+	for s := range g.segments {
+		if int((s-seq)&(ccsds.SeqCountRange-1)) >= window {
+			return true
+		}
+	}
+	return false
+}
+
+// complete reports whether every segment from first to last is present
+func (g *group) complete() bool {
+	if !g.haveFirst || !g.haveLast {
+		return false
+	}
+	count := int((g.lastSeq-g.firstSeq)&(ccsds.SeqCountRange-1)) + 1
+	if len(g.segments) != count {
+		return false
+	}
+	for i := 0; i < count; i++ {
+		if _, ok := g.segments[(g.firstSeq+uint16(i))%ccsds.SeqCountRange]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// join concatenates the segment data fields in sequence-count order
+// behind a primary header describing a single standalone packet
+func (g *group) join() []byte {
+	h := g.header
+	header := ccsds.NewPrimaryHeader(h.Type(), h.HasSecondaryHeader(), h.APID(), ccsds.SeqFlagStandalone, g.firstSeq, g.size)
+
+	packet := header.Append(make([]byte, 0, ccsds.PrimaryHeaderSize+g.size))
+	for i := 0; i < len(g.segments); i++ {
+		packet = append(packet, g.segments[(g.firstSeq+uint16(i))%ccsds.SeqCountRange]...)
+	}
+	return packet
+}
@@ -0,0 +1,186 @@
+package reassembly
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// segment encodes a telemetry packet carrying data with the given sequence
+// flags and count
+func segment(apid uint16, flags uint8, seq uint16, data string) []byte {
+	b := make([]byte, ccsds.PrimaryHeaderSize, ccsds.PrimaryHeaderSize+len(data))
+	binary.BigEndian.PutUint16(b[0:2], apid)
+	binary.BigEndian.PutUint16(b[2:4], uint16(flags)<<14|seq)
+	binary.BigEndian.PutUint16(b[4:6], uint16(len(data)-1))
+	return append(b, data...)
+}
+
+func newTestReassembler() *Reassembler {
+	return NewReassembler(&config.IngestConfig{
+		ReassemblyTimeout:     10 * time.Second,
+		ReassemblyMaxBytes:    12,
+		ReassemblyMaxSegments: 4,
+	})
+}
+
+// pushAll pushes packets in order and returns the packet completed by the last
+func pushAll(t *testing.T, r *Reassembler, now time.Time, packets ...[]byte) []byte {
+	t.Helper()
+	var packet []byte
+	for i, p := range packets {
+		var err error
+		if packet, err = r.Push(0, p, now); err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+		if packet != nil && i < len(packets)-1 {
+			t.Fatalf("push %d completed %x early", i, packet)
+		}
+	}
+	return packet
+}
+
+func TestReassemblerStandalone(t *testing.T) {
+	r := newTestReassembler()
+	want := segment(5, ccsds.SeqFlagStandalone, 9, "whole")
+
+	// Bytes after the declared length belong to no packet
+	got := pushAll(t, r, time.Now(), append(want, 0xFF, 0xFF))
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %x, want %x", got, want)
+	}
+}
+
+func TestReassemblerJoinsSegments(t *testing.T) {
+	r := newTestReassembler()
+	got := pushAll(t, r, time.Now(),
+		segment(5, ccsds.SeqFlagFirst, 1, "ab"),
+		segment(5, ccsds.SeqFlagContinuation, 2, "cd"),
+		segment(5, ccsds.SeqFlagLast, 3, "ef"))
+
+	if want := segment(5, ccsds.SeqFlagStandalone, 1, "abcdef"); !bytes.Equal(got, want) {
+		t.Fatalf("got %x, want %x", got, want)
+	}
+}
+
+func TestReassemblerOutOfOrderAcrossCountWrap(t *testing.T) {
+	r := newTestReassembler()
+	got := pushAll(t, r, time.Now(),
+		segment(5, ccsds.SeqFlagLast, 1, "ef"),
+		segment(5, ccsds.SeqFlagFirst, ccsds.SeqCountRange-1, "ab"),
+		segment(5, ccsds.SeqFlagContinuation, 0, "cd"))
+
+	if want := segment(5, ccsds.SeqFlagStandalone, ccsds.SeqCountRange-1, "abcdef"); !bytes.Equal(got, want) {
+		t.Fatalf("got %x, want %x", got, want)
+	}
+}
+
+func TestReassemblerIgnoresDuplicates(t *testing.T) {
+	r := newTestReassembler()
+	got := pushAll(t, r, time.Now(),
+		segment(5, ccsds.SeqFlagFirst, 1, "ab"),
+		segment(5, ccsds.SeqFlagFirst, 1, "ab"),
+		segment(5, ccsds.SeqFlagLast, 2, "cd"))
+
+	if want := segment(5, ccsds.SeqFlagStandalone, 1, "abcd"); !bytes.Equal(got, want) {
+		t.Fatalf("got %x, want %x", got, want)
+	}
+}
+
+func TestReassemblerKeepsAPIDsApart(t *testing.T) {
+	r := newTestReassembler()
+	got := pushAll(t, r, time.Now(),
+		segment(5, ccsds.SeqFlagFirst, 1, "ab"),
+		segment(6, ccsds.SeqFlagFirst, 7, "xy"),
+		segment(5, ccsds.SeqFlagLast, 2, "cd"))
+
+	if want := segment(5, ccsds.SeqFlagStandalone, 1, "abcd"); !bytes.Equal(got, want) {
+		t.Fatalf("got %x, want %x", got, want)
+	}
+}
+
+func TestReassemblerKeepsSpacecraftApart(t *testing.T) {
+	r := newTestReassembler()
+	now := time.Now()
+	if _, err := r.Push(1, segment(5, ccsds.SeqFlagFirst, 1, "ab"), now); err != nil {
+		t.Fatal(err)
+	}
+	packet, err := r.Push(2, segment(5, ccsds.SeqFlagLast, 2, "cd"), now)
+	if err != nil || packet != nil {
+		t.Fatalf("segments of two spacecraft were joined into %x (%v)", packet, err)
+	}
+}
+
+func TestReassemblerDiscardsIncompleteGroups(t *testing.T) {
+	start := time.Now()
+
+	// A missing continuation leaves the group waiting
+	r := newTestReassembler()
+	if got := pushAll(t, r, start, segment(5, ccsds.SeqFlagFirst, 1, "ab"), segment(5, ccsds.SeqFlagLast, 3, "ef")); got != nil {
+		t.Fatalf("incomplete group returned %x", got)
+	}
+
+	// The first segment expires before the last arrives
+	r = newTestReassembler()
+	pushAll(t, r, start, segment(5, ccsds.SeqFlagFirst, 1, "ab"))
+	if got := pushAll(t, r, start.Add(time.Minute), segment(5, ccsds.SeqFlagLast, 2, "cd")); got != nil {
+		t.Fatalf("timed-out group returned %x", got)
+	}
+
+	// Sixteen bytes exceed the twelve allowed
+	r = newTestReassembler()
+	if got := pushAll(t, r, start, segment(5, ccsds.SeqFlagFirst, 1, "abcdefgh"), segment(5, ccsds.SeqFlagLast, 2, "ijklmnop")); got != nil {
+		t.Fatalf("oversized group returned %x", got)
+	}
+
+	// Five segments exceed the four allowed
+	r = newTestReassembler()
+	got := pushAll(t, r, start,
+		segment(5, ccsds.SeqFlagFirst, 1, "a"),
+		segment(5, ccsds.SeqFlagContinuation, 2, "b"),
+		segment(5, ccsds.SeqFlagContinuation, 3, "c"),
+		segment(5, ccsds.SeqFlagContinuation, 4, "d"),
+		segment(5, ccsds.SeqFlagLast, 5, "e"))
+	if got != nil {
+		t.Fatalf("group over the segment limit returned %x", got)
+	}
+}
+
+func TestReassemblerRecoversFromLostEnds(t *testing.T) {
+	start := time.Now()
+
+	// The first segment of one group is lost; the next group still joins
+	r := newTestReassembler()
+	got := pushAll(t, r, start,
+		segment(5, ccsds.SeqFlagContinuation, 2, "cd"),
+		segment(5, ccsds.SeqFlagLast, 3, "ef"),
+		segment(5, ccsds.SeqFlagFirst, 4, "gh"),
+		segment(5, ccsds.SeqFlagLast, 5, "ij"))
+	if want := segment(5, ccsds.SeqFlagStandalone, 4, "ghij"); !bytes.Equal(got, want) {
+		t.Fatalf("after a lost first segment got %x, want %x", got, want)
+	}
+
+	// Both the first of one group and the first of the next are lost, so a
+	// second last segment starts over
+	r = newTestReassembler()
+	pushAll(t, r, start, segment(5, ccsds.SeqFlagLast, 3, "ef"), segment(5, ccsds.SeqFlagLast, 6, "kl"))
+	got = pushAll(t, r, start, segment(5, ccsds.SeqFlagFirst, 5, "ij"))
+	if want := segment(5, ccsds.SeqFlagStandalone, 5, "ijkl"); !bytes.Equal(got, want) {
+		t.Fatalf("after a lost last segment got %x, want %x", got, want)
+	}
+}
+
+func TestReassemblerShortPackets(t *testing.T) {
+	r := newTestReassembler()
+	if _, err := r.Push(0, segment(5, ccsds.SeqFlagStandalone, 1, "abcdef")[:8], time.Now()); !errors.Is(err, ErrShortPacket) {
+		t.Errorf("truncated data field: error %v, want %v", err, ErrShortPacket)
+	}
+	if _, err := r.Push(0, []byte{0x08, 0x05}, time.Now()); err == nil {
+		t.Error("truncated primary header: expected an error")
+	}
+}
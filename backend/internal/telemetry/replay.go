@@ -0,0 +1,253 @@
+package telemetry
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/repository"
+)
+
+var (
+	// ErrLiveOverwrite is returned when a replay targets the live dataset without asking to overwrite it
+	ErrLiveOverwrite = errors.New("replaying into the live dataset requires overwrite")
+	// ErrReplayRunning is returned when a replay into the same dataset has not finished
+	ErrReplayRunning = errors.New("a replay into this dataset is already running")
+)
+
+// ReplayRequest selects archived datagrams and where their telemetry is written
+type ReplayRequest struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Dataset   string    `json:"dataset"`   // Target dataset; defaults to live
+	Overwrite bool      `json:"overwrite"` // Must be set to replace live telemetry
+}
+
+// ReplayResult summarises a completed replay
+type ReplayResult struct {
+	Dataset   string `json:"dataset"`
+	Datagrams int    `json:"datagrams"`
+	Packets   int    `json:"packets"`
+	Stored    int    `json:"stored"`
+	Failed    int    `json:"failed"`
+	Replaced  int64  `json:"replaced"`
+}
+
+// Replay job states
+const (
+	ReplayRunning = "running"
+	ReplayDone    = "done"
+	ReplayFailed  = "failed"
+)
+
+// maxReplayJobs is how many replay jobs are remembered, including finished ones
+const maxReplayJobs = 100
+
+// ReplayJob describes a replay running in the background
+type ReplayJob struct {
+	ID         string        `json:"id"`
+	Request    ReplayRequest `json:"request"`
+	Status     string        `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	Result     *ReplayResult `json:"result,omitempty"` // Set once the replay has finished
+}
+
+// Replayer pushes archived datagrams back through the processing pipeline
+type Replayer struct {
+	repo *repository.TelemetryRepository
+	cfg  *config.IngestConfig
+
+	mu      sync.Mutex
+	jobs    map[string]*ReplayJob
+	order   []string        // Job IDs, oldest first
+	running map[string]bool // Datasets with a replay in progress
+	nextID  int
+}
+
+// NewReplayer creates a replayer that decodes with the current ingest settings
+func NewReplayer(repo *repository.TelemetryRepository, cfg *config.IngestConfig) *Replayer {
+	return &Replayer{
+		repo:    repo,
+		cfg:     cfg,
+		jobs:    make(map[string]*ReplayJob),
+		running: make(map[string]bool),
+	}
+}
+
+// Start validates a replay request and runs it in the background. Only one
+// replay may write to a dataset at a time.
+func (r *Replayer) Start(req ReplayRequest) (ReplayJob, error) {
+	if req.Dataset == "" {
+		req.Dataset = models.DatasetLive
+	}
+	if req.Dataset == models.DatasetLive && !req.Overwrite {
+		return ReplayJob{}, ErrLiveOverwrite
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running[req.Dataset] {
+		return ReplayJob{}, ErrReplayRunning
+	}
+	r.running[req.Dataset] = true
+
+	r.nextID++
+	job := &ReplayJob{
+		ID:        strconv.Itoa(r.nextID),
+		Request:   req,
+		Status:    ReplayRunning,
+		StartedAt: time.Now(),
+	}
+	r.jobs[job.ID] = job
+	r.order = append(r.order, job.ID)
+	r.forgetOldJobs()
+
+	go r.run(job.ID, req)
+	return *job, nil
+}
+
+// Job returns the current state of a replay job
+func (r *Replayer) Job(id string) (ReplayJob, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return ReplayJob{}, false
+	}
+	return *job, true
+}
+
+// run performs a replay and records its outcome on the job
+func (r *Replayer) run(id string, req ReplayRequest) {
+	result, err := r.Replay(req)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.running, req.Dataset)
+	job, ok := r.jobs[id]
+	if !ok {
+		return
+	}
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.Result = &result
+	job.Status = ReplayDone
+	if err != nil {
+		job.Status = ReplayFailed
+		job.Error = err.Error()
+	}
+}
+
+// forgetOldJobs drops the oldest finished jobs beyond maxReplayJobs
+func (r *Replayer) forgetOldJobs() {
+	kept := r.order[:0]
+	excess := len(r.order) - maxReplayJobs
+	for _, id := range r.order {
+		if excess > 0 && r.jobs[id].Status != ReplayRunning {
+			delete(r.jobs, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	r.order = kept
+}
+
+// Replay reprocesses every datagram received within the requested range.
+// Telemetry already stored in the target dataset for that range is replaced
+// in one transaction, so a failed replay leaves the dataset unchanged and
+// running the same replay twice gives the same result.
+func (r *Replayer) Replay(req ReplayRequest) (ReplayResult, error) {
+	dataset := req.Dataset
+	if dataset == "" {
+		dataset = models.DatasetLive
+	}
+	if dataset == models.DatasetLive && !req.Overwrite {
+		return ReplayResult{}, ErrLiveOverwrite
+	}
+
+	result := ReplayResult{Dataset: dataset}
+
+	// Span of the telemetry being replaced, so the rollups can be refreshed
+	first, last, replacing, err := r.repo.TimestampRangeReceived(dataset, req.StartTime, req.EndTime)
+	if err != nil {
+		return result, err
+	}
+
+	err = r.repo.Transaction(func(tx *repository.TelemetryRepository) error {
+		replaced, err := tx.DeleteTelemetryReceived(dataset, req.StartTime, req.EndTime)
+		if err != nil {
+			return err
+		}
+		result.Replaced = replaced
+
+		// Datagrams are decoded as the listener that received them decoded them
+		type sourceKey struct {
+			mode         string
+			spacecraftID uint16
+			stream       string
+		}
+		pipelines := make(map[sourceKey]*Pipeline)
+
+		// The archive is read outside the transaction, whose connection is
+		// busy with the inserts
+		return r.repo.EachRawPacket(req.StartTime, req.EndTime, func(raw models.RawPacket) error {
+			key := sourceKey{raw.Mode, raw.SpacecraftID, raw.Stream}
+			p, ok := pipelines[key]
+			if !ok {
+				source := config.ListenerConfig{Mode: raw.Mode, SpacecraftID: raw.SpacecraftID, Stream: raw.Stream}
+				p = NewReplayPipeline(tx, r.cfg, source, dataset)
+				pipelines[key] = p
+			}
+
+			result.Datagrams++
+			for _, packet := range p.Extract(raw.Data, raw.ReceivedAt) {
+				result.Packets++
+				err := p.Handle(packet)
+				if errors.Is(err, ErrStoreFailed) {
+					// The transaction is aborted, so nothing more can be stored
+					return err
+				}
+				if err != nil {
+					result.Failed++
+				} else {
+					result.Stored++
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Replay into %q failed, dataset left unchanged: %v", dataset, err)
+		return result, err
+	}
+
+	// Refresh policies only revisit recent buckets, so rollups covering older
+	// telemetry must be refreshed over both the old and new spans
+	if newFirst, newLast, ok, spanErr := r.repo.TimestampRangeReceived(dataset, req.StartTime, req.EndTime); spanErr != nil {
+		log.Printf("Failed to find replayed telemetry span: %v", spanErr)
+	} else if ok || replacing {
+		if !replacing || (ok && newFirst.Before(first)) {
+			first = newFirst
+		}
+		if !replacing || (ok && newLast.After(last)) {
+			last = newLast
+		}
+		if err := r.repo.RefreshRollups(first, last); err != nil {
+			log.Printf("Failed to refresh telemetry rollups after replay: %v", err)
+		}
+	}
+
+	log.Printf("Replay into %q: %d datagrams, %d packets, %d stored, %d failed, %d replaced",
+		dataset, result.Datagrams, result.Packets, result.Stored, result.Failed, result.Replaced)
+	return result, nil
+}
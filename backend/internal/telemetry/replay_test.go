@@ -0,0 +1,152 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/repository"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/timecode"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+var replayTestConfig = &config.IngestConfig{
+	TimeCode:             timecode.FormatUnix,
+	ClockWindow:          20,
+	ClockFutureTolerance: 5 * time.Second,
+	ClockJumpThreshold:   time.Second,
+}
+
+// testRepository connects to the database named by TEST_DATABASE_DSN, given
+// in key=value form, skipping the test when none is configured
+func testRepository(t *testing.T) *repository.TelemetryRepository {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	cfg := &config.DatabaseConfig{Port: "5432", SSLMode: "disable"}
+	for _, field := range strings.Fields(dsn) {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "host":
+			cfg.Host = value
+		case "port":
+			cfg.Port = value
+		case "user":
+			cfg.User = value
+		case "password":
+			cfg.Password = value
+		case "dbname":
+			cfg.Name = value
+		case "sslmode":
+			cfg.SSLMode = value
+		}
+	}
+	return repository.NewTelemetryRepository(cfg, nil)
+}
+
+// rawTelemetry encodes a standalone telemetry packet stamped with onboard time
+func rawTelemetry(seq uint16, onboard time.Time) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, ccsds.PrimaryHeader{
+		PacketID:      0x0801,
+		PacketSeqCtrl: 0xC000 | seq,
+		PacketLength:  uint16(binary.Size(ccsds.SecondaryHeader{}) + binary.Size(ccsds.TelemetryPayload{}) - 1),
+	})
+	binary.Write(&buf, binary.BigEndian, ccsds.SecondaryHeader{Timestamp: uint64(onboard.Unix()), SubsystemID: 1})
+	binary.Write(&buf, binary.BigEndian, ccsds.TelemetryPayload{Temperature: 25, Battery: 80, Altitude: 520, Signal: -50})
+	return buf.Bytes()
+}
+
+func TestReplayRequiresOverwriteForLive(t *testing.T) {
+	// The request is refused before the repository is touched
+	r := NewReplayer(nil, replayTestConfig)
+	for _, dataset := range []string{"", models.DatasetLive} {
+		if _, err := r.Replay(ReplayRequest{Dataset: dataset}); !errors.Is(err, ErrLiveOverwrite) {
+			t.Errorf("dataset %q: error %v, want %v", dataset, err, ErrLiveOverwrite)
+		}
+	}
+}
+
+func TestReplayStartOnePerDataset(t *testing.T) {
+	r := NewReplayer(nil, replayTestConfig)
+	if _, err := r.Start(ReplayRequest{}); !errors.Is(err, ErrLiveOverwrite) {
+		t.Errorf("live replay without overwrite: error %v, want %v", err, ErrLiveOverwrite)
+	}
+
+	// A dataset being written by one replay cannot be the target of another
+	r.running["rerun"] = true
+	if _, err := r.Start(ReplayRequest{Dataset: "rerun"}); !errors.Is(err, ErrReplayRunning) {
+		t.Errorf("second replay into a dataset: error %v, want %v", err, ErrReplayRunning)
+	}
+	if len(r.jobs) != 0 {
+		t.Errorf("%d jobs started for refused requests", len(r.jobs))
+	}
+}
+
+func TestReplayReplacesDataset(t *testing.T) {
+	repo := testRepository(t)
+
+	// Archive a few datagrams a second apart, one of them undecodable
+	start := time.Now().Truncate(time.Second).Add(-time.Hour)
+	for i := 0; i < 4; i++ {
+		data := rawTelemetry(uint16(i), start.Add(time.Duration(i)*time.Second))
+		if i == 2 {
+			data = data[:10]
+		}
+		raw := models.RawPacket{ReceivedAt: start.Add(time.Duration(i) * time.Second), Source: "test", Mode: config.IngestModePacket, Data: data}
+		if err := repo.InsertRawPacket(raw); err != nil {
+			t.Fatalf("archive datagram %d: %v", i, err)
+		}
+	}
+
+	dataset := fmt.Sprintf("replay-test-%d", time.Now().UnixNano())
+	req := ReplayRequest{StartTime: start, EndTime: start.Add(3 * time.Second), Dataset: dataset}
+	t.Cleanup(func() { repo.DeleteTelemetryReceived(dataset, req.StartTime, req.EndTime) })
+
+	r := NewReplayer(repo, replayTestConfig)
+	first, err := r.Replay(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Datagrams != 4 || first.Stored != 3 || first.Replaced != 0 {
+		t.Fatalf("first replay %+v, want 4 datagrams, 3 stored and none replaced", first)
+	}
+
+	// Replaying again replaces what the first replay stored
+	second, err := r.Replay(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Stored != 3 || second.Replaced != 3 {
+		t.Fatalf("second replay %+v, want 3 stored and 3 replaced", second)
+	}
+
+	stored, err := repo.WithDataset(dataset).GetTelemetry(req.StartTime, req.EndTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 3 {
+		t.Fatalf("%d records in %q, want 3", len(stored), dataset)
+	}
+
+	// Live telemetry is untouched by a replay into another dataset
+	live, err := repo.GetTelemetry(req.StartTime, req.EndTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, telemetry := range live {
+		if telemetry.Dataset != models.DatasetLive {
+			t.Errorf("live query returned telemetry from %q", telemetry.Dataset)
+		}
+	}
+}
@@ -2,69 +2,100 @@ package telemetry
 
 import (
 	"log"
-	"net"
+	"sync"
+	"time"
 
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/commanding"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
 	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/repository"
-	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/processor"
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/telemetry/clock"
 )
 
-// TelemetryServer represents the UDP server for receiving telemetry data
+// TelemetryServer receives telemetry on every configured listener
 type TelemetryServer struct {
-	repo      *repository.TelemetryRepository
-	port      string
-	processor *processor.TelemetryProcessor
+	repo       *repository.TelemetryRepository
+	cfg        *config.IngestConfig
+	correlator *clock.Correlator
+	verifier   *commanding.Verifier
+	listeners  []*listener
+	archiver   *archiver
 }
 
 // NewTelemetryServer creates a new telemetry server instance
-func NewTelemetryServer(repo *repository.TelemetryRepository, port string) *TelemetryServer {
-	return &TelemetryServer{
-		repo:      repo,
-		port:      port,
-		processor: processor.NewTelemetryProcessor(),
+func NewTelemetryServer(repo *repository.TelemetryRepository, correlator *clock.Correlator, verifier *commanding.Verifier, cfg *config.IngestConfig) *TelemetryServer {
+	s := &TelemetryServer{
+		repo:       repo,
+		cfg:        cfg,
+		correlator: correlator,
+		verifier:   verifier,
+		archiver:   newArchiver(repo, cfg.ArchiveQueueSize, cfg.ArchiveBatchSize),
 	}
+
+	for _, lc := range cfg.Listeners {
+		s.listeners = append(s.listeners, newListener(s, lc))
+	}
+	return s
+}
+
+// newPipeline creates a live pipeline for telemetry arriving on source
+func (s *TelemetryServer) newPipeline(source config.ListenerConfig) *Pipeline {
+	return NewPipeline(s.repo, s.correlator, s.verifier, s.cfg, source)
 }
 
-// Start initializes and starts the UDP server
+// Start starts every listener and blocks while they run
 func (s *TelemetryServer) Start() {
-	addr, err := net.ResolveUDPAddr("udp", ":"+s.port)
-	if err != nil {
-		log.Fatal("UDP address resolution error:", err)
+	if len(s.listeners) == 0 {
+		log.Println("No ingest listeners configured")
+		return
 	}
 
-	conn, err := net.ListenUDP("udp", addr)
-	if err != nil {
-		log.Fatal("UDP listen error:", err)
+	if s.cfg.ArchiveRaw {
+		go s.archiver.run()
 	}
-	defer conn.Close()
-
-	log.Printf("UDP server listening on port %s...", s.port)
 
-	buffer := make([]byte, 1024) // Buffer for incoming packets
+	// Drop segment groups that never complete, even if no further packets arrive
+	go s.expire()
 
-	// Continuously listen for packets
-	for {
-		n, _, err := conn.ReadFromUDP(buffer)
-		if err != nil {
-			log.Println("Error receiving UDP packet:", err)
-			continue
-		}
+	var wg sync.WaitGroup
+	for _, l := range s.listeners {
+		wg.Add(1)
+		go func(l *listener) {
+			defer wg.Done()
+			l.serve()
+		}(l)
+	}
+	wg.Wait()
+}
 
-		// Process packet in a goroutine
-		go s.handlePacket(buffer[:n])
+// Stats returns the statistics of every listener
+func (s *TelemetryServer) Stats() []ListenerStats {
+	stats := make([]ListenerStats, 0, len(s.listeners))
+	for _, l := range s.listeners {
+		stats = append(stats, l.snapshot())
 	}
+	return stats
 }
 
-// handlePacket processes an incoming UDP packet
-func (s *TelemetryServer) handlePacket(data []byte) {
-	// Process the packet using the telemetry processor
-	telemetry, err := s.processor.ProcessPacket(data)
-	if err != nil {
-		log.Printf("Error processing telemetry packet: %v", err)
-		return
+// archive queues a raw datagram for the packet archive, returning false if
+// it had to be dropped
+func (s *TelemetryServer) archive(raw models.RawPacket) bool {
+	return s.archiver.Enqueue(raw)
+}
+
+// expire periodically discards timed-out segment groups and idle rate limit state
+func (s *TelemetryServer) expire() {
+	interval := s.cfg.ReassemblyTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
 	}
 
-	// Store the telemetry data
-	if err := s.repo.InsertTelemetry(telemetry); err != nil {
-		log.Printf("Failed to insert telemetry: %v", err)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for _, l := range s.listeners {
+			l.expire(now)
+		}
 	}
 }
@@ -0,0 +1,193 @@
+package timecode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+)
+
+// Supported time code formats
+const (
+	FormatUnix = "unix" // 64-bit whole seconds since the Unix epoch
+	FormatCUC  = "cuc"  // CCSDS Unsegmented time Code (coarse and fine seconds)
+	FormatCDS  = "cds"  // CCSDS Day Segmented time code (days, milliseconds, sub-milliseconds)
+)
+
+// Time scales the elapsed time of a code can be counted in
+const (
+	ScaleUTC = "utc" // Elapsed seconds exclude leap seconds
+	ScaleTAI = "tai" // Elapsed seconds include leap seconds
+)
+
+// CCSDSEpoch is the recommended CCSDS level 1 epoch, 1958 January 1
+var CCSDSEpoch = time.Date(1958, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// ErrShortTimeCode is returned when fewer bytes are supplied than the code needs
+var ErrShortTimeCode = errors.New("time code shorter than configured length")
+
+// ErrTimeCodeRange is returned when a code counts more time since the epoch
+// than a time.Duration can hold, about 292 years
+var ErrTimeCodeRange = errors.New("time code beyond the representable range")
+
+const (
+	nanosPerMilli = int64(time.Millisecond)
+	millisPerDay  = 86400000
+
+	// Largest coarse seconds and days that leave room for the finer fields
+	maxCUCSeconds = math.MaxInt64/int64(time.Second) - 1
+	maxCDSDays    = (math.MaxInt64 - (1<<32)*nanosPerMilli - (1<<16)*int64(time.Microsecond)) / int64(24*time.Hour)
+)
+
+// Decoder converts onboard time codes to UTC timestamps
+type Decoder struct {
+	format      string
+	epoch       time.Time
+	scale       string
+	coarseBytes int
+	fineBytes   int
+	dayBytes    int
+	subMsBytes  int
+}
+
+// NewDecoder creates a decoder for the time code described by cfg
+func NewDecoder(cfg *config.IngestConfig) (*Decoder, error) {
+	d := &Decoder{
+		format:      cfg.TimeCode,
+		epoch:       cfg.TimeEpoch,
+		scale:       cfg.TimeScale,
+		coarseBytes: cfg.CUCCoarseOctets,
+		fineBytes:   cfg.CUCFineOctets,
+		dayBytes:    cfg.CDSDayOctets,
+		subMsBytes:  cfg.CDSSubMsOctets,
+	}
+
+	switch d.format {
+	case FormatUnix:
+		d.epoch = time.Unix(0, 0).UTC()
+		d.scale = ScaleUTC
+	case FormatCUC:
+		if d.coarseBytes < 1 || d.coarseBytes > 7 {
+			return nil, fmt.Errorf("CUC coarse time must be 1-7 octets, got %d", d.coarseBytes)
+		}
+		if d.fineBytes < 0 || d.fineBytes > 3 {
+			return nil, fmt.Errorf("CUC fine time must be 0-3 octets, got %d", d.fineBytes)
+		}
+		if d.scale == "" {
+			d.scale = ScaleTAI
+		}
+	case FormatCDS:
+		if d.dayBytes != 2 && d.dayBytes != 3 {
+			return nil, fmt.Errorf("CDS day segment must be 2 or 3 octets, got %d", d.dayBytes)
+		}
+		if d.subMsBytes != 0 && d.subMsBytes != 2 && d.subMsBytes != 4 {
+			return nil, fmt.Errorf("CDS sub-millisecond segment must be 0, 2 or 4 octets, got %d", d.subMsBytes)
+		}
+		if d.scale == "" {
+			d.scale = ScaleUTC
+		}
+	default:
+		return nil, fmt.Errorf("unknown time code format %q", d.format)
+	}
+
+	if d.epoch.IsZero() {
+		d.epoch = CCSDSEpoch
+	}
+	if d.scale != ScaleUTC && d.scale != ScaleTAI {
+		return nil, fmt.Errorf("unknown time scale %q", d.scale)
+	}
+
+	return d, nil
+}
+
+// Size returns the number of bytes the time code occupies
+func (d *Decoder) Size() int {
+	switch d.format {
+	case FormatCUC:
+		return d.coarseBytes + d.fineBytes
+	case FormatCDS:
+		return d.dayBytes + 4 + d.subMsBytes
+	default:
+		return 8
+	}
+}
+
+// Decode converts the time code at the start of b to a UTC timestamp
+func (d *Decoder) Decode(b []byte) (time.Time, error) {
+	if len(b) < d.Size() {
+		return time.Time{}, ErrShortTimeCode
+	}
+
+	switch d.format {
+	case FormatCUC:
+		return d.decodeCUC(b)
+	case FormatCDS:
+		return d.decodeCDS(b)
+	default:
+		return time.Unix(int64(readUint(b[:8])), 0), nil
+	}
+}
+
+// decodeCUC converts coarse seconds plus a binary fraction of a second
+func (d *Decoder) decodeCUC(b []byte) (time.Time, error) {
+	coarse := readUint(b[:d.coarseBytes])
+	if coarse > uint64(maxCUCSeconds) {
+		return time.Time{}, ErrTimeCodeRange
+	}
+	fine := readUint(b[d.coarseBytes : d.coarseBytes+d.fineBytes])
+
+	// fine / 256^fineBytes seconds, scaled to nanoseconds without overflow
+	nanos := int64(fine * uint64(time.Second) >> (8 * uint(d.fineBytes)))
+
+	return d.fromElapsed(time.Duration(coarse)*time.Second + time.Duration(nanos)), nil
+}
+
+// decodeCDS converts days, milliseconds of day and an optional sub-millisecond field
+func (d *Decoder) decodeCDS(b []byte) (time.Time, error) {
+	days := int64(readUint(b[:d.dayBytes]))
+	if days > maxCDSDays {
+		return time.Time{}, ErrTimeCodeRange
+	}
+	ms := int64(readUint(b[d.dayBytes : d.dayBytes+4]))
+
+	var subNanos int64
+	subMs := b[d.dayBytes+4 : d.dayBytes+4+d.subMsBytes]
+	switch d.subMsBytes {
+	case 2:
+		subNanos = int64(readUint(subMs)) * int64(time.Microsecond) // microseconds
+	case 4:
+		subNanos = int64(readUint(subMs)) / 1000 // picoseconds
+	}
+
+	// A UTC day containing a positive leap second has 86 401 000 ms. Go
+	// cannot represent 23:59:60, so hold the clock at the end of the day.
+	if d.scale == ScaleUTC && ms >= millisPerDay {
+		day := d.epoch.AddDate(0, 0, int(days))
+		return day.Add(24*time.Hour - time.Nanosecond), nil
+	}
+
+	elapsed := time.Duration(days)*24*time.Hour +
+		time.Duration(ms*nanosPerMilli+subNanos)
+	return d.fromElapsed(elapsed), nil
+}
+
+// fromElapsed adds elapsed time to the epoch, removing leap seconds
+// accumulated since the epoch when counting in TAI
+func (d *Decoder) fromElapsed(elapsed time.Duration) time.Time {
+	t := d.epoch.Add(elapsed)
+	if d.scale == ScaleTAI {
+		t = t.Add(-time.Duration(leapSecondsBetween(d.epoch, t)) * time.Second)
+	}
+	return t.UTC()
+}
+
+// readUint decodes a big-endian unsigned integer of up to eight bytes
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
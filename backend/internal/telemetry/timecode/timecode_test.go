@@ -0,0 +1,159 @@
+package timecode
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/config"
+)
+
+// code concatenates big-endian fields given as value and width pairs
+func code(fields ...uint64) []byte {
+	var b []byte
+	for i := 0; i < len(fields); i += 2 {
+		v, n := fields[i], int(fields[i+1])
+		for j := n - 1; j >= 0; j-- {
+			b = append(b, byte(v>>(8*uint(j))))
+		}
+	}
+	return b
+}
+
+func TestOffsetAtTAI(t *testing.T) {
+	checks := []struct {
+		tai  time.Time
+		want int
+	}{
+		{date(1965, 6, 1), 0},
+		{date(1972, 1, 1).Add(9 * time.Second), 0},
+		{date(1972, 1, 1).Add(10 * time.Second), 10},
+		{date(2017, 1, 1).Add(36 * time.Second), 36},
+		{date(2017, 1, 1).Add(37 * time.Second), 37},
+		{date(2026, 1, 1), 37},
+	}
+	for _, c := range checks {
+		if got := offsetAtTAI(c.tai); got != c.want {
+			t.Errorf("TAI-UTC at %s TAI is %d, want %d", c.tai.Format(time.RFC3339), got, c.want)
+		}
+	}
+}
+
+func TestLeapSecondsBetween(t *testing.T) {
+	gps := date(1980, 1, 6).Add(19 * time.Second) // The GPS epoch on the TAI scale
+	if got := leapSecondsBetween(gps, date(2020, 1, 1)); got != 18 {
+		t.Errorf("GPS-UTC %d, want 18", got)
+	}
+	if got := leapSecondsBetween(CCSDSEpoch, date(2020, 1, 1)); got != 37 {
+		t.Errorf("TAI-UTC since 1958 %d, want 37", got)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	when := time.Date(2020, 3, 14, 15, 9, 26, 500*int(time.Millisecond), time.UTC)
+	sinceCCSDS := uint64(when.Unix() - CCSDSEpoch.Unix())
+	gpsEpoch := date(1980, 1, 6)
+	days := uint64(when.Sub(CCSDSEpoch) / (24 * time.Hour))
+	msOfDay := uint64(when.Sub(CCSDSEpoch)%(24*time.Hour)) / uint64(time.Millisecond)
+	leapDay := uint64(date(2016, 12, 31).Sub(CCSDSEpoch) / (24 * time.Hour))
+
+	tests := []struct {
+		name string
+		cfg  config.IngestConfig
+		code []byte
+		want time.Time
+	}{
+		{"unix seconds", config.IngestConfig{TimeCode: FormatUnix},
+			code(uint64(when.Unix()), 8), when.Truncate(time.Second)},
+		{"CUC in TAI from 1958", config.IngestConfig{TimeCode: FormatCUC, CUCCoarseOctets: 4, CUCFineOctets: 2},
+			code(sinceCCSDS+37, 4, 0x8000, 2), when},
+		{"CUC in UTC", config.IngestConfig{TimeCode: FormatCUC, TimeScale: ScaleUTC, CUCCoarseOctets: 4},
+			code(sinceCCSDS, 4), when.Truncate(time.Second)},
+		{"CUC from the GPS epoch", config.IngestConfig{TimeCode: FormatCUC, TimeEpoch: gpsEpoch, CUCCoarseOctets: 4, CUCFineOctets: 1},
+			code(uint64(when.Unix()-gpsEpoch.Unix())+18, 4, 0x80, 1), when},
+		{"CDS with microseconds", config.IngestConfig{TimeCode: FormatCDS, CDSDayOctets: 2, CDSSubMsOctets: 2},
+			code(days, 2, msOfDay, 4, 250, 2), when.Add(250 * time.Microsecond)},
+		{"CDS with picoseconds", config.IngestConfig{TimeCode: FormatCDS, CDSDayOctets: 3, CDSSubMsOctets: 4},
+			code(days, 3, msOfDay, 4, 1500000, 4), when.Add(1500 * time.Nanosecond)},
+		{"CDS during a leap second", config.IngestConfig{TimeCode: FormatCDS, CDSDayOctets: 2},
+			code(leapDay, 2, 86400500, 4), date(2017, 1, 1).Add(-time.Nanosecond)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDecoder(&tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tt.code) != d.Size() {
+				t.Fatalf("code of %d bytes, decoder expects %d", len(tt.code), d.Size())
+			}
+			got, err := d.Decode(tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("decoded %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeShortCode(t *testing.T) {
+	d, err := NewDecoder(&config.IngestConfig{TimeCode: FormatCUC, CUCCoarseOctets: 4, CUCFineOctets: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Decode(code(1, 5)); !errors.Is(err, ErrShortTimeCode) {
+		t.Fatalf("error %v, want %v", err, ErrShortTimeCode)
+	}
+}
+
+func TestDecodeOutOfRange(t *testing.T) {
+	cuc := config.IngestConfig{TimeCode: FormatCUC, CUCCoarseOctets: 5, CUCFineOctets: 1}
+	cds := config.IngestConfig{TimeCode: FormatCDS, CDSDayOctets: 3, CDSSubMsOctets: 4}
+
+	for _, tt := range []struct {
+		name string
+		cfg  config.IngestConfig
+		code []byte
+		err  error
+	}{
+		{"CUC at the limit", cuc, code(uint64(maxCUCSeconds), 5, 0xFF, 1), nil},
+		{"CUC past the limit", cuc, code(uint64(maxCUCSeconds+1), 5, 0, 1), ErrTimeCodeRange},
+		{"CUC with every coarse bit set", cuc, code(1<<40-1, 5, 0, 1), ErrTimeCodeRange},
+		{"CDS at the limit", cds, code(uint64(maxCDSDays), 3, 1<<32-1, 4, 1<<32-1, 4), nil},
+		{"CDS past the limit", cds, code(uint64(maxCDSDays+1), 3, 0, 4, 0, 4), ErrTimeCodeRange},
+		{"CDS with every day bit set", cds, code(1<<24-1, 3, 0, 4, 0, 4), ErrTimeCodeRange},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDecoder(&tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := d.Decode(tt.code)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+			// A code in range lies after its epoch rather than wrapping before it
+			if err == nil && !got.After(CCSDSEpoch) {
+				t.Fatalf("decoded %s, before the epoch", got)
+			}
+		})
+	}
+}
+
+func TestNewDecoderRejectsBadConfig(t *testing.T) {
+	for _, cfg := range []config.IngestConfig{
+		{TimeCode: "gps-week"},
+		{TimeCode: FormatCUC},
+		{TimeCode: FormatCUC, CUCCoarseOctets: 4, CUCFineOctets: 4},
+		{TimeCode: FormatCDS, CDSDayOctets: 1},
+		{TimeCode: FormatCDS, CDSDayOctets: 2, CDSSubMsOctets: 3},
+		{TimeCode: FormatCUC, CUCCoarseOctets: 4, TimeScale: "gps"},
+	} {
+		if _, err := NewDecoder(&cfg); err == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+}
@@ -10,10 +10,23 @@ import (
 	"github.com/mtthew-teng/Turion-GSW-Take-Home/backend/internal/models"
 )
 
+// Topics that clients subscribe to by endpoint
+const (
+	TopicTelemetry = "telemetry" // New telemetry records
+	TopicCommands  = "commands"  // Command verification updates
+)
+
 // Client represents a connected WebSocket client
 type Client struct {
-	Conn *websocket.Conn
-	Mu   sync.Mutex
+	Conn  *websocket.Conn
+	Mu    sync.Mutex
+	Topic string
+}
+
+// message is a broadcast for the clients of one topic
+type message struct {
+	topic string
+	data  []byte
 }
 
 // WebSocketServer manages WebSocket connections and broadcasts
@@ -22,7 +35,7 @@ type WebSocketServer struct {
 	clientsMutex sync.RWMutex
 	register     chan *Client
 	unregister   chan *Client
-	broadcast    chan []byte
+	broadcast    chan message
 }
 
 // NewWebSocketServer creates a new WebSocket server
@@ -31,7 +44,7 @@ func NewWebSocketServer() *WebSocketServer {
 		clients:    make(map[*Client]bool),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
-		broadcast:  make(chan []byte),
+		broadcast:  make(chan message),
 	}
 }
 
@@ -59,20 +72,24 @@ func (s *WebSocketServer) run() {
 			s.clientsMutex.Unlock()
 			log.Println("Client disconnected from WebSocket")
 
-		case message := <-s.broadcast:
-			s.broadcastToClients(message)
+		case msg := <-s.broadcast:
+			s.broadcastToClients(msg)
 		}
 	}
 }
 
-// broadcastToClients sends a message to all connected clients
-func (s *WebSocketServer) broadcastToClients(message []byte) {
-	s.clientsMutex.RLock()
-	defer s.clientsMutex.RUnlock()
+// broadcastToClients sends a message to all clients subscribed to its topic
+func (s *WebSocketServer) broadcastToClients(msg message) {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
 
 	for client := range s.clients {
+		if client.Topic != msg.topic {
+			continue
+		}
+
 		client.Mu.Lock()
-		err := client.Conn.WriteMessage(websocket.TextMessage, message)
+		err := client.Conn.WriteMessage(websocket.TextMessage, msg.data)
 		client.Mu.Unlock()
 
 		if err != nil {
@@ -93,9 +110,15 @@ func (s *WebSocketServer) HandleWebSocket(app *fiber.App) {
 		return c.SendStatus(fiber.StatusUpgradeRequired)
 	})
 
-	// WebSocket endpoint
-	app.Get("/ws/telemetry", websocket.New(func(conn *websocket.Conn) {
-		client := &Client{Conn: conn}
+	// WebSocket endpoints, one per topic
+	app.Get("/ws/telemetry", websocket.New(s.serveTopic(TopicTelemetry)))
+	app.Get("/ws/commands", websocket.New(s.serveTopic(TopicCommands)))
+}
+
+// serveTopic returns a connection handler that subscribes clients to topic
+func (s *WebSocketServer) serveTopic(topic string) func(*websocket.Conn) {
+	return func(conn *websocket.Conn) {
+		client := &Client{Conn: conn, Topic: topic}
 
 		// Register the client
 		s.register <- client
@@ -112,10 +135,10 @@ func (s *WebSocketServer) HandleWebSocket(app *fiber.App) {
 				break
 			}
 		}
-	}))
+	}
 }
 
-// BroadcastTelemetry sends new telemetry data to all connected clients
+// BroadcastTelemetry sends new telemetry data to telemetry clients
 func (s *WebSocketServer) BroadcastTelemetry(telemetry models.Telemetry) {
 	data, err := json.Marshal(telemetry)
 	if err != nil {
@@ -123,5 +146,16 @@ func (s *WebSocketServer) BroadcastTelemetry(telemetry models.Telemetry) {
 		return
 	}
 
-	s.broadcast <- data
+	s.broadcast <- message{topic: TopicTelemetry, data: data}
+}
+
+// BroadcastCommand sends a command status update to command clients
+func (s *WebSocketServer) BroadcastCommand(update interface{}) {
+	data, err := json.Marshal(update)
+	if err != nil {
+		log.Printf("Error marshaling command update: %v", err)
+		return
+	}
+
+	s.broadcast <- message{topic: TopicCommands, data: data}
 }
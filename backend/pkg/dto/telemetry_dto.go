@@ -1,19 +1,56 @@
 package dto
 
-// AggregatedTelemetry stores min, max, and average values of telemetry data
+import "time"
+
+// ParameterStats maps each requested statistic of one parameter, such as
+// "min" or "p95", to its value. Values are null when the range has no data.
+type ParameterStats map[string]*float64
+
+// AnomalyStats counts the anomalous samples in a range
+type AnomalyStats struct {
+	Count   int64   `json:"count"`
+	Percent float64 `json:"percent"` // Share of all samples in the range
+}
+
+// AggregatedTelemetry stores the requested statistics of the telemetry in a
+// range, with per-parameter statistics nested under the parameter name.
+// Range-wide values are omitted unless requested.
 type AggregatedTelemetry struct {
-	MinTemperature float32 `json:"min_temperature"`
-	MaxTemperature float32 `json:"max_temperature"`
-	AvgTemperature float32 `json:"avg_temperature"`
-	MinBattery     float32 `json:"min_battery"`
-	MaxBattery     float32 `json:"max_battery"`
-	AvgBattery     float32 `json:"avg_battery"`
-	MinAltitude    float32 `json:"min_altitude"`
-	MaxAltitude    float32 `json:"max_altitude"`
-	AvgAltitude    float32 `json:"avg_altitude"`
-	MinSignal      float32 `json:"min_signal"`
-	MaxSignal      float32 `json:"max_signal"`
-	AvgSignal      float32 `json:"avg_signal"`
+	StartTime  time.Time                 `json:"start_time"`
+	EndTime    time.Time                 `json:"end_time"`
+	Count      *int64                    `json:"count,omitempty"`
+	Anomalies  *AnomalyStats             `json:"anomalies,omitempty"`
+	Parameters map[string]ParameterStats `json:"parameters"`
+}
+
+// BucketStats stores the statistics of one parameter within a time bucket
+type BucketStats struct {
+	Min   float32 `json:"min"`
+	Max   float32 `json:"max"`
+	Avg   float32 `json:"avg"`
+	First float32 `json:"first"` // Earliest value in the bucket
+	Last  float32 `json:"last"`  // Latest value in the bucket
+}
+
+// TelemetryBucket stores the statistics of the telemetry within one time
+// bucket. Buckets without data have a count of 0 and null statistics, so
+// charts can show the gap instead of joining across it.
+type TelemetryBucket struct {
+	Start       time.Time    `json:"start"`
+	Count       int64        `json:"count"`
+	Temperature *BucketStats `json:"temperature"`
+	Battery     *BucketStats `json:"battery"`
+	Altitude    *BucketStats `json:"altitude"`
+	Signal      *BucketStats `json:"signal"`
+}
+
+// BucketedTelemetry is a series of consecutive, equally sized time buckets
+type BucketedTelemetry struct {
+	StartTime time.Time         `json:"start_time"`
+	EndTime   time.Time         `json:"end_time"`
+	Interval  string            `json:"interval"`         // Bucket width as a duration, e.g. "1m0s"
+	Seconds   int64             `json:"interval_seconds"` // Bucket width in seconds
+	Buckets   []TelemetryBucket `json:"buckets"`
 }
 
 // PaginatedResponse is a wrapper for paginated data
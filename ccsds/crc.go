@@ -0,0 +1,61 @@
+package ccsds
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// CRCSize is the length of a packet or frame error control field
+const CRCSize = 2
+
+// ErrCRC is returned when an error control field does not match
+var ErrCRC = errors.New("ccsds: error control field mismatch")
+
+// CRC-16-CCITT parameters as used by the packet and frame error control fields
+const (
+	ccittPoly = 0x1021
+	ccittInit = 0xFFFF
+)
+
+// ccittTable holds the precomputed CRC of every byte value
+var ccittTable = makeCCITTTable()
+
+func makeCCITTTable() [256]uint16 {
+	var table [256]uint16
+	for i := range table {
+		crc := uint16(i) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ ccittPoly
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}
+
+// CRC16CCITT computes the CRC-16-CCITT (polynomial 0x1021, initial value 0xFFFF,
+// no reflection, no final XOR) of data
+func CRC16CCITT(data []byte) uint16 {
+	crc := uint16(ccittInit)
+	for _, b := range data {
+		crc = crc<<8 ^ ccittTable[byte(crc>>8)^b]
+	}
+	return crc
+}
+
+// AppendCRC appends the CRC of covered to dst
+func AppendCRC(dst, covered []byte) []byte {
+	return binary.BigEndian.AppendUint16(dst, CRC16CCITT(covered))
+}
+
+// CheckCRC reports whether the last two bytes of b are the CRC of the rest
+func CheckCRC(b []byte) bool {
+	if len(b) < CRCSize {
+		return false
+	}
+	end := len(b) - CRCSize
+	return CRC16CCITT(b[:end]) == binary.BigEndian.Uint16(b[end:])
+}
@@ -0,0 +1,8 @@
+// Package ccsds encodes and decodes CCSDS space packets (CCSDS 133.0-B),
+// the telemetry carried in them and the Reed-Solomon channel code of coded
+// transfer frames (CCSDS 131.0-B). It is shared by the ground software and
+// the spacecraft simulator so that both agree on the wire format.
+//
+// Parsing works on the caller's buffer and does not allocate: a Packet is a
+// view of the bytes it was parsed from.
+package ccsds
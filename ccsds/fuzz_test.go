@@ -0,0 +1,131 @@
+package ccsds
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// FuzzParse checks that any input either fails cleanly or yields a packet
+// whose header re-encodes to the original bytes
+func FuzzParse(f *testing.F) {
+	enc := Encoder{APID: 1, SecondaryHeader: true, CRC: true}
+	f.Add(enc.Encode(nil, []byte("telemetry")))
+	f.Add([]byte{0x08, 0x01, 0xC0, 0x00, 0x00})
+	f.Add([]byte{0xE0, 0x00, 0xC0, 0x00, 0x00, 0x00, 0xFF})
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		p, err := Parse(b)
+		if err != nil {
+			if !errors.Is(err, ErrShortHeader) && !errors.Is(err, ErrTruncated) && !errors.Is(err, ErrVersion) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			return
+		}
+		h := p.Header()
+		if len(p) != h.PacketSize() || len(p) > len(b) {
+			t.Fatalf("packet of %d bytes for a header declaring %d", len(p), h.PacketSize())
+		}
+		if !bytes.Equal(h.Append(nil), b[:PrimaryHeaderSize]) {
+			t.Fatalf("header %+v does not re-encode to %x", h, b[:PrimaryHeaderSize])
+		}
+		if _, err := p.UserData(); err != nil && !errors.Is(err, ErrCRC) && !errors.Is(err, ErrTruncated) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+}
+
+// FuzzRoundTrip checks that encoded packets decode to the same fields
+func FuzzRoundTrip(f *testing.F) {
+	f.Add(uint16(1), uint16(0), true, true, []byte("payload"))
+	f.Add(uint16(MaxAPID), uint16(MaxSeqCount), false, false, []byte{0})
+
+	f.Fuzz(func(t *testing.T, apid, seq uint16, tc, withCRC bool, data []byte) {
+		if len(data) == 0 || len(data)+CRCSize > MaxDataLength {
+			return
+		}
+		enc := Encoder{APID: apid & MaxAPID, CRC: withCRC}
+		if tc {
+			enc.Type = TypeTC
+		}
+		enc.SetSeqCount(seq)
+
+		// Two packets back to back exercise the decoder
+		b := enc.Encode(nil, data)
+		b = enc.Encode(b, data)
+
+		d := NewDecoder(b)
+		for i := uint16(0); i < 2; i++ {
+			p, err := d.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := p.Header()
+			if h.APID() != apid&MaxAPID || h.SeqCount() != (seq+i)&MaxSeqCount ||
+				h.Type() != enc.Type || h.SeqFlags() != SeqFlagStandalone || h.Version() != 0 {
+				t.Fatalf("decoded %+v", h)
+			}
+
+			got := p.Data()
+			if withCRC {
+				if got, err = p.UserData(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("data %x, want %x", got, data)
+			}
+		}
+		if _, err := d.Next(); err != io.EOF {
+			t.Fatalf("expected EOF, got %v", err)
+		}
+	})
+}
+
+// FuzzTelemetry checks that telemetry structures survive encoding
+func FuzzTelemetry(f *testing.F) {
+	f.Add(uint64(1700000000), uint16(1), float32(25), float32(90), float32(520), float32(-50), uint8(1), uint8(0), uint16(1000))
+
+	f.Fuzz(func(t *testing.T, ts uint64, subsystem uint16, temp, battery, altitude, signal float32, heater, safe uint8, interval uint16) {
+		sec := SecondaryHeader{Timestamp: ts, SubsystemID: subsystem}
+		payload := TelemetryPayload{temp, battery, altitude, signal}
+		status := SpacecraftStatus{heater, safe, interval}
+
+		b := status.Append(payload.Append(sec.Append(nil)))
+		if len(b) != SecondaryHeaderSize+TelemetryPayloadSize+SpacecraftStatusSize {
+			t.Fatalf("encoded %d bytes", len(b))
+		}
+
+		gotSec, _ := ParseSecondaryHeader(b)
+		gotPayload, _ := ParseTelemetryPayload(b[SecondaryHeaderSize:])
+		gotStatus, _ := ParseSpacecraftStatus(b[SecondaryHeaderSize+TelemetryPayloadSize:])
+		if gotSec != sec || gotStatus != status ||
+			!bytes.Equal(gotPayload.Append(nil), payload.Append(nil)) { // NaN != NaN, so compare bits
+			t.Fatalf("decoded %+v %+v %+v", gotSec, gotPayload, gotStatus)
+		}
+	})
+}
+
+// TestParseDoesNotAllocate guards the zero-allocation parsing path
+func TestParseDoesNotAllocate(t *testing.T) {
+	enc := Encoder{APID: 1, SecondaryHeader: true, CRC: true}
+	b := enc.Encode(nil, TelemetryPayload{}.Append(SecondaryHeader{}.Append(nil)))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		p, err := Parse(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := p.UserData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseTelemetryPayload(data[SecondaryHeaderSize:]); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("parsing allocated %.0f times", allocs)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds
+
+go 1.20
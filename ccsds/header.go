@@ -0,0 +1,122 @@
+package ccsds
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// PrimaryHeaderSize is the encoded size of a primary header in bytes
+const PrimaryHeaderSize = 6
+
+// Field limits
+const (
+	MaxAPID       = 0x07FF
+	IdleAPID      = 0x07FF // Reserved for idle packets
+	MaxSeqCount   = 0x3FFF
+	SeqCountRange = MaxSeqCount + 1
+	MaxDataLength = 0xFFFF + 1 // Longest packet data field
+)
+
+// Packet types
+const (
+	TypeTM uint8 = 0 // Telemetry
+	TypeTC uint8 = 1 // Telecommand
+)
+
+// Sequence flag values carried in the top two bits of PacketSeqCtrl
+const (
+	SeqFlagContinuation uint8 = 0x0 // Continuation segment of a segmented packet
+	SeqFlagFirst        uint8 = 0x1 // First segment of a segmented packet
+	SeqFlagLast         uint8 = 0x2 // Last segment of a segmented packet
+	SeqFlagStandalone   uint8 = 0x3 // Unsegmented packet
+)
+
+var (
+	// ErrShortHeader is returned for buffers shorter than a primary header
+	ErrShortHeader = errors.New("ccsds: buffer shorter than a primary header")
+	// ErrTruncated is returned when a packet is shorter than its header declares
+	ErrTruncated = errors.New("ccsds: packet shorter than its declared length")
+	// ErrVersion is returned for packets with a version other than 0
+	ErrVersion = errors.New("ccsds: unsupported packet version")
+)
+
+// PrimaryHeader is the 6-byte primary header of a space packet, laid out as
+// on the wire so that it can also be used with encoding/binary
+type PrimaryHeader struct {
+	PacketID      uint16 // Version (3 bits) | Type (1 bit) | SecHdrFlag (1 bit) | APID (11 bits)
+	PacketSeqCtrl uint16 // SeqFlags (2 bits) | SeqCount (14 bits)
+	PacketLength  uint16 // Data field length minus 1
+}
+
+// NewPrimaryHeader packs the fields of a version 0 primary header.
+// dataLength is the length of the packet data field in bytes.
+func NewPrimaryHeader(packetType uint8, secondaryHeader bool, apid uint16, seqFlags uint8, seqCount uint16, dataLength int) PrimaryHeader {
+	id := uint16(packetType&1)<<12 | apid&MaxAPID
+	if secondaryHeader {
+		id |= 1 << 11
+	}
+	return PrimaryHeader{
+		PacketID:      id,
+		PacketSeqCtrl: uint16(seqFlags&3)<<14 | seqCount&MaxSeqCount,
+		PacketLength:  uint16(dataLength - 1),
+	}
+}
+
+// ParsePrimaryHeader decodes the primary header at the start of b
+func ParsePrimaryHeader(b []byte) (PrimaryHeader, error) {
+	if len(b) < PrimaryHeaderSize {
+		return PrimaryHeader{}, ErrShortHeader
+	}
+	return PrimaryHeader{
+		PacketID:      binary.BigEndian.Uint16(b[0:2]),
+		PacketSeqCtrl: binary.BigEndian.Uint16(b[2:4]),
+		PacketLength:  binary.BigEndian.Uint16(b[4:6]),
+	}, nil
+}
+
+// Append appends the encoded header to b
+func (h PrimaryHeader) Append(b []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, h.PacketID)
+	b = binary.BigEndian.AppendUint16(b, h.PacketSeqCtrl)
+	return binary.BigEndian.AppendUint16(b, h.PacketLength)
+}
+
+// Version returns the packet version number
+func (h PrimaryHeader) Version() uint8 {
+	return uint8(h.PacketID >> 13)
+}
+
+// Type returns TypeTM or TypeTC
+func (h PrimaryHeader) Type() uint8 {
+	return uint8(h.PacketID>>12) & 1
+}
+
+// HasSecondaryHeader reports whether the secondary header flag is set
+func (h PrimaryHeader) HasSecondaryHeader() bool {
+	return h.PacketID&(1<<11) != 0
+}
+
+// APID returns the application process identifier
+func (h PrimaryHeader) APID() uint16 {
+	return h.PacketID & MaxAPID
+}
+
+// SeqFlags returns the segmentation flags
+func (h PrimaryHeader) SeqFlags() uint8 {
+	return uint8(h.PacketSeqCtrl >> 14)
+}
+
+// SeqCount returns the 14-bit sequence count
+func (h PrimaryHeader) SeqCount() uint16 {
+	return h.PacketSeqCtrl & MaxSeqCount
+}
+
+// DataLength returns the length of the packet data field in bytes
+func (h PrimaryHeader) DataLength() int {
+	return int(h.PacketLength) + 1
+}
+
+// PacketSize returns the length of the whole packet in bytes
+func (h PrimaryHeader) PacketSize() int {
+	return PrimaryHeaderSize + h.DataLength()
+}
@@ -0,0 +1,120 @@
+package ccsds
+
+import "io"
+
+// Packet is one encoded space packet. It is a view of the buffer it was
+// parsed from and is only valid while that buffer is unchanged.
+type Packet []byte
+
+// Parse returns the packet at the start of b. Bytes after the packet are
+// ignored.
+func Parse(b []byte) (Packet, error) {
+	h, err := ParsePrimaryHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	if h.Version() != 0 {
+		return nil, ErrVersion
+	}
+	size := h.PacketSize()
+	if len(b) < size {
+		return nil, ErrTruncated
+	}
+	return Packet(b[:size:size]), nil
+}
+
+// Header returns the packet's primary header
+func (p Packet) Header() PrimaryHeader {
+	h, _ := ParsePrimaryHeader(p)
+	return h
+}
+
+// APID returns the packet's application process identifier
+func (p Packet) APID() uint16 {
+	return p.Header().APID()
+}
+
+// SeqCount returns the packet's sequence count
+func (p Packet) SeqCount() uint16 {
+	return p.Header().SeqCount()
+}
+
+// Data returns the packet data field, including any secondary header and
+// error control field
+func (p Packet) Data() []byte {
+	return p[PrimaryHeaderSize:]
+}
+
+// UserData returns the data field without the trailing error control
+// field, after checking it. Packets too short to carry one are truncated.
+func (p Packet) UserData() ([]byte, error) {
+	if len(p) < PrimaryHeaderSize+CRCSize {
+		return nil, ErrTruncated
+	}
+	if !CheckCRC(p) {
+		return nil, ErrCRC
+	}
+	return p[PrimaryHeaderSize : len(p)-CRCSize], nil
+}
+
+// Decoder splits a buffer of concatenated packets
+type Decoder struct {
+	buf []byte
+}
+
+// NewDecoder returns a decoder reading the packets in b
+func NewDecoder(b []byte) *Decoder {
+	return &Decoder{buf: b}
+}
+
+// Next returns the next packet, or io.EOF once the buffer is used up. A
+// truncated final packet is reported with ErrTruncated.
+func (d *Decoder) Next() (Packet, error) {
+	if len(d.buf) == 0 {
+		return nil, io.EOF
+	}
+	p, err := Parse(d.buf)
+	if err != nil {
+		return nil, err
+	}
+	d.buf = d.buf[len(p):]
+	return p, nil
+}
+
+// Encoder builds packets of one APID with consecutive sequence counts
+type Encoder struct {
+	Type            uint8 // TypeTM or TypeTC
+	APID            uint16
+	SecondaryHeader bool // Sets the secondary header flag
+	CRC             bool // Appends a CRC-16-CCITT error control field
+	seqCount        uint16
+}
+
+// SeqCount returns the sequence count of the next packet
+func (e *Encoder) SeqCount() uint16 {
+	return e.seqCount
+}
+
+// SetSeqCount sets the sequence count of the next packet
+func (e *Encoder) SetSeqCount(seq uint16) {
+	e.seqCount = seq & MaxSeqCount
+}
+
+// Encode appends a standalone packet carrying data to dst and advances the
+// sequence count
+func (e *Encoder) Encode(dst, data []byte) []byte {
+	length := len(data)
+	if e.CRC {
+		length += CRCSize
+	}
+
+	start := len(dst)
+	dst = NewPrimaryHeader(e.Type, e.SecondaryHeader, e.APID, SeqFlagStandalone, e.seqCount, length).Append(dst)
+	dst = append(dst, data...)
+	if e.CRC {
+		dst = AppendCRC(dst, dst[start:])
+	}
+
+	e.seqCount = (e.seqCount + 1) & MaxSeqCount
+	return dst
+}
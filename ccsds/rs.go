@@ -0,0 +1,294 @@
+package ccsds
+
+// Reed-Solomon (255,223) as specified in CCSDS 131.0-B: field generator
+// x^8+x^7+x^2+x+1, code generator roots alpha^(11*j) for j = 112..143, and
+// symbols carried in Berlekamp's dual basis. The decoder follows Phil Karn's
+// Berlekamp-Massey implementation.
+// Reed-Solomon codeword dimensions in symbols
+const (
+	RSCodewordSize = rsN
+	RSDataSize     = rsK
+	RSParitySize   = rsRoots
+)
+
+// ASM is the attached sync marker preceding every codeblock (CCSDS 131.0-B)
+var ASM = []byte{0x1A, 0xCF, 0xFC, 0x1D}
+
+const (
+	rsN      = 255 // Codeword length in symbols
+	rsK      = 223 // Data symbols per codeword
+	rsRoots  = rsN - rsK
+	rsFCR    = 112 // First consecutive root, in index form
+	rsPrim   = 11  // Primitive element of the roots
+	rsIPrim  = 116 // rsPrim^-1 modulo 255
+	rsGFPoly = 0x187
+	rsA0     = rsN // Index form of zero
+)
+
+var (
+	alphaTo    [rsN + 1]int     // Index form to polynomial form
+	indexOf    [rsN + 1]int     // Polynomial form to index form
+	genPoly    [rsRoots + 1]int // Code generator polynomial, index form
+	dualToConv [256]byte        // Dual basis to conventional representation
+	convToDual [256]byte        // Conventional to dual basis representation
+)
+
+func init() {
+	sr := 1
+	for i := 0; i < rsN; i++ {
+		indexOf[sr] = i
+		alphaTo[i] = sr
+		sr <<= 1
+		if sr&0x100 != 0 {
+			sr ^= rsGFPoly
+		}
+		sr &= rsN
+	}
+	indexOf[0] = rsA0
+	alphaTo[rsA0] = 0
+
+	genPoly[0] = 1
+	for i, root := 0, rsFCR*rsPrim; i < rsRoots; i, root = i+1, root+rsPrim {
+		genPoly[i+1] = 1
+		for j := i; j > 0; j-- {
+			if genPoly[j] != 0 {
+				genPoly[j] = genPoly[j-1] ^ alphaTo[modnn(indexOf[genPoly[j]]+root)]
+			} else {
+				genPoly[j] = genPoly[j-1]
+			}
+		}
+		genPoly[0] = alphaTo[modnn(indexOf[genPoly[0]]+root)]
+	}
+	for i := range genPoly {
+		genPoly[i] = indexOf[genPoly[i]]
+	}
+
+	// Transformation matrix between the two representations (CCSDS 131.0-B annex F)
+	tal := [8]byte{0x8d, 0xef, 0xec, 0x86, 0xfa, 0x99, 0xaf, 0x7b}
+	for i := 0; i < 256; i++ {
+		var v byte
+		for j := 0; j < 8; j++ {
+			for k := 0; k < 8; k++ {
+				if i&(1<<k) != 0 {
+					v ^= tal[7-k] & (1 << j)
+				}
+			}
+		}
+		convToDual[i] = v
+		dualToConv[v] = byte(i)
+	}
+}
+
+func modnn(x int) int {
+	for x >= rsN {
+		x -= rsN
+		x = (x >> 8) + (x & rsN)
+	}
+	return x
+}
+
+// RSEncode computes the parity symbols of one codeword whose first
+// RSDataSize symbols are data, all in dual basis representation
+func RSEncode(data []byte) [RSParitySize]byte {
+	var parity [rsRoots]int
+	for i := 0; i < rsK; i++ {
+		feedback := indexOf[int(dualToConv[data[i]])^parity[0]]
+		if feedback != rsA0 {
+			for j := 1; j < rsRoots; j++ {
+				parity[j] ^= alphaTo[modnn(feedback+genPoly[rsRoots-j])]
+			}
+		}
+		copy(parity[:], parity[1:])
+		if feedback != rsA0 {
+			parity[rsRoots-1] = alphaTo[modnn(feedback+genPoly[0])]
+		} else {
+			parity[rsRoots-1] = 0
+		}
+	}
+
+	var out [rsRoots]byte
+	for i, p := range parity {
+		out[i] = convToDual[p]
+	}
+	return out
+}
+
+// RSDecode corrects one codeword of RSCodewordSize dual basis symbols in
+// place. It returns the number of corrected symbols, or -1 if the codeword
+// cannot be corrected, in which case it is left unchanged.
+func RSDecode(codeword []byte) int {
+	var data [rsN]int
+	for i := range data {
+		data[i] = int(dualToConv[codeword[i]])
+	}
+
+	// Syndromes
+	var s [rsRoots]int
+	for i := range s {
+		s[i] = data[0]
+	}
+	for j := 1; j < rsN; j++ {
+		for i := range s {
+			if s[i] == 0 {
+				s[i] = data[j]
+			} else {
+				s[i] = data[j] ^ alphaTo[modnn(indexOf[s[i]]+(rsFCR+i)*rsPrim)]
+			}
+		}
+	}
+	synError := 0
+	for i := range s {
+		synError |= s[i]
+		s[i] = indexOf[s[i]]
+	}
+	if synError == 0 {
+		return 0
+	}
+
+	// Berlekamp-Massey: find the error locator polynomial
+	var lambda, b, t [rsRoots + 1]int
+	lambda[0] = 1
+	for i := range b {
+		b[i] = indexOf[lambda[i]]
+	}
+	el := 0
+	for r := 1; r <= rsRoots; r++ {
+		discr := 0
+		for i := 0; i < r; i++ {
+			if lambda[i] != 0 && s[r-i-1] != rsA0 {
+				discr ^= alphaTo[modnn(indexOf[lambda[i]]+s[r-i-1])]
+			}
+		}
+		discr = indexOf[discr]
+		if discr == rsA0 {
+			copy(b[1:], b[:rsRoots])
+			b[0] = rsA0
+			continue
+		}
+
+		t[0] = lambda[0]
+		for i := 0; i < rsRoots; i++ {
+			if b[i] != rsA0 {
+				t[i+1] = lambda[i+1] ^ alphaTo[modnn(discr+b[i])]
+			} else {
+				t[i+1] = lambda[i+1]
+			}
+		}
+		if 2*el <= r-1 {
+			el = r - el
+			for i := range b {
+				if lambda[i] == 0 {
+					b[i] = rsA0
+				} else {
+					b[i] = modnn(indexOf[lambda[i]] - discr + rsN)
+				}
+			}
+		} else {
+			copy(b[1:], b[:rsRoots])
+			b[0] = rsA0
+		}
+		lambda = t
+	}
+
+	degLambda := 0
+	for i := range lambda {
+		lambda[i] = indexOf[lambda[i]]
+		if lambda[i] != rsA0 {
+			degLambda = i
+		}
+	}
+
+	// Chien search: find the roots of the error locator
+	var reg [rsRoots + 1]int
+	copy(reg[1:], lambda[1:])
+	var root, loc [rsRoots]int
+	count := 0
+	for i, k := 1, rsIPrim-1; i <= rsN; i, k = i+1, modnn(k+rsIPrim) {
+		q := 1
+		for j := degLambda; j > 0; j-- {
+			if reg[j] != rsA0 {
+				reg[j] = modnn(reg[j] + j)
+				q ^= alphaTo[reg[j]]
+			}
+		}
+		if q != 0 {
+			continue
+		}
+		root[count] = i
+		loc[count] = k
+		count++
+		if count == degLambda {
+			break
+		}
+	}
+	if count != degLambda {
+		return -1
+	}
+
+	// Error evaluator polynomial
+	degOmega := degLambda - 1
+	var omega [rsRoots + 1]int
+	for i := 0; i <= degOmega; i++ {
+		tmp := 0
+		for j := i; j >= 0; j-- {
+			if s[i-j] != rsA0 && lambda[j] != rsA0 {
+				tmp ^= alphaTo[modnn(s[i-j]+lambda[j])]
+			}
+		}
+		omega[i] = indexOf[tmp]
+	}
+
+	// Forney: compute the error values
+	for j := count - 1; j >= 0; j-- {
+		num1 := 0
+		for i := degOmega; i >= 0; i-- {
+			if omega[i] != rsA0 {
+				num1 ^= alphaTo[modnn(omega[i]+i*root[j])]
+			}
+		}
+		num2 := alphaTo[modnn(root[j]*(rsFCR-1)+rsN)]
+		den := 0
+
+		// lambda[i+1] for even i is the formal derivative of lambda
+		start := degLambda
+		if start > rsRoots-1 {
+			start = rsRoots - 1
+		}
+		for i := start &^ 1; i >= 0; i -= 2 {
+			if lambda[i+1] != rsA0 {
+				den ^= alphaTo[modnn(lambda[i+1]+i*root[j])]
+			}
+		}
+		if den == 0 {
+			return -1
+		}
+		if num1 != 0 {
+			data[loc[j]] ^= alphaTo[modnn(indexOf[num1]+indexOf[num2]+rsN-indexOf[den])]
+		}
+	}
+
+	for i := range data {
+		codeword[i] = convToDual[data[i]]
+	}
+	return count
+}
+
+// RSEncodeBlock encodes a frame of RSDataSize*depth bytes into a codeblock of
+// RSCodewordSize*depth bytes, interleaving depth codewords symbol by symbol
+func RSEncodeBlock(frame []byte, depth int) []byte {
+	block := make([]byte, rsN*depth)
+	codeword := make([]byte, rsK)
+	for i := 0; i < depth; i++ {
+		for j := range codeword {
+			codeword[j] = frame[j*depth+i]
+		}
+		parity := RSEncode(codeword)
+		for j := 0; j < rsK; j++ {
+			block[j*depth+i] = codeword[j]
+		}
+		for j, p := range parity {
+			block[(rsK+j)*depth+i] = p
+		}
+	}
+	return block
+}
@@ -0,0 +1,82 @@
+package ccsds
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// codeword encodes random data into one codeword
+func codeword(rng *rand.Rand) []byte {
+	c := make([]byte, rsK, rsN)
+	rng.Read(c)
+	parity := RSEncode(c)
+	return append(c, parity[:]...)
+}
+
+// corrupt changes n distinct symbols of a codeword
+func corrupt(rng *rand.Rand, c []byte, n int) {
+	for _, i := range rng.Perm(len(c))[:n] {
+		c[i] ^= byte(1 + rng.Intn(255))
+	}
+}
+
+func TestRSDecodeCorrects(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	// Up to half the parity symbols can be corrected, wherever they fall
+	for errors := 0; errors <= rsRoots/2; errors++ {
+		original := codeword(rng)
+		received := append([]byte(nil), original...)
+		corrupt(rng, received, errors)
+
+		if n := RSDecode(received); n != errors {
+			t.Fatalf("%d symbol errors: corrected %d", errors, n)
+		}
+		if !bytes.Equal(received, original) {
+			t.Fatalf("%d symbol errors: codeword not restored", errors)
+		}
+	}
+}
+
+func TestRSDecodeUncorrectable(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, errors := range []int{rsRoots/2 + 1, 40} {
+		for trial := 0; trial < 20; trial++ {
+			received := codeword(rng)
+			corrupt(rng, received, errors)
+			before := append([]byte(nil), received...)
+
+			// A failed decode leaves the codeword as received
+			if n := RSDecode(received); n != -1 {
+				t.Fatalf("%d symbol errors, trial %d: decode returned %d", errors, trial, n)
+			}
+			if !bytes.Equal(received, before) {
+				t.Fatalf("%d symbol errors, trial %d: codeword changed", errors, trial)
+			}
+		}
+	}
+}
+
+func TestRSEncodeBlockInterleaves(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	frame := make([]byte, rsK*3)
+	rng.Read(frame)
+	block := RSEncodeBlock(frame, 3)
+
+	// Every third symbol belongs to the same codeword
+	for i := 0; i < 3; i++ {
+		c := make([]byte, rsN)
+		for j := range c {
+			c[j] = block[j*3+i]
+		}
+		if n := RSDecode(c); n != 0 {
+			t.Fatalf("codeword %d: decode returned %d", i, n)
+		}
+		for j := 0; j < rsK; j++ {
+			if c[j] != frame[j*3+i] {
+				t.Fatalf("codeword %d: symbol %d is not frame byte %d", i, j, j*3+i)
+			}
+		}
+	}
+}
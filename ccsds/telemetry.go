@@ -0,0 +1,96 @@
+package ccsds
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Encoded sizes of the telemetry structures
+const (
+	SecondaryHeaderSize  = 10
+	TelemetryPayloadSize = 16
+	SpacecraftStatusSize = 4
+)
+
+// SecondaryHeader is the secondary header of a telemetry packet when the
+// default Unix seconds time code is in use. Ground software configured for
+// CUC or CDS time codes replaces Timestamp with a code of another length.
+type SecondaryHeader struct {
+	Timestamp   uint64 // Unix timestamp (seconds since epoch)
+	SubsystemID uint16 // Identifies the subsystem (e.g., power, thermal)
+}
+
+// TelemetryPayload is the housekeeping telemetry following the secondary header
+type TelemetryPayload struct {
+	Temperature float32 // Temperature in Celsius
+	Battery     float32 // Battery percentage (0-100%)
+	Altitude    float32 // Altitude in kilometers
+	Signal      float32 // Signal strength in decibels (dB)
+}
+
+// SpacecraftStatus optionally follows the telemetry payload and reports the
+// state changed by telecommands
+type SpacecraftStatus struct {
+	HeaterState  uint8  // Battery heater, 1 = on
+	SafeMode     uint8  // Safe mode, 1 = active
+	TxIntervalMs uint16 // Telemetry transmit interval in milliseconds
+}
+
+// Append appends the encoded secondary header to b
+func (s SecondaryHeader) Append(b []byte) []byte {
+	b = binary.BigEndian.AppendUint64(b, s.Timestamp)
+	return binary.BigEndian.AppendUint16(b, s.SubsystemID)
+}
+
+// ParseSecondaryHeader decodes the secondary header at the start of b
+func ParseSecondaryHeader(b []byte) (SecondaryHeader, error) {
+	if len(b) < SecondaryHeaderSize {
+		return SecondaryHeader{}, ErrTruncated
+	}
+	return SecondaryHeader{
+		Timestamp:   binary.BigEndian.Uint64(b[0:8]),
+		SubsystemID: binary.BigEndian.Uint16(b[8:10]),
+	}, nil
+}
+
+// Append appends the encoded payload to b
+func (p TelemetryPayload) Append(b []byte) []byte {
+	for _, v := range [...]float32{p.Temperature, p.Battery, p.Altitude, p.Signal} {
+		b = binary.BigEndian.AppendUint32(b, math.Float32bits(v))
+	}
+	return b
+}
+
+// ParseTelemetryPayload decodes the payload at the start of b
+func ParseTelemetryPayload(b []byte) (TelemetryPayload, error) {
+	if len(b) < TelemetryPayloadSize {
+		return TelemetryPayload{}, ErrTruncated
+	}
+	value := func(i int) float32 {
+		return math.Float32frombits(binary.BigEndian.Uint32(b[4*i:]))
+	}
+	return TelemetryPayload{
+		Temperature: value(0),
+		Battery:     value(1),
+		Altitude:    value(2),
+		Signal:      value(3),
+	}, nil
+}
+
+// Append appends the encoded status to b
+func (s SpacecraftStatus) Append(b []byte) []byte {
+	b = append(b, s.HeaterState, s.SafeMode)
+	return binary.BigEndian.AppendUint16(b, s.TxIntervalMs)
+}
+
+// ParseSpacecraftStatus decodes the status at the start of b
+func ParseSpacecraftStatus(b []byte) (SpacecraftStatus, error) {
+	if len(b) < SpacecraftStatusSize {
+		return SpacecraftStatus{}, ErrTruncated
+	}
+	return SpacecraftStatus{
+		HeaterState:  b[0],
+		SafeMode:     b[1],
+		TxIntervalMs: binary.BigEndian.Uint16(b[2:4]),
+	}, nil
+}
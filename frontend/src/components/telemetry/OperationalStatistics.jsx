@@ -56,7 +56,8 @@ const OperationalStatistics = () => {
         
         const data = await getAggregatedTelemetry(
           startTime.toISOString(),
-          endTime.toISOString()
+          endTime.toISOString(),
+          ["min", "max", "avg"]
         );
         
         setStats(data);
@@ -72,7 +73,7 @@ const OperationalStatistics = () => {
   }, [timeRange]);
 
   const formatValue = (value) => {
-    return value !== undefined ? value.toFixed(1) : "N/A";
+    return value != null ? value.toFixed(1) : "N/A";
   };
 
   const StatItem = ({ label, min, max, avg, unit }) => (
@@ -152,30 +153,30 @@ const OperationalStatistics = () => {
           
           <StatItem 
             label="Temperature" 
-            min={stats.min_temperature} 
-            max={stats.max_temperature} 
-            avg={stats.avg_temperature} 
+            min={stats.parameters?.temperature?.min} 
+            max={stats.parameters?.temperature?.max} 
+            avg={stats.parameters?.temperature?.avg} 
             unit="°C" 
           />
           <StatItem 
             label="Battery" 
-            min={stats.min_battery} 
-            max={stats.max_battery} 
-            avg={stats.avg_battery} 
+            min={stats.parameters?.battery?.min} 
+            max={stats.parameters?.battery?.max} 
+            avg={stats.parameters?.battery?.avg} 
             unit="%" 
           />
           <StatItem 
             label="Altitude" 
-            min={stats.min_altitude} 
-            max={stats.max_altitude} 
-            avg={stats.avg_altitude} 
+            min={stats.parameters?.altitude?.min} 
+            max={stats.parameters?.altitude?.max} 
+            avg={stats.parameters?.altitude?.avg} 
             unit="km" 
           />
           <StatItem 
             label="Signal" 
-            min={stats.min_signal} 
-            max={stats.max_signal} 
-            avg={stats.avg_signal} 
+            min={stats.parameters?.signal?.min} 
+            max={stats.parameters?.signal?.max} 
+            avg={stats.parameters?.signal?.avg} 
             unit="dB" 
           />
         </div>
@@ -122,10 +122,11 @@ export const getLastTelemetry = async (count) => {
   }
 };
 
-export const getAggregatedTelemetry = async (startTime, endTime) => {
+// Fetch range statistics; stats is a list such as ["min", "max", "p95", "count"]
+export const getAggregatedTelemetry = async (startTime, endTime, stats) => {
   try {
     const response = await axios.get(`${API_URL}/telemetry/aggregate`, {
-      params: { start_time: startTime, end_time: endTime },
+      params: { start_time: startTime, end_time: endTime, stats: stats?.join(",") },
     });
 
     if (!response.data) {
@@ -140,6 +141,25 @@ export const getAggregatedTelemetry = async (startTime, endTime) => {
   }
 };
 
+// Fetch statistics in time buckets; pass either interval (e.g. "1m") or maxPoints
+export const getBucketedTelemetry = async (startTime, endTime, { interval, maxPoints } = {}) => {
+  try {
+    const response = await axios.get(`${API_URL}/telemetry/buckets`, {
+      params: { start_time: startTime, end_time: endTime, interval, max_points: maxPoints },
+    });
+
+    if (!response.data) {
+      console.error("API returned null data for buckets");
+      return null;
+    }
+
+    return response.data;
+  } catch (error) {
+    console.error("API bucket request failed:", error);
+    return null;
+  }
+};
+
 export const getPaginatedTelemetry = async (params = {}) => {
   try {
     const response = await axios.get(`${API_URL}/telemetry/paginated`, { params });
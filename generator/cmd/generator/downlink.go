@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"math/rand"
+	"sync"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// downlink wraps packets in the configured framing and sends them to the
+// ground. It is shared by telemetry and command acknowledgements.
+type downlink struct {
+	mu     sync.Mutex
+	link   *impairedLink
+	frames *frameBuilder
+	noise  *rand.Rand // Symbol errors injected in cadu mode
+}
+
+// newDownlink creates a downlink for a spacecraft over link. Its symbol
+// errors are drawn from the link's seed so they repeat with the impairments.
+func newDownlink(link *impairedLink, spacecraftID uint16) *downlink {
+	return &downlink{
+		link: link,
+		// Frames are sized to fill one codeblock
+		frames: &frameBuilder{spacecraftID: spacecraftID, length: ccsds.RSDataSize * *interleave},
+		noise:  rand.New(rand.NewSource(link.seed + 1 + int64(spacecraftID))),
+	}
+}
+
+// send frames, authenticates and transmits one packet, which takes several
+// datagrams when it spans transfer frames
+func (d *downlink) send(packet []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	datagrams := [][]byte{packet}
+	switch *mode {
+	case "frame":
+		datagrams = d.frames.build(packet)
+	case "cadu":
+		datagrams = d.frames.build(packet)
+		for i, frame := range datagrams {
+			datagrams[i] = cadu(frame, *interleave, *symbolErrors, d.noise)
+		}
+	}
+	for _, data := range datagrams {
+		if err := d.write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write authenticates and transmits one datagram
+func (d *downlink) write(data []byte) error {
+	if *hmacKey != "" {
+		mac := hmac.New(sha256.New, []byte(*hmacKey))
+		mac.Write(data)
+		data = mac.Sum(data)
+	}
+
+	return d.link.write(data)
+}
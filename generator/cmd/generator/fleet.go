@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// fleetFile describes several spacecraft to simulate instead of the one
+// configured by flags
+var fleetFile = flag.String("fleet", "", "JSON file describing the spacecraft to simulate and their packet streams")
+
+// streamConfig is one packet type sent by a spacecraft
+type streamConfig struct {
+	APID      uint16 `json:"apid"`
+	Subsystem uint16 `json:"subsystem"`
+	Interval  string `json:"interval,omitempty"` // Send period; 1s if empty
+}
+
+// spacecraftConfig describes one simulated spacecraft. Zero orbit values
+// take the flag defaults. To command a fleet, the backend's
+// COMMAND_SPACECRAFT lists each spacecraft as id:command_apid:ack_apid, for
+// the example constellation "1:16:17,2:32:33". Only transfer frames carry the
+// spacecraft ID, so a fleet is sent in frame or CADU mode.
+type spacecraftConfig struct {
+	Name        string         `json:"name"`
+	ID          uint16         `json:"id"` // Spacecraft ID in transfer frames
+	Seed        int64          `json:"seed,omitempty"`
+	Altitude    float64        `json:"altitude,omitempty"`
+	Inclination float64        `json:"inclination,omitempty"`
+	Beta        float64        `json:"beta,omitempty"`
+	CommandAPID uint16         `json:"command_apid,omitempty"` // APID of its telecommands; 0 accepts any
+	AckAPID     uint16         `json:"ack_apid,omitempty"`
+	Scenario    string         `json:"scenario,omitempty"`
+	Streams     []streamConfig `json:"streams"`
+
+	intervals []time.Duration
+}
+
+// fleetConfig is the JSON form of a fleet file
+type fleetConfig struct {
+	Spacecraft []spacecraftConfig `json:"spacecraft"`
+}
+
+// loadFleet reads and validates a fleet file
+func loadFleet(path string) ([]spacecraftConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg fleetConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(cfg.Spacecraft) == 0 {
+		return nil, fmt.Errorf("%s: no spacecraft", path)
+	}
+
+	commandAPIDs := make(map[uint16]string)
+	for i := range cfg.Spacecraft {
+		sc := &cfg.Spacecraft[i]
+		if sc.Name == "" {
+			sc.Name = fmt.Sprintf("SC-%d", sc.ID)
+		}
+		if err := sc.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, sc.Name, err)
+		}
+		if other, ok := commandAPIDs[sc.CommandAPID]; ok {
+			if sc.CommandAPID == 0 {
+				return nil, fmt.Errorf("%s: only one spacecraft may omit command_apid (%s and %s)", path, other, sc.Name)
+			}
+			return nil, fmt.Errorf("%s: %s and %s share command APID %d", path, other, sc.Name, sc.CommandAPID)
+		}
+		commandAPIDs[sc.CommandAPID] = sc.Name
+	}
+	return cfg.Spacecraft, nil
+}
+
+// singleSpacecraft describes the spacecraft configured by flags
+func singleSpacecraft() spacecraftConfig {
+	return spacecraftConfig{
+		Name:        "SC",
+		ID:          uint16(*spacecraftID),
+		Seed:        *seed,
+		Altitude:    *initialAltitude,
+		Inclination: *inclination,
+		Beta:        *betaAngle,
+		Scenario:    *scenarioFile,
+		Streams:     []streamConfig{{APID: APID, Subsystem: SUBSYSTEM_ID}},
+	}
+}
+
+// validate checks a spacecraft's settings, fills in defaults and parses
+// the stream intervals
+func (sc *spacecraftConfig) validate() error {
+	if sc.ID > 0x3FF {
+		return fmt.Errorf("spacecraft ID %d does not fit in 10 bits", sc.ID)
+	}
+	if sc.Altitude == 0 {
+		sc.Altitude = *initialAltitude
+	}
+	if sc.Inclination == 0 {
+		sc.Inclination = *inclination
+	}
+	if sc.Beta == 0 {
+		sc.Beta = *betaAngle
+	}
+	if sc.AckAPID == 0 {
+		sc.AckAPID = ACK_APID
+	}
+	if sc.AckAPID > ccsds.MaxAPID || sc.CommandAPID > ccsds.MaxAPID {
+		return fmt.Errorf("APIDs must fit in 11 bits")
+	}
+	if len(sc.Streams) == 0 {
+		return fmt.Errorf("no streams")
+	}
+
+	apids := make(map[uint16]bool)
+	sc.intervals = nil
+	for _, st := range sc.Streams {
+		switch {
+		case st.APID >= ccsds.IdleAPID:
+			return fmt.Errorf("stream APID %d is out of range", st.APID)
+		case st.APID == sc.AckAPID:
+			return fmt.Errorf("stream APID %d is used for acknowledgements", st.APID)
+		case apids[st.APID]:
+			return fmt.Errorf("duplicate stream APID %d", st.APID)
+		}
+		apids[st.APID] = true
+
+		interval := time.Second
+		if st.Interval != "" {
+			d, err := time.ParseDuration(st.Interval)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("stream APID %d: invalid interval %q", st.APID, st.Interval)
+			}
+			interval = d
+		}
+		sc.intervals = append(sc.intervals, interval)
+	}
+	return nil
+}
+
+// spacecraft is one simulated vehicle. Commands change its state, which
+// every one of its streams reports.
+type spacecraft struct {
+	cfg      spacecraftConfig
+	seed     int64
+	state    *spacecraftState
+	downlink *downlink
+	mu       sync.Mutex
+	acks     *ccsds.Encoder // Guarded by mu
+}
+
+// newSpacecraft creates a spacecraft sending through link. A scenario's seed
+// applies when the spacecraft has none.
+func newSpacecraft(cfg spacecraftConfig, link *impairedLink) (*spacecraft, error) {
+	if cfg.intervals == nil {
+		if err := cfg.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	s := cfg.Seed
+	if cfg.Scenario != "" {
+		script, err := loadScenario(cfg.Scenario)
+		if err != nil {
+			return nil, err
+		}
+		if s == 0 {
+			s = script.seed
+		}
+	}
+	if s == 0 {
+		// Derived from the run seed so the whole fleet repeats with it
+		s = runSeed
+		if *fleetFile != "" {
+			s += int64(cfg.ID)
+		}
+	}
+
+	return &spacecraft{
+		cfg:      cfg,
+		seed:     s,
+		state:    &spacecraftState{txInterval: cfg.intervals[0]},
+		acks:     &ccsds.Encoder{Type: ccsds.TypeTM, APID: cfg.AckAPID, CRC: *appendCRC},
+		downlink: newDownlink(link, cfg.ID),
+	}, nil
+}
+
+// run starts every stream of the spacecraft
+func (sc *spacecraft) run() {
+	log.Printf("Simulating %s (SCID %d, seed %d) with %d streams", sc.cfg.Name, sc.cfg.ID, sc.seed, len(sc.cfg.Streams))
+	for i := range sc.cfg.Streams {
+		go sc.stream(i)
+	}
+}
+
+// stream sends one packet type with its own sequence counter and model.
+// The first stream's rate follows SET_TX_RATE; the others keep their
+// configured interval.
+func (sc *spacecraft) stream(i int) {
+	st := sc.cfg.Streams[i]
+	name := fmt.Sprintf("%s APID %d", sc.cfg.Name, st.APID)
+
+	model, err := newSpacecraftModel(modelParams{
+		seed:        sc.seed,
+		noiseSeed:   sc.seed + int64(st.APID),
+		altitude:    sc.cfg.Altitude,
+		inclination: sc.cfg.Inclination,
+		beta:        sc.cfg.Beta,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Each stream plays its own copy of the scenario
+	var script *scenario
+	if sc.cfg.Scenario != "" {
+		script, err = loadScenario(sc.cfg.Scenario)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%s: playing scenario %q with %d events", name, script.name, len(script.faults))
+	}
+
+	enc := newTelemetryEncoder(st.APID)
+	var interval, elapsed time.Duration
+	for {
+		status := sc.state.status()
+		payload := model.step(interval, status)
+		elapsed += interval
+		transmit := true
+		if script != nil {
+			transmit = script.apply(elapsed, &payload)
+		}
+		packetCount := enc.SeqCount()
+		data := createTelemetryPacket(enc, st.Subsystem, payload, status)
+
+		// Packets produced while silent are lost, leaving a gap in the sequence count
+		if !transmit {
+			log.Printf("%s: suppressed telemetry packet #%d\n", name, packetCount)
+		} else if err := sc.downlink.send(data); err != nil {
+			log.Printf("%s: error sending telemetry: %v", name, err)
+		} else {
+			log.Printf("%s: sent telemetry packet #%d: %.1f C, %.1f%%, %.1f km, %.1f dB\n",
+				name, packetCount, payload.Temperature, payload.Battery, payload.Altitude, payload.Signal)
+		}
+
+		interval = sc.cfg.intervals[i]
+		if i == 0 {
+			interval = sc.state.nextInterval()
+		}
+		time.Sleep(interval)
+	}
+}
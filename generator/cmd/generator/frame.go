@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/rand"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+const (
+	frameHeaderSize = 6
+	frameFECFSize   = 2
+	fhpNoStart      = 0x7FF // No packet starts in the frame's data field
+)
+
+// minIdleSize is the length of the shortest idle packet: a primary header
+// and one data byte
+const minIdleSize = ccsds.PrimaryHeaderSize + 1
+
+// frameBuilder wraps packets in fixed-length TM Transfer Frames. Each packet
+// starts a new frame and may continue over as many frames as it needs; an
+// idle packet fills the rest of the last one. When too little room is left
+// for an idle header the idle packet continues into the next frame.
+type frameBuilder struct {
+	spacecraftID uint16
+	length       int
+	count        uint8
+	carry        []byte // End of an idle packet that starts the next frame
+}
+
+// build returns the frames carrying packet
+func (b *frameBuilder) build(packet []byte) [][]byte {
+	size := b.length - frameHeaderSize - frameFECFSize
+	stream := append(append([]byte(nil), b.carry...), packet...)
+	starts := []int{len(b.carry)} // Offsets in stream where a packet begins
+	b.carry = nil
+
+	// Fill the last frame with an idle packet, splitting it if the room left
+	// is too short to hold its header
+	if rem := (size - len(stream)%size) % size; rem > 0 {
+		idleSize := rem
+		if idleSize < minIdleSize {
+			idleSize = minIdleSize
+		}
+		idle := idlePacket(idleSize)
+		starts = append(starts, len(stream))
+		stream = append(stream, idle[:rem]...)
+		b.carry = idle[rem:]
+	}
+
+	var frames [][]byte
+	for offset := 0; offset < len(stream); offset += size {
+		fhp := fhpNoStart
+		for _, start := range starts {
+			if start >= offset && start < offset+size {
+				fhp = start - offset
+				break
+			}
+		}
+		frames = append(frames, b.frame(stream[offset:offset+size], fhp))
+	}
+	return frames
+}
+
+// frame wraps one data field in a transfer frame
+func (b *frameBuilder) frame(data []byte, fhp int) []byte {
+	frame := make([]byte, b.length)
+
+	// Version 0, spacecraft ID, virtual channel 0, no OCF
+	binary.BigEndian.PutUint16(frame[0:2], (b.spacecraftID&0x03FF)<<4)
+	frame[2] = b.count // Master channel frame count
+	frame[3] = b.count // Virtual channel frame count
+	b.count++
+	// Packet order and segment length ID 0b11, then the first header pointer
+	binary.BigEndian.PutUint16(frame[4:6], 0x1800|uint16(fhp))
+
+	copy(frame[frameHeaderSize:], data)
+	binary.BigEndian.PutUint16(frame[b.length-frameFECFSize:], ccsds.CRC16CCITT(frame[:b.length-frameFECFSize]))
+	return frame
+}
+
+// idlePacket returns an idle packet of size bytes, at least minIdleSize
+func idlePacket(size int) []byte {
+	buf := ccsds.NewPrimaryHeader(ccsds.TypeTM, false, ccsds.IdleAPID, ccsds.SeqFlagStandalone, 0, size-ccsds.PrimaryHeaderSize).
+		Append(make([]byte, 0, size))
+	for len(buf) < size {
+		buf = append(buf, 0x55)
+	}
+	return buf
+}
+
+// cadu encodes a frame into a channel access data unit, corrupting
+// symbolErrors symbols of the codeblock drawn from rng to simulate link noise
+func cadu(frame []byte, depth, symbolErrors int, rng *rand.Rand) []byte {
+	block := ccsds.RSEncodeBlock(frame, depth)
+	for i := 0; i < symbolErrors; i++ {
+		block[rng.Intn(len(block))] ^= byte(rng.Intn(255) + 1)
+	}
+	return append(append([]byte(nil), ccsds.ASM...), block...)
+}
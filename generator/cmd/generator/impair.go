@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Link impairments applied to every datagram on its way to the ground.
+// Probabilities are per datagram.
+var (
+	lossRate      = flag.Float64("loss", 0, "probability of losing a datagram")
+	burstEnter    = flag.Float64("burst-enter", 0, "Gilbert-Elliott probability of entering the bad state per datagram")
+	burstExit     = flag.Float64("burst-exit", 0.3, "Gilbert-Elliott probability of leaving the bad state per datagram")
+	burstLoss     = flag.Float64("burst-loss", 1, "probability of losing a datagram in the bad state")
+	duplicateRate = flag.Float64("duplicate", 0, "probability of sending a datagram twice")
+	reorderRate   = flag.Float64("reorder", 0, "probability of delaying a datagram past later ones")
+	reorderDelay  = flag.Duration("reorder-delay", 3*time.Second, "longest delay of a reordered datagram")
+	corruptRate   = flag.Float64("corrupt", 0, "probability of flipping bits in a datagram")
+	corruptBits   = flag.Int("corrupt-bits", 1, "bits flipped in a corrupted datagram")
+	truncateRate  = flag.Float64("truncate", 0, "probability of cutting a datagram short")
+	impairStats   = flag.String("impair-stats", "", "file the ground-truth impairment counts are written to as JSON")
+)
+
+// impairmentStats are the ground-truth counts of what the link did
+type impairmentStats struct {
+	Offered     uint64 `json:"offered"`   // Datagrams handed to the link
+	Delivered   uint64 `json:"delivered"` // Datagrams written, duplicates included
+	Lost        uint64 `json:"lost"`      // Lost at random
+	BurstLost   uint64 `json:"burst_lost"`
+	Duplicated  uint64 `json:"duplicated"`
+	Reordered   uint64 `json:"reordered"`
+	Corrupted   uint64 `json:"corrupted"`
+	BitsFlipped uint64 `json:"bits_flipped"`
+	Truncated   uint64 `json:"truncated"`
+}
+
+// impairedLink writes datagrams to the ground through the impairment stages
+type impairedLink struct {
+	conn  net.Conn
+	seed  int64
+	mu    sync.Mutex
+	rng   *rand.Rand
+	bad   bool // Gilbert-Elliott channel state
+	stats impairmentStats
+}
+
+// newImpairedLink checks the impairment flags and creates a link over conn
+// whose random draws come from seed, so impairments repeat with the run seed
+func newImpairedLink(conn net.Conn, seed int64) (*impairedLink, error) {
+	rates := map[string]float64{
+		"loss":        *lossRate,
+		"burst-enter": *burstEnter,
+		"burst-exit":  *burstExit,
+		"burst-loss":  *burstLoss,
+		"duplicate":   *duplicateRate,
+		"reorder":     *reorderRate,
+		"corrupt":     *corruptRate,
+		"truncate":    *truncateRate,
+	}
+	for name, rate := range rates {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("-%s must be between 0 and 1", name)
+		}
+	}
+	if *corruptBits < 1 {
+		return nil, fmt.Errorf("-corrupt-bits must be at least 1")
+	}
+	if *reorderDelay <= 0 {
+		return nil, fmt.Errorf("-reorder-delay must be positive")
+	}
+
+	return &impairedLink{conn: conn, seed: seed, rng: rand.New(rand.NewSource(seed))}, nil
+}
+
+// write passes one datagram through loss, truncation, corruption,
+// duplication and reordering. Lost datagrams are not an error.
+func (l *impairedLink) write(data []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stats.Offered++
+
+	// Bursty loss: a two-state Markov channel
+	if l.bad {
+		l.bad = l.rng.Float64() >= *burstExit
+	} else {
+		l.bad = l.rng.Float64() < *burstEnter
+	}
+	if l.bad && l.rng.Float64() < *burstLoss {
+		l.stats.BurstLost++
+		return nil
+	}
+	if l.rng.Float64() < *lossRate {
+		l.stats.Lost++
+		return nil
+	}
+
+	data = append([]byte(nil), data...)
+	if len(data) > 1 && l.rng.Float64() < *truncateRate {
+		data = data[:1+l.rng.Intn(len(data)-1)]
+		l.stats.Truncated++
+	}
+	if l.rng.Float64() < *corruptRate {
+		for i := 0; i < *corruptBits; i++ {
+			bit := l.rng.Intn(len(data) * 8)
+			data[bit/8] ^= 1 << (bit % 8)
+		}
+		l.stats.Corrupted++
+		l.stats.BitsFlipped += uint64(*corruptBits)
+	}
+
+	copies := 1
+	if l.rng.Float64() < *duplicateRate {
+		copies = 2
+		l.stats.Duplicated++
+	}
+
+	var err error
+	for i := 0; i < copies; i++ {
+		if l.rng.Float64() < *reorderRate {
+			// Held back so that later datagrams overtake it
+			delay := time.Duration(1 + l.rng.Int63n(int64(*reorderDelay)))
+			l.stats.Reordered++
+			time.AfterFunc(delay, func() { l.deliver(data) })
+			continue
+		}
+		if _, werr := l.conn.Write(data); werr != nil {
+			err = werr
+			continue
+		}
+		l.stats.Delivered++
+	}
+	return err
+}
+
+// deliver writes a delayed datagram
+func (l *impairedLink) deliver(data []byte) {
+	if _, err := l.conn.Write(data); err != nil {
+		log.Printf("Error sending delayed datagram: %v", err)
+		return
+	}
+	l.mu.Lock()
+	l.stats.Delivered++
+	l.mu.Unlock()
+}
+
+// snapshot returns the counts so far
+func (l *impairedLink) snapshot() impairmentStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// report logs the counts and writes them to the -impair-stats file
+func (l *impairedLink) report() {
+	stats := l.snapshot()
+	log.Printf("Link: %d offered, %d delivered, %d lost, %d burst lost, %d duplicated, %d reordered, %d corrupted, %d truncated",
+		stats.Offered, stats.Delivered, stats.Lost, stats.BurstLost, stats.Duplicated,
+		stats.Reordered, stats.Corrupted, stats.Truncated)
+
+	if *impairStats == "" {
+		return
+	}
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		log.Printf("Error encoding impairment stats: %v", err)
+		return
+	}
+
+	// Written through a rename so readers never see a partial file
+	tmp := *impairStats + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		log.Printf("Error writing impairment stats: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, *impairStats); err != nil {
+		log.Printf("Error writing impairment stats: %v", err)
+	}
+}
+
+// impaired reports whether any impairment is enabled
+func impaired() bool {
+	return *lossRate > 0 || *burstEnter > 0 || *duplicateRate > 0 || *reorderRate > 0 ||
+		*corruptRate > 0 || *truncateRate > 0
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"math/bits"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// update rewrites the impaired capture the backend's frame tests check
+var update = flag.Bool("update", false, "rewrite the impaired frame capture in the backend's testdata")
+
+// impairedCapture is the capture, relative to this package, that the
+// backend's demultiplexer tests compare with its ground-truth counts
+const impairedCapture = "../../../backend/internal/telemetry/frames/testdata/impaired_frames"
+
+// captureConn records the datagrams written to it
+type captureConn struct {
+	net.Conn
+	mu        sync.Mutex
+	datagrams [][]byte
+}
+
+func (c *captureConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.datagrams = append(c.datagrams, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (c *captureConn) written() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.datagrams
+}
+
+// setFlag changes a flag value until the test ends
+func setFlag[T any](t *testing.T, flag *T, value T) {
+	old := *flag
+	*flag = value
+	t.Cleanup(func() { *flag = old })
+}
+
+// newTestLink creates a link with the given seed that records what it sends
+func newTestLink(t *testing.T, s int64) (*impairedLink, *captureConn) {
+	t.Helper()
+	conn := &captureConn{}
+	link, err := newImpairedLink(conn, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return link, conn
+}
+
+// datagram is long enough to corrupt and truncate
+var datagram = []byte("a datagram long enough to corrupt and truncate")
+
+// sendAll writes n copies of datagram and returns the link's counts
+func sendAll(t *testing.T, link *impairedLink, n int) impairmentStats {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := link.write(datagram); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return link.snapshot()
+}
+
+func TestImpairedLinkRejectsBadFlags(t *testing.T) {
+	for name, set := range map[string]func(t *testing.T){
+		"loss above one":     func(t *testing.T) { setFlag(t, lossRate, 1.5) },
+		"negative duplicate": func(t *testing.T) { setFlag(t, duplicateRate, -0.1) },
+		"no corrupt bits":    func(t *testing.T) { setFlag(t, corruptBits, 0) },
+		"no reorder delay":   func(t *testing.T) { setFlag(t, reorderDelay, 0) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			set(t)
+			if _, err := newImpairedLink(&captureConn{}, 1); err == nil {
+				t.Fatal("link created")
+			}
+		})
+	}
+}
+
+func TestImpairedLinkClean(t *testing.T) {
+	link, conn := newTestLink(t, 7)
+	if stats := sendAll(t, link, 50); stats != (impairmentStats{Offered: 50, Delivered: 50}) {
+		t.Fatalf("stats %+v", stats)
+	}
+	for _, d := range conn.written() {
+		if !bytes.Equal(d, datagram) {
+			t.Fatalf("datagram changed to %q", d)
+		}
+	}
+}
+
+func TestImpairedLinkLoss(t *testing.T) {
+	setFlag(t, lossRate, 1)
+	link, conn := newTestLink(t, 7)
+	if stats := sendAll(t, link, 50); stats != (impairmentStats{Offered: 50, Lost: 50}) {
+		t.Errorf("random loss: stats %+v", stats)
+	}
+
+	// A channel that enters the bad state and never leaves loses everything
+	setFlag(t, lossRate, 0)
+	setFlag(t, burstEnter, 1)
+	setFlag(t, burstExit, 0)
+	link, conn = newTestLink(t, 7)
+	if stats := sendAll(t, link, 50); stats != (impairmentStats{Offered: 50, BurstLost: 50}) {
+		t.Errorf("burst loss: stats %+v", stats)
+	}
+	if len(conn.written()) != 0 {
+		t.Errorf("%d datagrams sent over a lossy link", len(conn.written()))
+	}
+}
+
+func TestImpairedLinkDuplication(t *testing.T) {
+	setFlag(t, duplicateRate, 1)
+	link, conn := newTestLink(t, 7)
+	if stats := sendAll(t, link, 50); stats != (impairmentStats{Offered: 50, Delivered: 100, Duplicated: 50}) {
+		t.Errorf("stats %+v", stats)
+	}
+	if len(conn.written()) != 100 {
+		t.Errorf("%d datagrams sent, want 100", len(conn.written()))
+	}
+}
+
+func TestImpairedLinkReordering(t *testing.T) {
+	setFlag(t, reorderRate, 1)
+	setFlag(t, reorderDelay, time.Millisecond)
+	link, conn := newTestLink(t, 7)
+	sendAll(t, link, 50)
+
+	// Held datagrams are delivered once their delay has passed
+	deadline := time.Now().Add(time.Second)
+	for link.snapshot().Delivered < 50 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if stats := link.snapshot(); stats != (impairmentStats{Offered: 50, Delivered: 50, Reordered: 50}) {
+		t.Errorf("stats %+v", stats)
+	}
+	if len(conn.written()) != 50 {
+		t.Errorf("%d datagrams sent, want 50", len(conn.written()))
+	}
+}
+
+func TestImpairedLinkCorruption(t *testing.T) {
+	setFlag(t, corruptRate, 1)
+	setFlag(t, corruptBits, 3)
+	link, conn := newTestLink(t, 7)
+	if stats := sendAll(t, link, 50); stats != (impairmentStats{Offered: 50, Delivered: 50, Corrupted: 50, BitsFlipped: 150}) {
+		t.Errorf("stats %+v", stats)
+	}
+
+	for _, d := range conn.written() {
+		// A bit flipped twice is restored, leaving one flip
+		flipped := 0
+		for i := range d {
+			flipped += bits.OnesCount8(d[i] ^ datagram[i])
+		}
+		if flipped != 1 && flipped != 3 {
+			t.Fatalf("%d bits flipped, want 1 or 3", flipped)
+		}
+	}
+}
+
+func TestImpairedLinkTruncation(t *testing.T) {
+	setFlag(t, truncateRate, 1)
+	link, conn := newTestLink(t, 7)
+	if stats := sendAll(t, link, 50); stats != (impairmentStats{Offered: 50, Delivered: 50, Truncated: 50}) {
+		t.Errorf("stats %+v", stats)
+	}
+	for _, d := range conn.written() {
+		if len(d) == 0 || len(d) >= len(datagram) || !bytes.HasPrefix(datagram, d) {
+			t.Fatalf("datagram of %d bytes is not a prefix of the %d sent", len(d), len(datagram))
+		}
+	}
+}
+
+func TestImpairedLinkRepeatsWithSeed(t *testing.T) {
+	setFlag(t, lossRate, 0.2)
+	setFlag(t, burstEnter, 0.05)
+	setFlag(t, duplicateRate, 0.1)
+	setFlag(t, corruptRate, 0.1)
+	setFlag(t, truncateRate, 0.1)
+
+	run := func(s int64) [][]byte {
+		link, conn := newTestLink(t, s)
+		for i := 0; i < 200; i++ {
+			link.write([]byte{byte(i), byte(i >> 8), 0xAA, 0x55})
+		}
+		return conn.written()
+	}
+
+	a, b, c := run(11), run(11), run(12)
+	if !equalDatagrams(a, b) {
+		t.Error("the same seed gave different impairments")
+	}
+	if equalDatagrams(a, c) {
+		t.Error("different seeds gave the same impairments")
+	}
+}
+
+// equalDatagrams reports whether two captures hold the same datagrams
+func equalDatagrams(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// TestImpairedFrameCapture checks that the capture in the backend's
+// testdata is what the impaired link produces today, so the backend's frame
+// loss and FECF counts are checked against real ground truth. Run with
+// -update after changing the link or the frame layout.
+func TestImpairedFrameCapture(t *testing.T) {
+	capture, stats := impairFrames(t)
+
+	if *update {
+		if err := os.WriteFile(impairedCapture+".bin", capture, 0644); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.MarshalIndent(stats, "", "  ")
+		if err := os.WriteFile(impairedCapture+".json", append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(impairedCapture + ".bin")
+	if err != nil {
+		t.Fatalf("%v; run with -update to create it", err)
+	}
+	if !bytes.Equal(capture, want) {
+		t.Fatalf("%s is out of date; run with -update", filepath.Base(impairedCapture+".bin"))
+	}
+	var wantStats impairmentStats
+	data, err := os.ReadFile(impairedCapture + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &wantStats); err != nil {
+		t.Fatal(err)
+	}
+	if stats != wantStats {
+		t.Fatalf("ground truth %+v, capture file has %+v", stats, wantStats)
+	}
+}
+
+// impairFrames sends one telemetry packet per transfer frame through a
+// lossy, corrupting link and returns the datagrams, each preceded by its
+// length as two big-endian bytes, with the link's counts. The first and last
+// frames pass unimpaired so that every loss lies between two received frames.
+func impairFrames(t *testing.T) ([]byte, impairmentStats) {
+	t.Helper()
+	const frameCount = 200
+
+	enc := &ccsds.Encoder{Type: ccsds.TypeTM, APID: 16, SecondaryHeader: true, CRC: true}
+	builder := &frameBuilder{spacecraftID: 42, length: ccsds.RSDataSize}
+	var frames [][]byte
+	for i := 0; len(frames) < frameCount; i++ {
+		header := ccsds.SecondaryHeader{Timestamp: 1700000000 + uint64(i), SubsystemID: SUBSYSTEM_ID}
+		payload := ccsds.TelemetryPayload{Temperature: 25, Battery: 90 - float32(i)/10, Altitude: 520, Signal: -50}
+		frames = append(frames, builder.build(encodeTelemetry(enc, header, payload, nil))...)
+	}
+
+	impair := func(loss, burst, corrupt float64) {
+		setFlag(t, lossRate, loss)
+		setFlag(t, burstEnter, burst)
+		setFlag(t, corruptRate, corrupt)
+	}
+	link, conn := newTestLink(t, 42)
+	for i, frame := range frames {
+		switch i {
+		case 1:
+			impair(0.05, 0.01, 0.05)
+		case len(frames) - 1:
+			impair(0, 0, 0)
+		}
+		if err := link.write(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var capture []byte
+	for _, d := range conn.written() {
+		capture = binary.BigEndian.AppendUint16(capture, uint16(len(d)))
+		capture = append(capture, d...)
+	}
+	return capture, link.snapshot()
+}
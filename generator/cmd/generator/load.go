@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// Load test modes
+const (
+	LOAD_RATE = "rate" // Send at a constant target rate
+	LOAD_RAMP = "ramp" // Ramp linearly up to the target rate, then hold it
+	LOAD_MAX  = "max"  // Send as fast as the sockets allow
+)
+
+// Load test settings. Each worker sends on its own socket and APID, so
+// arrivals can be matched to the packet that caused them.
+var (
+	loadMode     = flag.String("load", "", "load test mode: rate, ramp or max (off when empty)")
+	loadRate     = flag.Float64("rate", 1000, "target send rate in packets per second")
+	loadRamp     = flag.Duration("ramp", 30*time.Second, "time to reach the target rate in ramp mode")
+	loadDuration = flag.Duration("duration", time.Minute, "length of the load test")
+	loadWorkers  = flag.Int("workers", 1, "sending goroutines, each with its own socket")
+	loadAPID     = flag.Uint("load-apid", 0x100, "APID of the first worker; worker n sends on this plus n")
+	loadDrain    = flag.Duration("drain", 5*time.Second, "time to wait for late arrivals after sending stops")
+	feedURL      = flag.String("feed", "ws://localhost:3000/ws/telemetry", "backend WebSocket feed used to measure latency (empty disables)")
+)
+
+// loadTest sends packets at a controlled rate and measures how long the
+// backend takes to publish each one on its WebSocket feed. A packet's
+// latency can only be measured if it arrives before its APID wraps.
+type loadTest struct {
+	start    time.Time
+	sentAt   [][]int64 // Send time in Unix nanoseconds by worker and sequence count; 0 once matched
+	sent     atomic.Uint64
+	errors   atomic.Uint64
+	received atomic.Uint64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+}
+
+// feedRecord is the part of a published telemetry record the load test needs
+type feedRecord struct {
+	APID          uint16
+	SequenceCount uint16
+}
+
+// runLoad runs a load test and prints its results
+func runLoad() {
+	switch *loadMode {
+	case LOAD_RATE, LOAD_RAMP, LOAD_MAX:
+	default:
+		log.Fatalf("Unknown load mode %q", *loadMode)
+	}
+	if *loadWorkers < 1 || *loadAPID+uint(*loadWorkers) > ccsds.IdleAPID {
+		log.Fatalf("Workers must be at least 1 and their APIDs below %d", ccsds.IdleAPID)
+	}
+	if *loadMode != LOAD_MAX && *loadRate <= 0 {
+		log.Fatalf("Rate must be positive")
+	}
+
+	l := &loadTest{sentAt: make([][]int64, *loadWorkers)}
+	for i := range l.sentAt {
+		l.sentAt[i] = make([]int64, ccsds.SeqCountRange)
+	}
+
+	if *feedURL != "" {
+		feed, err := dialWebSocket(*feedURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to %s: %v", *feedURL, err)
+		}
+		defer feed.Close()
+		go l.follow(feed)
+	} else {
+		log.Printf("No feed configured; latency will not be measured")
+	}
+
+	log.Printf("Starting %s load test for %s with %d workers", *loadMode, *loadDuration, *loadWorkers)
+	l.start = time.Now()
+
+	var wg sync.WaitGroup
+	for w := 0; w < *loadWorkers; w++ {
+		conn, err := net.Dial("udp", *target)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer conn.Close()
+
+		// Each worker's link draws from its own seed derived from the run's
+		link, err := newImpairedLink(conn, runSeed+1+int64(w)<<16)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dl := newDownlink(link, uint16(*spacecraftID))
+
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			l.send(w, dl)
+		}(w)
+	}
+
+	done := make(chan struct{})
+	go l.report(done)
+	wg.Wait()
+
+	if *feedURL != "" {
+		time.Sleep(*loadDrain)
+	}
+	close(done)
+	l.summarise()
+}
+
+// target returns the number of packets that should have been sent after elapsed
+func (l *loadTest) target(elapsed time.Duration) float64 {
+	t := elapsed.Seconds()
+	if *loadMode == LOAD_RAMP {
+		ramp := loadRamp.Seconds()
+		if t < ramp {
+			return *loadRate * t * t / (2 * ramp)
+		}
+		return *loadRate * (ramp/2 + t - ramp)
+	}
+	return *loadRate * t
+}
+
+// send paces one worker's packets until the test ends
+func (l *loadTest) send(w int, dl *downlink) {
+	enc := newTelemetryEncoder(uint16(*loadAPID) + uint16(w))
+	payload := ccsds.TelemetryPayload{Temperature: 25, Battery: 90, Altitude: 520, Signal: -50}
+	status := ccsds.SpacecraftStatus{TxIntervalMs: 1000}
+
+	var count int64
+	for {
+		elapsed := time.Since(l.start)
+		if elapsed >= *loadDuration {
+			return
+		}
+
+		// Packets are sent in bursts to catch up with the schedule, since
+		// sleeping is far coarser than the interval between packets
+		due := int64(64)
+		if *loadMode != LOAD_MAX {
+			due = int64(l.target(elapsed)/float64(*loadWorkers)) - count
+			if due <= 0 {
+				time.Sleep(200 * time.Microsecond)
+				continue
+			}
+		}
+
+		for i := int64(0); i < due; i++ {
+			seq := enc.SeqCount()
+			data := createTelemetryPacket(enc, SUBSYSTEM_ID, payload, status)
+			atomic.StoreInt64(&l.sentAt[w][seq], time.Now().UnixNano())
+			if err := dl.send(data); err != nil {
+				l.errors.Add(1)
+			}
+			l.sent.Add(1)
+		}
+		count += due
+	}
+}
+
+// follow matches records published on the feed to the packets that caused them
+func (l *loadTest) follow(feed *wsClient) {
+	base := uint16(*loadAPID)
+	for {
+		message, err := feed.read()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Feed closed: %v", err)
+			}
+			return
+		}
+		now := time.Now().UnixNano()
+
+		var record feedRecord
+		if err := json.Unmarshal(message, &record); err != nil {
+			continue
+		}
+		w := int(record.APID) - int(base)
+		if w < 0 || w >= len(l.sentAt) {
+			continue
+		}
+
+		// Swapping the slot to zero keeps duplicates from being counted twice
+		sent := atomic.SwapInt64(&l.sentAt[w][record.SequenceCount&ccsds.MaxSeqCount], 0)
+		if sent == 0 {
+			continue
+		}
+		l.received.Add(1)
+		l.mu.Lock()
+		l.latencies = append(l.latencies, time.Duration(now-sent))
+		l.mu.Unlock()
+	}
+}
+
+// report logs the achieved rates every second
+func (l *loadTest) report(done chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var lastSent, lastReceived uint64
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			sent, received := l.sent.Load(), l.received.Load()
+			elapsed := now.Sub(l.start)
+			target := "max"
+			if elapsed >= *loadDuration {
+				target = "0"
+			} else if *loadMode != LOAD_MAX {
+				previous := elapsed - time.Second
+				if previous < 0 {
+					previous = 0
+				}
+				target = fmt.Sprintf("%.0f", l.target(elapsed)-l.target(previous))
+			}
+			log.Printf("Load: sent %d pkt/s (target %s), received %d pkt/s, %d send errors",
+				sent-lastSent, target, received-lastReceived, l.errors.Load())
+			lastSent, lastReceived = sent, received
+		}
+	}
+}
+
+// summarise logs the achieved rate and latency percentiles
+func (l *loadTest) summarise() {
+	sent := l.sent.Load()
+	seconds := math.Min(time.Since(l.start).Seconds(), loadDuration.Seconds())
+	log.Printf("Sent %d packets in %.1fs: %.0f pkt/s, %d send errors", sent, seconds, float64(sent)/seconds, l.errors.Load())
+	if *feedURL == "" {
+		return
+	}
+
+	l.mu.Lock()
+	latencies := append([]time.Duration(nil), l.latencies...)
+	l.mu.Unlock()
+
+	received := uint64(len(latencies))
+	lost := 0.0
+	if sent > 0 {
+		lost = 100 * float64(sent-received) / float64(sent)
+	}
+	log.Printf("Received %d on the feed (%.2f%% missing)", received, lost)
+	if received == 0 {
+		return
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return latencies[int(math.Ceil(p/100*float64(len(latencies))))-1]
+	}
+	log.Printf("Latency: p50 %s, p90 %s, p99 %s, p99.9 %s, max %s",
+		percentile(50), percentile(90), percentile(99), percentile(99.9), latencies[len(latencies)-1])
+}
@@ -1,156 +1,160 @@
 package main
 
 import (
-	"bytes"
-	"encoding/binary"
+	"flag"
 	"log"
-	"math/rand"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
-)
 
-// CCSDS Primary Header (6 bytes)
-type CCSDSPrimaryHeader struct {
-	PacketID      uint16 // Version(3 bits), Type(1 bit), SecHdrFlag(1 bit), APID(11 bits)
-	PacketSeqCtrl uint16 // SeqFlags(2 bits), SeqCount(14 bits)
-	PacketLength  uint16 // Total packet length minus 7
-}
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
 
-// CCSDS Secondary Header (10 bytes)
-type CCSDSSecondaryHeader struct {
-	Timestamp   uint64 // Unix timestamp
-	SubsystemID uint16 // Identifies the subsystem (e.g., power, thermal)
-}
+const (
+	APID         = 0x01
+	SUBSYSTEM_ID = 0x0001 // Main bus telemetry
+)
 
-// Telemetry Payload
-type TelemetryPayload struct {
-	Temperature float32 // Temperature in Celsius
-	Battery     float32 // Battery percentage
-	Altitude    float32 // Altitude in kilometers
-	Signal      float32 // Signal strength in dB
-}
+// appendCRC adds a packet error control field to every packet when set
+var appendCRC = flag.Bool("crc", false, "append a CRC-16-CCITT packet error control field")
 
-const (
-	APID           = 0x01
-	PACKET_VERSION = 0x0
-	PACKET_TYPE    = 0x0    // 0 = TM (telemetry)
-	SEC_HDR_FLAG   = 0x1    // Secondary header present
-	SEQ_FLAGS      = 0x3    // Standalone packet
-	SUBSYSTEM_ID   = 0x0001 // Main bus telemetry
+// Downlink framing: bare packets, TM Transfer Frames, or Reed-Solomon coded
+// frames preceded by sync markers
+var (
+	mode         = flag.String("mode", "packet", "downlink format: packet, frame or cadu")
+	spacecraftID = flag.Uint("scid", 0, "spacecraft ID written into transfer frames")
+	interleave   = flag.Int("interleave", 1, "Reed-Solomon interleaving depth in cadu mode")
+	symbolErrors = flag.Int("symbol-errors", 0, "random symbol errors injected into each codeblock in cadu mode")
 )
 
+// hmacKey authenticates every datagram with an HMAC-SHA256 trailer when set
+var hmacKey = flag.String("hmac-key", "", "shared key for an HMAC-SHA256 datagram trailer")
+
+// target is the backend address telemetry is sent to
+var target = flag.String("target", "localhost:8089", "UDP address of the backend's telemetry listener")
+
+// uplinkAddr is where the simulator listens for telecommands
+var uplinkAddr = flag.String("uplink", ":8090", "UDP address to receive telecommands on")
+
 func main() {
-	conn, err := net.Dial("udp", "localhost:8089")
+	flag.Parse()
+	if *mode != "packet" && *mode != "frame" && *mode != "cadu" {
+		log.Fatalf("Unknown mode %q", *mode)
+	}
+	if *interleave < 1 || *interleave > 8 {
+		log.Fatalf("Interleaving depth must be between 1 and 8")
+	}
+
+	if *replayFile != "" && (*loadMode != "" || *fleetFile != "") {
+		log.Fatalf("Replay cannot be combined with a load test or fleet")
+	}
+
+	// Every random draw of the run derives from this seed
+	runSeed = resolveSeed(*seed)
+	log.Printf("Run seed %d", runSeed)
+
+	if *loadMode != "" {
+		runLoad()
+		return
+	}
+
+	conn, err := net.Dial("udp", *target)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer conn.Close()
 
-	packetCount := uint16(0)
-	for {
-		data := createTelemetryPacket(&packetCount)
-
-		_, err := conn.Write(data)
+	configs := []spacecraftConfig{singleSpacecraft()}
+	if *fleetFile != "" {
+		configs, err = loadFleet(*fleetFile)
 		if err != nil {
-			log.Printf("Error sending telemetry: %v", err)
-			continue
+			log.Fatal(err)
 		}
-
-		if packetCount%5 == 0 {
-			log.Printf("Sent anomalous telemetry packet #%d\n", packetCount)
-		} else {
-			log.Printf("Sent normal telemetry packet #%d\n", packetCount)
-		}
-
-		time.Sleep(1 * time.Second)
-		packetCount++
 	}
-}
-
-func createTelemetryPacket(seqCount *uint16) []byte {
-	buf := new(bytes.Buffer)
-
-	// Create primary header
-	// PacketID: Version(3) | Type(1) | SecHdrFlag(1) | APID(11)
-	packetID := uint16(PACKET_VERSION)<<13 |
-		uint16(PACKET_TYPE)<<12 |
-		uint16(SEC_HDR_FLAG)<<11 |
-		uint16(APID)
-
-	// PacketSeqCtrl: SeqFlags(2) | SeqCount(14)
-	packetSeqCtrl := uint16(SEQ_FLAGS)<<14 | (*seqCount & 0x3FFF)
+	// Packets do not say which spacecraft sent them, so the backend could not
+	// tell the fleet's telemetry and acknowledgements apart
+	if len(configs) > 1 && *mode == "packet" {
+		log.Fatalf("A fleet of %d spacecraft needs -mode frame or cadu", len(configs))
+	}
 
-	// Generate telemetry data
-	payload := generateTelemetryPayload(*seqCount%5 == 0)
+	link, err := newImpairedLink(conn, runSeed+1)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if impaired() || *impairStats != "" {
+		go reportLink(link)
+	}
 
-	// Calculate total packet length (excluding primary header first 6 bytes)
-	packetDataLength := uint16(binary.Size(CCSDSSecondaryHeader{}) +
-		binary.Size(TelemetryPayload{}) - 1)
+	if *replayFile != "" {
+		runReplay(link)
+		if impaired() || *impairStats != "" {
+			// Datagrams held back for reordering are still in flight
+			if *reorderRate > 0 {
+				time.Sleep(*reorderDelay)
+			}
+			link.report()
+		}
+		return
+	}
 
-	primaryHeader := CCSDSPrimaryHeader{
-		PacketID:      packetID,
-		PacketSeqCtrl: packetSeqCtrl,
-		PacketLength:  packetDataLength,
+	fleet := make([]*spacecraft, 0, len(configs))
+	for _, cfg := range configs {
+		sc, err := newSpacecraft(cfg, link)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fleet = append(fleet, sc)
 	}
 
-	// Create secondary header
-	secondaryHeader := CCSDSSecondaryHeader{
-		Timestamp:   uint64(time.Now().Unix()),
-		SubsystemID: SUBSYSTEM_ID,
+	// Telecommands change the state reported in later packets
+	go newCommandReceiver(fleet).listen(*uplinkAddr)
+	for _, sc := range fleet {
+		sc.run()
 	}
+	select {}
+}
 
-	// Write headers and payload
-	binary.Write(buf, binary.BigEndian, primaryHeader) // CCSDS uses big-endian
-	binary.Write(buf, binary.BigEndian, secondaryHeader)
-	binary.Write(buf, binary.BigEndian, payload)
+// reportLink reports the impairment counts periodically and once more on exit
+func reportLink(link *impairedLink) {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
-	return buf.Bytes()
-}
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
 
-func generateTelemetryPayload(generateAnomaly bool) TelemetryPayload {
-	if generateAnomaly {
-		// Randomly choose one parameter to be anomalous
-		anomalyType := rand.Intn(4)
-		switch anomalyType {
-		case 0:
-			return TelemetryPayload{
-				Temperature: randomFloat(35.0, 40.0), // High temperature anomaly
-				Battery:     randomFloat(70.0, 100.0),
-				Altitude:    randomFloat(500.0, 550.0),
-				Signal:      randomFloat(-60.0, -40.0),
-			}
-		case 1:
-			return TelemetryPayload{
-				Temperature: randomFloat(20.0, 30.0),
-				Battery:     randomFloat(20.0, 40.0), // Low battery anomaly
-				Altitude:    randomFloat(500.0, 550.0),
-				Signal:      randomFloat(-60.0, -40.0),
-			}
-		case 2:
-			return TelemetryPayload{
-				Temperature: randomFloat(20.0, 30.0),
-				Battery:     randomFloat(70.0, 100.0),
-				Altitude:    randomFloat(300.0, 400.0), // Low altitude anomaly
-				Signal:      randomFloat(-60.0, -40.0),
-			}
-		default:
-			return TelemetryPayload{
-				Temperature: randomFloat(20.0, 30.0),
-				Battery:     randomFloat(70.0, 100.0),
-				Altitude:    randomFloat(500.0, 550.0),
-				Signal:      randomFloat(-90.0, -80.0), // Weak signal anomaly
-			}
+	for {
+		select {
+		case <-ticker.C:
+			link.report()
+		case <-stop:
+			link.report()
+			os.Exit(0)
 		}
 	}
+}
+
+// createTelemetryPacket encodes one telemetry packet with enc, which holds
+// the stream's APID and sequence count
+func createTelemetryPacket(enc *ccsds.Encoder, subsystem uint16, payload ccsds.TelemetryPayload, status ccsds.SpacecraftStatus) []byte {
+	header := ccsds.SecondaryHeader{Timestamp: uint64(time.Now().Unix()), SubsystemID: subsystem}
+	return encodeTelemetry(enc, header, payload, &status)
+}
 
-	return TelemetryPayload{
-		Temperature: randomFloat(20.0, 30.0),   // Normal operating range
-		Battery:     randomFloat(70.0, 100.0),  // Battery percentage
-		Altitude:    randomFloat(500.0, 550.0), // Orbit altitude
-		Signal:      randomFloat(-60.0, -40.0), // Signal strength
+// encodeTelemetry encodes a telemetry packet, leaving out the spacecraft
+// status when status is nil
+func encodeTelemetry(enc *ccsds.Encoder, header ccsds.SecondaryHeader, payload ccsds.TelemetryPayload, status *ccsds.SpacecraftStatus) []byte {
+	data := make([]byte, 0, ccsds.SecondaryHeaderSize+ccsds.TelemetryPayloadSize+ccsds.SpacecraftStatusSize)
+	data = header.Append(data)
+	data = payload.Append(data)
+	if status != nil {
+		data = status.Append(data)
 	}
+	return enc.Encode(nil, data)
 }
 
-func randomFloat(min, max float32) float32 {
-	return min + rand.Float32()*(max-min)
+// newTelemetryEncoder returns the encoder for one telemetry stream
+func newTelemetryEncoder(apid uint16) *ccsds.Encoder {
+	return &ccsds.Encoder{Type: ccsds.TypeTM, APID: apid, SecondaryHeader: true, CRC: *appendCRC}
 }
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// Earth constants
+const (
+	earthRadius = 6371.0       // Mean radius in kilometers
+	earthMu     = 398600.4418  // Gravitational parameter in km^3/s^2
+	earthRate   = 7.2921159e-5 // Rotation rate in rad/s
+)
+
+// Simulation parameters. Every random draw comes from -seed, so two runs with
+// the same seed and commands produce the same telemetry.
+var (
+	seed      = flag.Int64("seed", 0, "random seed for reproducible telemetry (0 picks one from the clock)")
+	timeScale = flag.Float64("time-scale", 10, "simulated seconds per real second")
+
+	// Orbit
+	initialAltitude = flag.Float64("altitude", 520, "initial orbit altitude in kilometers")
+	decayRate       = flag.Float64("decay", 0.05, "orbit decay in kilometers per simulated day")
+	inclination     = flag.Float64("inclination", 51.6, "orbit inclination in degrees")
+	betaAngle       = flag.Float64("beta", 20, "angle between the orbit plane and the sun in degrees")
+
+	// Power
+	batteryCapacity = flag.Float64("battery-capacity", 80, "battery capacity in watt-hours")
+	initialCharge   = flag.Float64("battery-charge", 90, "initial battery state of charge in percent")
+	solarPower      = flag.Float64("solar-power", 60, "solar array output in sunlight in watts")
+	busLoad         = flag.Float64("bus-load", 30, "spacecraft bus power draw in watts")
+	safeModeLoad    = flag.Float64("safe-load", 15, "bus power draw in safe mode in watts")
+	heaterPower     = flag.Float64("heater-power", 10, "heater power draw in watts")
+
+	// Thermal
+	sunlitTemp  = flag.Float64("sunlit-temp", 30, "equilibrium temperature in sunlight in Celsius")
+	eclipseTemp = flag.Float64("eclipse-temp", 12, "equilibrium temperature in eclipse in Celsius")
+	heaterRise  = flag.Float64("heater-rise", 8, "equilibrium temperature rise with the heater on in Celsius")
+	thermalTau  = flag.Duration("thermal-tau", 15*time.Minute, "thermal time constant in simulated time")
+
+	// Communications
+	stations     = flag.String("stations", "64.8,-147.5;78.2,15.4;-25.9,27.7;35.4,-116.9", "ground stations as lat,lon pairs separated by semicolons")
+	minElevation = flag.Float64("min-elevation", 5, "elevation in degrees above which a station has contact")
+	zenithSignal = flag.Float64("zenith-signal", -45, "signal strength in dB with the spacecraft overhead")
+	signalFloor  = flag.Float64("signal-floor", -95, "signal strength in dB without station contact")
+)
+
+// groundStation is a receiving site on the Earth's surface
+type groundStation struct {
+	lat, lon float64 // Radians
+}
+
+// parseStations parses "lat,lon;lat,lon" in degrees
+func parseStations(s string) ([]groundStation, error) {
+	var list []groundStation
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ",")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("station %q must be lat,lon", entry)
+		}
+		lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		if err != nil || lat < -90 || lat > 90 {
+			return nil, fmt.Errorf("station %q has an invalid latitude", entry)
+		}
+		lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || lon < -180 || lon > 180 {
+			return nil, fmt.Errorf("station %q has an invalid longitude", entry)
+		}
+		list = append(list, groundStation{lat: radians(lat), lon: radians(lon)})
+	}
+	return list, nil
+}
+
+// modelParams are the parameters that differ between simulated spacecraft.
+// Orbit phases are drawn from seed and measurement noise from noiseSeed, so
+// streams of one spacecraft share an orbit but not their noise.
+type modelParams struct {
+	seed        int64
+	noiseSeed   int64
+	altitude    float64 // Kilometers
+	inclination float64 // Degrees
+	beta        float64 // Degrees
+}
+
+// spacecraftModel simulates a spacecraft in a circular orbit. Sunlight and
+// eclipse drive battery charge and temperature, and signal strength follows
+// the range to the highest visible ground station.
+type spacecraftModel struct {
+	params      modelParams
+	rng         *rand.Rand
+	stations    []groundStation
+	elapsed     float64 // Simulated seconds since the start
+	altitude    float64 // Kilometers
+	argLat      float64 // Argument of latitude, radians from the ascending node
+	earthAngle  float64 // Earth rotation angle at the start, radians
+	charge      float64 // Battery state of charge in percent
+	temperature float64 // Celsius
+}
+
+// newSpacecraftModel creates a model from params and the simulation flags
+func newSpacecraftModel(params modelParams) (*spacecraftModel, error) {
+	list, err := parseStations(*stations)
+	if err != nil {
+		return nil, err
+	}
+
+	phases := rand.New(rand.NewSource(params.seed))
+	m := &spacecraftModel{
+		params:     params,
+		rng:        rand.New(rand.NewSource(params.noiseSeed)),
+		stations:   list,
+		altitude:   params.altitude,
+		argLat:     phases.Float64() * 2 * math.Pi,
+		earthAngle: phases.Float64() * 2 * math.Pi,
+		charge:     *initialCharge,
+	}
+	m.temperature = *eclipseTemp
+	if m.sunlit() {
+		m.temperature = *sunlitTemp
+	}
+	return m, nil
+}
+
+// step advances the model by dt of real time and returns the telemetry the
+// spacecraft would report
+func (m *spacecraftModel) step(dt time.Duration, status ccsds.SpacecraftStatus) ccsds.TelemetryPayload {
+	sim := dt.Seconds() * *timeScale
+	m.elapsed += sim
+
+	// Orbit: circular, with the period set by the current altitude
+	m.altitude -= *decayRate * sim / 86400
+	r := earthRadius + m.altitude
+	m.argLat = math.Mod(m.argLat+math.Sqrt(earthMu/(r*r*r))*sim, 2*math.Pi)
+	sunlit := m.sunlit()
+
+	// Power: solar input in sunlight against the bus and heater loads
+	heaterOn := status.HeaterState == 1
+	load := *busLoad
+	if status.SafeMode == 1 {
+		load = *safeModeLoad
+	}
+	if heaterOn {
+		load += *heaterPower
+	}
+	net := -load
+	if sunlit {
+		net += *solarPower
+	}
+	m.charge += net * sim / 3600 / *batteryCapacity * 100
+	m.charge = math.Max(0, math.Min(100, m.charge))
+
+	// Thermal: first-order lag towards the equilibrium temperature
+	target := *eclipseTemp
+	if sunlit {
+		target = *sunlitTemp
+	}
+	if heaterOn {
+		target += *heaterRise
+	}
+	m.temperature += (target - m.temperature) * (1 - math.Exp(-sim/thermalTau.Seconds()))
+
+	return ccsds.TelemetryPayload{
+		Temperature: float32(m.temperature + m.rng.NormFloat64()*0.1),
+		Battery:     float32(m.charge),
+		Altitude:    float32(m.altitude + m.rng.NormFloat64()*0.01),
+		Signal:      float32(m.signal() + m.rng.NormFloat64()),
+	}
+}
+
+// sunlit reports whether the spacecraft is outside the Earth's cylindrical
+// shadow. The sun lies at the beta angle above the orbit plane, in the
+// direction of the ascending node.
+func (m *spacecraftModel) sunlit() bool {
+	beta := radians(m.params.beta)
+	cosSun := math.Cos(m.argLat) * math.Cos(beta) // Cosine of the spacecraft-sun angle
+	if cosSun >= 0 {
+		return true
+	}
+	r := earthRadius + m.altitude
+	return r*math.Sqrt(1-cosSun*cosSun) > earthRadius
+}
+
+// signal returns the signal strength at the best placed ground station.
+// Free-space loss grows with the square of the range, relative to a pass
+// directly overhead.
+func (m *spacecraftModel) signal() float64 {
+	r := earthRadius + m.altitude
+	inc := radians(m.params.inclination)
+	sat := [3]float64{
+		r * math.Cos(m.argLat),
+		r * math.Sin(m.argLat) * math.Cos(inc),
+		r * math.Sin(m.argLat) * math.Sin(inc),
+	}
+
+	best := *signalFloor
+	rotation := m.earthAngle + earthRate*m.elapsed
+	for _, gs := range m.stations {
+		theta := gs.lon + rotation
+		up := [3]float64{
+			math.Cos(gs.lat) * math.Cos(theta),
+			math.Cos(gs.lat) * math.Sin(theta),
+			math.Sin(gs.lat),
+		}
+
+		var rho [3]float64
+		var rangeSq, height float64
+		for i := range rho {
+			rho[i] = sat[i] - earthRadius*up[i]
+			rangeSq += rho[i] * rho[i]
+			height += rho[i] * up[i]
+		}
+		distance := math.Sqrt(rangeSq)
+		if math.Asin(height/distance) < radians(*minElevation) {
+			continue
+		}
+
+		signal := *zenithSignal - 20*math.Log10(distance/m.altitude)
+		best = math.Max(best, signal)
+	}
+	return best
+}
+
+// runSeed is -seed, or the seed taken from the clock when it is zero
+var runSeed int64
+
+// resolveSeed returns s, or a seed taken from the clock when s is zero
+func resolveSeed(s int64) int64 {
+	if s == 0 {
+		return time.Now().UnixNano()
+	}
+	return s
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// Replay file formats
+const (
+	REPLAY_CSV    = "csv"    // Header row naming the telemetry fields, one record per row
+	REPLAY_NDJSON = "ndjson" // One backend telemetry record in JSON per line
+	REPLAY_RAW    = "raw"    // Concatenated space packets as received
+)
+
+// Replay settings. A replay sends recorded telemetry instead of simulating
+// it, and ends once the recording has been sent.
+var (
+	replayFile   = flag.String("replay", "", "recorded telemetry to send instead of simulating (CSV, NDJSON or raw packets)")
+	replayFormat = flag.String("replay-format", "", "replay file format: csv, ndjson or raw (empty guesses from the extension)")
+	replaySpeed  = flag.Float64("replay-speed", 1, "replay rate relative to the recording; 0 sends as fast as possible")
+	replayNow    = flag.Bool("replay-now", false, "rewrite recorded timestamps to the time each packet is sent")
+)
+
+// replayRecord is one recorded telemetry sample
+type replayRecord struct {
+	apid      uint16
+	seqCount  uint16
+	hasSeq    bool // Whether the recording kept the sequence count
+	subsystem uint16
+	timestamp time.Time
+	payload   ccsds.TelemetryPayload
+	status    *ccsds.SpacecraftStatus // Nil when the recording has no spacecraft status
+}
+
+// ndjsonRecord is the JSON form of a backend telemetry record. Fields are
+// matched case-insensitively, so exported records load unchanged.
+type ndjsonRecord struct {
+	APID          *uint16
+	SequenceCount *uint16
+	Timestamp     time.Time
+	Temperature   float32
+	Battery       float32
+	Altitude      float32
+	Signal        float32
+	HeaterOn      *bool
+	SafeMode      *bool
+	TxIntervalMs  *uint16
+}
+
+// runReplay sends a recording through link, paced by its timestamps
+func runReplay(link *impairedLink) {
+	format := *replayFormat
+	if format == "" {
+		format = replayFormatOf(*replayFile)
+	}
+	if *replaySpeed < 0 || math.IsInf(*replaySpeed, 0) || math.IsNaN(*replaySpeed) {
+		log.Fatalf("Replay speed must be zero or positive")
+	}
+
+	records, err := loadReplay(*replayFile, format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(records) == 0 {
+		log.Fatalf("%s: no telemetry records", *replayFile)
+	}
+
+	dl := newDownlink(link, uint16(*spacecraftID))
+	encoders := make(map[uint16]*ccsds.Encoder)
+
+	span := records[len(records)-1].timestamp.Sub(records[0].timestamp)
+	log.Printf("Replaying %d records spanning %s from %s", len(records), span, *replayFile)
+
+	start := time.Now()
+	var sent, failed int
+	for _, r := range records {
+		// Records are sent at their offset from the first, scaled by the
+		// speed; ones recorded out of order are sent immediately
+		if *replaySpeed > 0 {
+			offset := time.Duration(float64(r.timestamp.Sub(records[0].timestamp)) / *replaySpeed)
+			if wait := time.Until(start.Add(offset)); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		enc, ok := encoders[r.apid]
+		if !ok {
+			enc = newTelemetryEncoder(r.apid)
+			encoders[r.apid] = enc
+		}
+		if r.hasSeq {
+			enc.SetSeqCount(r.seqCount)
+		}
+
+		timestamp := r.timestamp
+		if *replayNow {
+			timestamp = time.Now()
+		}
+		header := ccsds.SecondaryHeader{Timestamp: uint64(timestamp.Unix()), SubsystemID: r.subsystem}
+		if err := dl.send(encodeTelemetry(enc, header, r.payload, r.status)); err != nil {
+			log.Printf("Error sending replayed telemetry: %v", err)
+			failed++
+			continue
+		}
+		sent++
+	}
+
+	log.Printf("Replay finished: sent %d of %d records in %s", sent, len(records), time.Since(start).Round(time.Millisecond))
+	if failed > 0 {
+		log.Printf("%d records could not be sent", failed)
+	}
+}
+
+// replayFormatOf guesses a recording's format from its file extension
+func replayFormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return REPLAY_CSV
+	case ".ndjson", ".jsonl", ".json":
+		return REPLAY_NDJSON
+	}
+	return REPLAY_RAW
+}
+
+// loadReplay reads every record of a recording
+func loadReplay(path, format string) ([]replayRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []replayRecord
+	switch format {
+	case REPLAY_CSV:
+		records, err = readCSV(f)
+	case REPLAY_NDJSON:
+		records, err = readNDJSON(f)
+	case REPLAY_RAW:
+		records, err = readRaw(f)
+	default:
+		return nil, fmt.Errorf("unknown replay format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return records, nil
+}
+
+// readNDJSON reads one JSON telemetry record per line, skipping blank lines
+func readNDJSON(r io.Reader) ([]replayRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	var records []replayRecord
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var raw ndjsonRecord
+		if err := json.Unmarshal([]byte(text), &raw); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if raw.Timestamp.IsZero() {
+			return nil, fmt.Errorf("line %d: missing Timestamp", line)
+		}
+
+		record := replayRecord{
+			apid:      APID,
+			subsystem: SUBSYSTEM_ID,
+			timestamp: raw.Timestamp,
+			payload: ccsds.TelemetryPayload{
+				Temperature: raw.Temperature,
+				Battery:     raw.Battery,
+				Altitude:    raw.Altitude,
+				Signal:      raw.Signal,
+			},
+			status: recordedStatus(raw.HeaterOn, raw.SafeMode, raw.TxIntervalMs),
+		}
+		if raw.APID != nil {
+			record.apid = *raw.APID
+		}
+		if raw.SequenceCount != nil {
+			record.seqCount, record.hasSeq = *raw.SequenceCount, true
+		}
+		if err := record.validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// readCSV reads records from a CSV file whose header row names the columns.
+// Names are matched ignoring case, spaces and underscores; timestamp and the
+// four payload values are required.
+func readCSV(r io.Reader) ([]replayRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.NewReplacer("_", "", " ", "").Replace(name))
+		columns[name] = i
+	}
+	for _, name := range []string{"timestamp", "temperature", "battery", "altitude", "signal"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing %s column", name)
+		}
+	}
+
+	var records []replayRecord
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		record, err := csvRecord(row, columns)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+}
+
+// csvRecord converts one CSV row
+func csvRecord(row []string, columns map[string]int) (replayRecord, error) {
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	record := replayRecord{apid: APID, subsystem: SUBSYSTEM_ID}
+	var err error
+	if record.timestamp, err = parseTimestamp(field("timestamp")); err != nil {
+		return record, err
+	}
+
+	values := []*float32{
+		&record.payload.Temperature,
+		&record.payload.Battery,
+		&record.payload.Altitude,
+		&record.payload.Signal,
+	}
+	for i, name := range []string{"temperature", "battery", "altitude", "signal"} {
+		v, err := strconv.ParseFloat(field(name), 32)
+		if err != nil {
+			return record, fmt.Errorf("invalid %s %q", name, field(name))
+		}
+		*values[i] = float32(v)
+	}
+
+	integers := []struct {
+		name string
+		dst  *uint16
+		set  *bool
+	}{
+		{"apid", &record.apid, nil},
+		{"sequencecount", &record.seqCount, &record.hasSeq},
+		{"subsystem", &record.subsystem, nil},
+	}
+	for _, c := range integers {
+		if field(c.name) == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(field(c.name), 0, 16)
+		if err != nil {
+			return record, fmt.Errorf("invalid %s %q", c.name, field(c.name))
+		}
+		*c.dst = uint16(v)
+		if c.set != nil {
+			*c.set = true
+		}
+	}
+
+	var heaterOn, safeMode *bool
+	var txInterval *uint16
+	for name, dst := range map[string]**bool{"heateron": &heaterOn, "safemode": &safeMode} {
+		if field(name) == "" {
+			continue
+		}
+		v, err := strconv.ParseBool(field(name))
+		if err != nil {
+			return record, fmt.Errorf("invalid %s %q", name, field(name))
+		}
+		*dst = &v
+	}
+	if s := field("txintervalms"); s != "" {
+		v, err := strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return record, fmt.Errorf("invalid tx interval %q", s)
+		}
+		interval := uint16(v)
+		txInterval = &interval
+	}
+	record.status = recordedStatus(heaterOn, safeMode, txInterval)
+
+	return record, record.validate()
+}
+
+// readRaw reads concatenated space packets in the generator's layout. A
+// packet error control field is recognised by the packet length and removed,
+// since packets are re-encoded with the current -crc setting. Packets that
+// are not telemetry, or fail their CRC, are skipped and counted.
+func readRaw(r io.Reader) ([]replayRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []replayRecord
+	var skipped, badCRC int
+	decoder := ccsds.NewDecoder(data)
+	for n := 1; ; n++ {
+		packet, err := decoder.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("packet %d: %w", n, err)
+		}
+		if packet.APID() == ccsds.IdleAPID || packet.Header().Type() != ccsds.TypeTM {
+			continue
+		}
+
+		// Recorded passes also hold packets such as command acknowledgements,
+		// which are not telemetry and are left out
+		if !packet.Header().HasSecondaryHeader() {
+			skipped++
+			continue
+		}
+		body := packet.Data()
+		base := ccsds.SecondaryHeaderSize + ccsds.TelemetryPayloadSize
+		switch len(body) {
+		case base, base + ccsds.SpacecraftStatusSize:
+		case base + ccsds.CRCSize, base + ccsds.SpacecraftStatusSize + ccsds.CRCSize:
+			if body, err = packet.UserData(); err != nil {
+				badCRC++
+				continue
+			}
+		default:
+			skipped++
+			continue
+		}
+
+		secondary, _ := ccsds.ParseSecondaryHeader(body)
+		payload, _ := ccsds.ParseTelemetryPayload(body[ccsds.SecondaryHeaderSize:])
+		record := replayRecord{
+			apid:      packet.APID(),
+			seqCount:  packet.SeqCount(),
+			hasSeq:    true,
+			subsystem: secondary.SubsystemID,
+			timestamp: time.Unix(int64(secondary.Timestamp), 0),
+			payload:   payload,
+		}
+		if status, err := ccsds.ParseSpacecraftStatus(body[base:]); err == nil {
+			record.status = &status
+		}
+		records = append(records, record)
+	}
+
+	if skipped > 0 || badCRC > 0 {
+		log.Printf("Skipped %d packets that are not telemetry and %d with a bad CRC", skipped, badCRC)
+	}
+	return records, nil
+}
+
+// recordedStatus builds the spacecraft status from the recorded fields, or
+// returns nil when none were recorded
+func recordedStatus(heaterOn, safeMode *bool, txInterval *uint16) *ccsds.SpacecraftStatus {
+	if heaterOn == nil && safeMode == nil && txInterval == nil {
+		return nil
+	}
+	var status ccsds.SpacecraftStatus
+	if heaterOn != nil && *heaterOn {
+		status.HeaterState = 1
+	}
+	if safeMode != nil && *safeMode {
+		status.SafeMode = 1
+	}
+	if txInterval != nil {
+		status.TxIntervalMs = *txInterval
+	}
+	return &status
+}
+
+// parseTimestamp accepts RFC 3339 times or Unix seconds
+func parseTimestamp(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)), nil
+}
+
+// validate checks that a record can be encoded
+func (r replayRecord) validate() error {
+	if r.apid >= ccsds.IdleAPID {
+		return fmt.Errorf("APID %d is out of range", r.apid)
+	}
+	if r.hasSeq && r.seqCount > ccsds.MaxSeqCount {
+		return fmt.Errorf("sequence count %d does not fit in 14 bits", r.seqCount)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// scenarioFile names a JSON scenario to play over the simulated telemetry
+var scenarioFile = flag.String("scenario", "", "JSON scenario file with a timeline of faults")
+
+// Scenario event types
+const (
+	EVENT_RAMP    = "ramp"    // Move a parameter linearly to a value over a period
+	EVENT_RATE    = "rate"    // Change a parameter at a constant rate per second
+	EVENT_SET     = "set"     // Hold a parameter at a value
+	EVENT_SILENCE = "silence" // Stop transmitting
+)
+
+// scenarioEvent is one entry in a scenario timeline. Times are offsets from
+// the first packet and durations use Go syntax, e.g. "90s" or "2m".
+type scenarioEvent struct {
+	At        string  `json:"at"`
+	Type      string  `json:"type"`
+	Parameter string  `json:"parameter,omitempty"`
+	To        float64 `json:"to,omitempty"`       // Ramp target
+	Over      string  `json:"over,omitempty"`     // Ramp period
+	Rate      float64 `json:"rate,omitempty"`     // Change per second
+	Value     float64 `json:"value,omitempty"`    // Held value
+	Duration  string  `json:"duration,omitempty"` // How long the event lasts; forever if empty
+}
+
+// scenarioConfig is the JSON form of a scenario
+type scenarioConfig struct {
+	Name   string          `json:"name"`
+	Seed   int64           `json:"seed,omitempty"` // Used unless -seed is given
+	Events []scenarioEvent `json:"events"`
+}
+
+// fault is a parsed scenario event and its progress
+type fault struct {
+	event    scenarioEvent
+	at       time.Duration
+	over     time.Duration
+	duration time.Duration
+	started  bool
+	ended    bool
+	start    float64 // Parameter value when the event began
+}
+
+// scenario plays a timeline of faults against the telemetry. Events are
+// timed from the intervals between packets rather than the clock, so a run
+// with the same seed and commands is repeated exactly.
+type scenario struct {
+	name   string
+	seed   int64
+	faults []*fault
+}
+
+// loadScenario reads and validates a scenario file
+func loadScenario(path string) (*scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg scenarioConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	s := &scenario{name: cfg.Name, seed: cfg.Seed}
+	for i, event := range cfg.Events {
+		f, err := parseEvent(event)
+		if err != nil {
+			return nil, fmt.Errorf("%s: event %d: %w", path, i+1, err)
+		}
+		s.faults = append(s.faults, f)
+	}
+	return s, nil
+}
+
+// parseEvent checks an event and parses its times
+func parseEvent(event scenarioEvent) (*fault, error) {
+	f := &fault{event: event}
+
+	var err error
+	if f.at, err = parseOffset(event.At, "at", true); err != nil {
+		return nil, err
+	}
+	if f.duration, err = parseOffset(event.Duration, "duration", false); err != nil {
+		return nil, err
+	}
+
+	switch event.Type {
+	case EVENT_SILENCE:
+		return f, nil
+	case EVENT_RAMP:
+		if f.over, err = parseOffset(event.Over, "over", true); err != nil {
+			return nil, err
+		}
+	case EVENT_RATE, EVENT_SET:
+	default:
+		return nil, fmt.Errorf("unknown type %q", event.Type)
+	}
+
+	if _, ok := parameterField(&ccsds.TelemetryPayload{}, event.Parameter); !ok {
+		return nil, fmt.Errorf("unknown parameter %q", event.Parameter)
+	}
+	return f, nil
+}
+
+// parseOffset parses a non-negative duration
+func parseOffset(value, field string, required bool) (time.Duration, error) {
+	if value == "" {
+		if required {
+			return 0, fmt.Errorf("%s is required", field)
+		}
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q", field, value)
+	}
+	return d, nil
+}
+
+// apply modifies the payload of the packet at offset t and reports whether
+// the packet should be transmitted
+func (s *scenario) apply(t time.Duration, payload *ccsds.TelemetryPayload) bool {
+	transmit := true
+	for _, f := range s.faults {
+		if t < f.at || f.ended {
+			continue
+		}
+		if f.duration > 0 && t >= f.at+f.duration {
+			f.ended = true
+			log.Printf("Scenario event ended: %s", f.describe())
+			continue
+		}
+
+		if f.event.Type == EVENT_SILENCE {
+			if !f.started {
+				f.started = true
+				log.Printf("Scenario event started: %s", f.describe())
+			}
+			transmit = false
+			continue
+		}
+
+		field, _ := parameterField(payload, f.event.Parameter)
+		if !f.started {
+			f.started = true
+			f.start = float64(*field)
+			log.Printf("Scenario event started: %s", f.describe())
+		}
+
+		elapsed := (t - f.at).Seconds()
+		var value float64
+		switch f.event.Type {
+		case EVENT_RAMP:
+			progress := 1.0
+			if f.over > 0 {
+				progress = math.Min(1, elapsed/f.over.Seconds())
+			}
+			value = f.start + (f.event.To-f.start)*progress
+		case EVENT_RATE:
+			value = f.start + f.event.Rate*elapsed
+		case EVENT_SET:
+			value = f.event.Value
+		}
+		*field = float32(clampParameter(f.event.Parameter, value))
+	}
+	return transmit
+}
+
+// describe summarises an event for the log
+func (f *fault) describe() string {
+	e := f.event
+	switch e.Type {
+	case EVENT_RAMP:
+		return fmt.Sprintf("T+%s ramp %s to %g over %s", f.at, e.Parameter, e.To, f.over)
+	case EVENT_RATE:
+		return fmt.Sprintf("T+%s change %s by %g/s", f.at, e.Parameter, e.Rate)
+	case EVENT_SET:
+		return fmt.Sprintf("T+%s hold %s at %g", f.at, e.Parameter, e.Value)
+	}
+	return fmt.Sprintf("T+%s stop transmitting", f.at)
+}
+
+// parameterField returns the payload field holding a parameter
+func parameterField(payload *ccsds.TelemetryPayload, name string) (*float32, bool) {
+	switch name {
+	case "temperature":
+		return &payload.Temperature, true
+	case "battery":
+		return &payload.Battery, true
+	case "altitude":
+		return &payload.Altitude, true
+	case "signal":
+		return &payload.Signal, true
+	}
+	return nil, false
+}
+
+// clampParameter keeps a parameter within its physical range
+func clampParameter(name string, value float64) float64 {
+	switch name {
+	case "battery":
+		return math.Max(0, math.Min(100, value))
+	case "altitude":
+		return math.Max(0, value)
+	}
+	return value
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// play applies a single event to fresh simulated values at each offset and
+// returns the resulting value of its parameter
+func play(t *testing.T, event scenarioEvent, offsets ...time.Duration) []float32 {
+	t.Helper()
+	f, err := parseEvent(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &scenario{faults: []*fault{f}}
+
+	var values []float32
+	for _, offset := range offsets {
+		payload := ccsds.TelemetryPayload{Temperature: 20, Battery: 90, Altitude: 520, Signal: -50}
+		if !s.apply(offset, &payload) {
+			t.Fatalf("T+%s: packet withheld", offset)
+		}
+		field, _ := parameterField(&payload, event.Parameter)
+		values = append(values, *field)
+	}
+	return values
+}
+
+func checkValues(t *testing.T, got []float32, want ...float32) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("values %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("values %v, want %v", got, want)
+		}
+	}
+}
+
+func TestScenarioRamp(t *testing.T) {
+	// The ramp starts from the value at its start and holds its target
+	got := play(t, scenarioEvent{At: "10s", Type: EVENT_RAMP, Parameter: "battery", To: 50, Over: "20s"},
+		5*time.Second, 10*time.Second, 20*time.Second, 40*time.Second)
+	checkValues(t, got, 90, 90, 70, 50)
+}
+
+func TestScenarioRate(t *testing.T) {
+	got := play(t, scenarioEvent{At: "0s", Type: EVENT_RATE, Parameter: "temperature", Rate: 0.5},
+		0, 10*time.Second, 60*time.Second)
+	checkValues(t, got, 20, 25, 50)
+
+	// A battery cannot drain below empty
+	got = play(t, scenarioEvent{At: "0s", Type: EVENT_RATE, Parameter: "battery", Rate: -10},
+		5*time.Second, 20*time.Second)
+	checkValues(t, got, 40, 0)
+}
+
+func TestScenarioSetEnds(t *testing.T) {
+	got := play(t, scenarioEvent{At: "1s", Type: EVENT_SET, Parameter: "signal", Value: -90, Duration: "2s"},
+		0, time.Second, 3*time.Second)
+	checkValues(t, got, -50, -90, -50)
+}
+
+func TestScenarioSilence(t *testing.T) {
+	f, err := parseEvent(scenarioEvent{At: "2s", Type: EVENT_SILENCE, Duration: "3s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &scenario{faults: []*fault{f}}
+
+	// The fault ends once passed, so the offsets are applied in order
+	for _, step := range []struct {
+		offset   time.Duration
+		transmit bool
+	}{
+		{time.Second, true},
+		{2 * time.Second, false},
+		{4 * time.Second, false},
+		{5 * time.Second, true},
+	} {
+		if got := s.apply(step.offset, &ccsds.TelemetryPayload{}); got != step.transmit {
+			t.Errorf("T+%s: transmit %v, want %v", step.offset, got, step.transmit)
+		}
+	}
+}
+
+func TestParseEventRejects(t *testing.T) {
+	for want, event := range map[string]scenarioEvent{
+		"at is required":    {Type: EVENT_SET, Parameter: "battery"},
+		"invalid at":        {At: "-1s", Type: EVENT_SET, Parameter: "battery"},
+		"invalid duration":  {At: "1s", Type: EVENT_SET, Parameter: "battery", Duration: "soon"},
+		"over is required":  {At: "1s", Type: EVENT_RAMP, Parameter: "battery", To: 5},
+		"unknown type":      {At: "1s", Type: "explode", Parameter: "battery"},
+		"unknown parameter": {At: "1s", Type: EVENT_SET, Parameter: "pressure"},
+	} {
+		if _, err := parseEvent(event); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%+v: error %v, want %q", event, err, want)
+		}
+	}
+
+	// Silence applies to the whole packet, so it names no parameter
+	if _, err := parseEvent(scenarioEvent{At: "5s", Type: EVENT_SILENCE, Duration: "10s"}); err != nil {
+		t.Errorf("silence: %v", err)
+	}
+}
+
+func TestLoadScenario(t *testing.T) {
+	// The example scenario shipped with the generator
+	s, err := loadScenario(filepath.Join("..", "..", "scenarios", "thermal-runaway.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.name != "thermal runaway" || s.seed != 42 || len(s.faults) != 3 {
+		t.Errorf("loaded %q with seed %d and %d events", s.name, s.seed, len(s.faults))
+	}
+
+	dir := t.TempDir()
+	for want, content := range map[string]string{
+		"parse":   `{"events": [`,
+		"event 2": `{"events": [{"at": "1s", "type": "silence"}, {"at": "1s", "type": "bogus"}]}`,
+	} {
+		path := filepath.Join(dir, "scenario.json")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadScenario(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want one mentioning %q", content, err, want)
+		}
+	}
+}
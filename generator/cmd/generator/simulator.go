@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds"
+)
+
+// Telecommand opcodes understood by the simulator
+const (
+	OPCODE_NOOP          = 0x0001
+	OPCODE_SET_HEATER    = 0x0002
+	OPCODE_SET_SAFE_MODE = 0x0003
+	OPCODE_SET_TX_RATE   = 0x0004
+)
+
+// Acknowledgement stages and failure codes
+const (
+	ACK_APID           = 0x011
+	ACK_STAGE_RECEIVED = 1
+	ACK_STAGE_ACCEPTED = 2
+	ACK_STAGE_EXECUTED = 3
+
+	ACK_OK               = 0
+	ACK_UNKNOWN_OPCODE   = 1
+	ACK_INVALID_ARGUMENT = 2
+	ACK_REFUSED          = 3 // Not allowed in the current state
+)
+
+const (
+	safeModeMinInterval = 5 * time.Second // Slowest telemetry rate is enforced in safe mode
+	executionDelay      = 500 * time.Millisecond
+)
+
+// spacecraftState is the state that telecommands change
+type spacecraftState struct {
+	mu         sync.Mutex
+	heaterOn   bool
+	safeMode   bool
+	txInterval time.Duration
+}
+
+// status returns the state as reported in telemetry
+func (s *spacecraftState) status() ccsds.SpacecraftStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var status ccsds.SpacecraftStatus
+	if s.heaterOn {
+		status.HeaterState = 1
+	}
+	if s.safeMode {
+		status.SafeMode = 1
+	}
+	status.TxIntervalMs = uint16(s.interval() / time.Millisecond)
+	return status
+}
+
+// interval returns the effective telemetry interval. Callers hold s.mu.
+func (s *spacecraftState) interval() time.Duration {
+	if s.safeMode && s.txInterval < safeModeMinInterval {
+		return safeModeMinInterval
+	}
+	return s.txInterval
+}
+
+// nextInterval returns how long to wait before the next telemetry packet
+func (s *spacecraftState) nextInterval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interval()
+}
+
+// commandReceiver listens for TC packets and routes them by APID to the
+// spacecraft they command
+type commandReceiver struct {
+	targets  map[uint16]*spacecraft
+	fallback *spacecraft // Receives commands for any other APID when set
+}
+
+// newCommandReceiver routes commands to the fleet
+func newCommandReceiver(fleet []*spacecraft) *commandReceiver {
+	r := &commandReceiver{targets: make(map[uint16]*spacecraft)}
+	for _, sc := range fleet {
+		if sc.cfg.CommandAPID == 0 {
+			r.fallback = sc
+		} else {
+			r.targets[sc.cfg.CommandAPID] = sc
+		}
+	}
+	return r
+}
+
+// listen receives TC packets on addr until the socket fails
+func (r *commandReceiver) listen(addr string) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		log.Fatal("Failed to listen for telecommands:", err)
+	}
+	defer conn.Close()
+
+	log.Printf("Listening for telecommands on %s", addr)
+
+	buffer := make([]byte, 65535)
+	for {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			log.Printf("Error receiving telecommand: %v", err)
+			continue
+		}
+		r.handle(append([]byte(nil), buffer[:n]...))
+	}
+}
+
+// handle validates one TC packet and passes it to the spacecraft it commands
+func (r *commandReceiver) handle(packet []byte) {
+	if len(packet) < ccsds.PrimaryHeaderSize+2+ccsds.CRCSize {
+		log.Printf("Dropped short telecommand (%d bytes)", len(packet))
+		return
+	}
+	tc, err := ccsds.Parse(packet)
+	if err != nil || tc.Header().Type() != ccsds.TypeTC || len(tc) < ccsds.PrimaryHeaderSize+2+ccsds.CRCSize {
+		log.Printf("Dropped malformed telecommand")
+		return
+	}
+
+	// A corrupted packet cannot be attributed to a command, so it is not acknowledged
+	body, err := tc.UserData()
+	if err != nil {
+		log.Printf("Dropped telecommand with bad CRC")
+		return
+	}
+
+	sc, ok := r.targets[tc.APID()]
+	if !ok {
+		sc = r.fallback
+	}
+	if sc == nil {
+		log.Printf("Dropped telecommand for unknown APID %d", tc.APID())
+		return
+	}
+	header := tc.Header()
+	sc.command(header.PacketID, header.PacketSeqCtrl, body)
+}
+
+// command acknowledges and executes one validated command
+func (sc *spacecraft) command(packetID, seqCtrl uint16, data []byte) {
+	sc.ack(packetID, seqCtrl, ACK_STAGE_RECEIVED, ACK_OK)
+
+	opcode := binary.BigEndian.Uint16(data[0:2])
+	execute, result := sc.accept(opcode, data[2:])
+	sc.ack(packetID, seqCtrl, ACK_STAGE_ACCEPTED, result)
+	if result != ACK_OK {
+		log.Printf("%s rejected telecommand opcode %d (code %d)", sc.cfg.Name, opcode, result)
+		return
+	}
+
+	time.AfterFunc(executionDelay, func() {
+		result := execute()
+		sc.ack(packetID, seqCtrl, ACK_STAGE_EXECUTED, result)
+		log.Printf("%s executed telecommand opcode %d (code %d)", sc.cfg.Name, opcode, result)
+	})
+}
+
+// accept checks a command's arguments and returns the action executing it
+func (sc *spacecraft) accept(opcode uint16, args []byte) (func() uint8, uint8) {
+	s := sc.state
+	switch opcode {
+	case OPCODE_NOOP:
+		if len(args) != 0 {
+			return nil, ACK_INVALID_ARGUMENT
+		}
+		return func() uint8 { return ACK_OK }, ACK_OK
+
+	case OPCODE_SET_HEATER:
+		if len(args) != 1 || args[0] > 1 {
+			return nil, ACK_INVALID_ARGUMENT
+		}
+		on := args[0] == 1
+		return func() uint8 {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if on && s.safeMode {
+				return ACK_REFUSED
+			}
+			s.heaterOn = on
+			return ACK_OK
+		}, ACK_OK
+
+	case OPCODE_SET_SAFE_MODE:
+		if len(args) != 1 || args[0] > 1 {
+			return nil, ACK_INVALID_ARGUMENT
+		}
+		enabled := args[0] == 1
+		return func() uint8 {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.safeMode = enabled
+			if enabled {
+				s.heaterOn = false // Shed non-essential loads
+			}
+			return ACK_OK
+		}, ACK_OK
+
+	case OPCODE_SET_TX_RATE:
+		if len(args) != 2 {
+			return nil, ACK_INVALID_ARGUMENT
+		}
+		ms := binary.BigEndian.Uint16(args)
+		if ms < 100 {
+			return nil, ACK_INVALID_ARGUMENT
+		}
+		return func() uint8 {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.txInterval = time.Duration(ms) * time.Millisecond
+			return ACK_OK
+		}, ACK_OK
+	}
+	return nil, ACK_UNKNOWN_OPCODE
+}
+
+// ack sends an acknowledgement packet for one stage of a command
+func (sc *spacecraft) ack(packetID, seqCtrl uint16, stage, result uint8) {
+	data := make([]byte, 6)
+	binary.BigEndian.PutUint16(data[0:2], packetID)
+	binary.BigEndian.PutUint16(data[2:4], seqCtrl)
+	data[4] = stage
+	data[5] = result
+
+	sc.mu.Lock()
+	packet := sc.acks.Encode(nil, data)
+	sc.mu.Unlock()
+
+	if err := sc.downlink.send(packet); err != nil {
+		log.Printf("Error sending acknowledgement: %v", err)
+	}
+}
@@ -1,3 +1,8 @@
 module github.com/mtthew-teng/Turion-GSW-Take-Home/generator
 
 go 1.20
+
+require github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds v0.0.0
+
+// The packet library lives alongside the backend and generator in this repository
+replace github.com/mtthew-teng/Turion-GSW-Take-Home/ccsds => ../ccsds